@@ -0,0 +1,259 @@
+/*
+Package acl provides authorization of connect, publish and
+subscribe requests.
+
+Rules are read from a text file, one rule per line
+
+	# access action    user  [filter]
+	allow    connect   *
+	allow    pubsub    *     clients/%c/#
+	allow    subscribe *     news/+
+	deny     publish   guest #
+	allow    publish   admin #
+
+Access is allow or deny. Action is one of connect, publish,
+subscribe or pubsub, where the latter means both publish and
+subscribe. User is a username or * for any user, including
+anonymous. Filter is a topic filter which may contain the wildcards +
+and #, and the substitutions %c and %u which are replaced with the
+client ID and username of the session. Connect rules have no
+filter. Lines starting with # are comments.
+
+Rules are evaluated in order, the first matching rule decides. If
+no rule matches access is denied.
+*/
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gregoryv/mq"
+)
+
+// Authorizer decides if a session may perform an action on a
+// topic. For Connect the topic is empty.
+type Authorizer interface {
+	Authorize(s Session, a Action, topic string) bool
+}
+
+// NewSession returns the session identified by the connect packet.
+func NewSession(p *mq.Connect) Session {
+	return Session{
+		ClientID: p.ClientID(),
+		Username: p.Username(),
+	}
+}
+
+// Session identifies a connected client.
+type Session struct {
+	ClientID string
+	Username string
+}
+
+type Action uint8
+
+const (
+	Connect Action = 1 << iota
+	Publish
+	Subscribe
+
+	PubSub = Publish | Subscribe
+)
+
+func (a Action) String() string {
+	switch a {
+	case Connect:
+		return "connect"
+	case Publish:
+		return "publish"
+	case Subscribe:
+		return "subscribe"
+	case PubSub:
+		return "pubsub"
+	}
+	return fmt.Sprintf("Action(%d)", a)
+}
+
+var actionNames = map[string]Action{
+	"connect":   Connect,
+	"publish":   Publish,
+	"subscribe": Subscribe,
+	"pubsub":    PubSub,
+}
+
+// CheckConnect returns Success if the connect is authorized,
+// NotAuthorized otherwise. Use it as ConnAck reason code.
+func CheckConnect(a Authorizer, p *mq.Connect) mq.ReasonCode {
+	if !a.Authorize(NewSession(p), Connect, "") {
+		return mq.NotAuthorized
+	}
+	return mq.Success
+}
+
+// CheckPublish returns Success if the session may publish to the
+// packets topic name, NotAuthorized otherwise. Use it as PubAck or
+// PubRec reason code. Topic aliases must be resolved by the caller.
+func CheckPublish(a Authorizer, s Session, p *mq.Publish) mq.ReasonCode {
+	if !a.Authorize(s, Publish, p.TopicName()) {
+		return mq.NotAuthorized
+	}
+	return mq.Success
+}
+
+// CheckSubscribe returns one reason code per topic filter in the
+// packet, GrantedQoS0, 1 or 2 for authorized filters and
+// NotAuthorized for others. Use them as SubAck reason codes.
+func CheckSubscribe(a Authorizer, s Session, p *mq.Subscribe) []mq.ReasonCode {
+	filters := p.Filters()
+	codes := make([]mq.ReasonCode, len(filters))
+	for i, f := range filters {
+		if !a.Authorize(s, Subscribe, f.Filter()) {
+			codes[i] = mq.NotAuthorized
+			continue
+		}
+		codes[i] = mq.ReasonCode(f.Options() & mq.OptQoS3)
+	}
+	return codes
+}
+
+// ----------------------------------------
+
+// Load reads rules from the given file.
+func Load(filename string) (*ACL, error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("acl.Load: %w", err)
+	}
+	defer fh.Close()
+	acl, err := Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("acl.Load %s: %w", filename, err)
+	}
+	return acl, nil
+}
+
+// Parse reads rules from the given reader.
+func Parse(r io.Reader) (*ACL, error) {
+	var acl ACL
+	s := bufio.NewScanner(r)
+	for no := 1; s.Scan(); no++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule, err := parseRule(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", no, err)
+		}
+		acl.rules = append(acl.rules, rule)
+	}
+	return &acl, s.Err()
+}
+
+func parseRule(fields []string) (Rule, error) {
+	var rule Rule
+	switch fields[0] {
+	case "allow":
+		rule.Allow = true
+	case "deny":
+	default:
+		return rule, fmt.Errorf("%w %q", ErrAccess, fields[0])
+	}
+	if len(fields) < 3 {
+		return rule, ErrFields
+	}
+	action, found := actionNames[fields[1]]
+	if !found {
+		return rule, fmt.Errorf("%w %q", ErrAction, fields[1])
+	}
+	rule.Action = action
+	rule.User = fields[2]
+
+	switch {
+	case action == Connect && len(fields) == 3:
+	case action != Connect && len(fields) == 4:
+		rule.Filter = fields[3]
+		f := mq.NewTopicFilter(rule.Filter, 0)
+		if err := f.WellFormed(); err != nil || !validFilter(rule.Filter) {
+			return rule, fmt.Errorf("%w %q", ErrFilter, rule.Filter)
+		}
+	default:
+		return rule, ErrFields
+	}
+	return rule, nil
+}
+
+// ACL is an ordered list of rules.
+type ACL struct {
+	rules []Rule
+}
+
+// Add appends rules to the list.
+func (a *ACL) Add(v ...Rule) { a.rules = append(a.rules, v...) }
+
+// Rules returns all rules in order.
+func (a *ACL) Rules() []Rule { return a.rules }
+
+// Authorize returns true if the first rule matching session, action
+// and topic allows access.
+func (a *ACL) Authorize(s Session, action Action, topic string) bool {
+	for _, r := range a.rules {
+		if r.Match(s, action, topic) {
+			return r.Allow
+		}
+	}
+	return false
+}
+
+// Rule grants or denies access for one user to a topic filter.
+type Rule struct {
+	Allow  bool
+	Action Action
+	User   string // * for any
+	Filter string // may contain %c and %u
+}
+
+// Match returns true if the rule applies to the given session,
+// action and topic. If the client ID or username cannot be
+// substituted into the filter, a deny rule matches any topic and an
+// allow rule none, i.e. it fails closed.
+func (r Rule) Match(s Session, action Action, topic string) bool {
+	if r.Action&action == 0 {
+		return false
+	}
+	if r.User != "*" && r.User != s.Username {
+		return false
+	}
+	if action == Connect {
+		return true
+	}
+	filter, ok := substitute(r.Filter, s)
+	if !ok {
+		return !r.Allow
+	}
+	if action == Subscribe {
+		return covers(filter, topic)
+	}
+	return matches(filter, topic)
+}
+
+func (r Rule) String() string {
+	access := "deny"
+	if r.Allow {
+		access = "allow"
+	}
+	return strings.TrimSpace(
+		fmt.Sprintf("%s %s %s %s", access, r.Action, r.User, r.Filter),
+	)
+}
+
+var (
+	ErrAccess = fmt.Errorf("access must be allow or deny")
+	ErrAction = fmt.Errorf("unknown action")
+	ErrFields = fmt.Errorf("wrong number of fields")
+	ErrFilter = fmt.Errorf("invalid filter")
+)
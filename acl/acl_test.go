@@ -0,0 +1,203 @@
+package acl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+const rules = `
+# access action    user  [filter]
+deny     connect   banned
+allow    connect   *
+
+allow    pubsub    *     clients/%c/#
+allow    subscribe *     users/%u/+
+allow    subscribe *     news/+
+deny     publish   guest #
+allow    publish   admin #
+allow    publish   *     public/#
+`
+
+func TestACL_Authorize(t *testing.T) {
+	acl, err := Parse(strings.NewReader(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		pink   = Session{ClientID: "pink", Username: "john"}
+		guest  = Session{ClientID: "g1", Username: "guest"}
+		admin  = Session{ClientID: "a1", Username: "admin"}
+		banned = Session{ClientID: "b1", Username: "banned"}
+		anon   = Session{ClientID: "x"}
+		evil   = Session{ClientID: "+", Username: "#"}
+	)
+	cases := []struct {
+		s      Session
+		action Action
+		topic  string
+		exp    bool
+	}{
+		{pink, Connect, "", true},
+		{anon, Connect, "", true},
+		{banned, Connect, "", false},
+
+		// %c substitution
+		{pink, Publish, "clients/pink/temp", true},
+		{pink, Publish, "clients/blue/temp", false},
+		{pink, Subscribe, "clients/pink/#", true},
+		{pink, Subscribe, "clients/+/temp", false},
+		{evil, Publish, "clients/blue/temp", false},
+
+		// %u substitution
+		{pink, Subscribe, "users/john/inbox", true},
+		{pink, Subscribe, "users/john/#", false},
+		{anon, Subscribe, "users//inbox", false},
+		{evil, Subscribe, "users/x/inbox", false},
+
+		// wildcards
+		{pink, Subscribe, "news/+", true},
+		{pink, Subscribe, "news/sport", true},
+		{pink, Subscribe, "news/#", false},
+		{pink, Subscribe, "$share/g1/news/sport", true},
+		{pink, Publish, "news/sport", false},
+		{pink, Publish, "public/a/b/c", true},
+		{pink, Publish, "public", true},
+
+		// user specific, first match decides
+		{guest, Publish, "public/a", false},
+		{admin, Publish, "anything/at/all", true},
+		{admin, Publish, "$SYS/broker", false},
+		{admin, Subscribe, "anything", false},
+	}
+	for _, c := range cases {
+		if got := acl.Authorize(c.s, c.action, c.topic); got != c.exp {
+			t.Errorf("%+v %v %q: got %v, expected %v",
+				c.s, c.action, c.topic, got, c.exp,
+			)
+		}
+	}
+}
+
+func TestACL_Authorize_denySubstitution(t *testing.T) {
+	acl, err := Parse(strings.NewReader(`
+deny  publish * clients/%c/secret
+deny  publish * private/%u/#
+allow publish * #
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		s     Session
+		topic string
+	}{
+		{Session{ClientID: "a/b", Username: "john"}, "clients/a/b/secret"},
+		{Session{ClientID: "x"}, "private//x"},
+	}
+	for _, c := range cases {
+		if acl.Authorize(c.s, Publish, c.topic) {
+			t.Errorf("%+v allowed to publish %q", c.s, c.topic)
+		}
+	}
+	if !acl.Authorize(Session{ClientID: "x", Username: "john"}, Publish, "private/x") {
+		t.Error("unrelated topic denied")
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]error{
+		"maybe connect *":            ErrAccess,
+		"allow":                      ErrFields,
+		"allow jump * a/b":           ErrAction,
+		"allow connect * a/b":        ErrFields,
+		"allow publish *":            ErrFields,
+		"allow publish * a/#/b":      ErrFilter,
+		"allow publish * a/b+":       ErrFilter,
+		"allow publish * a b c":      ErrFields,
+		"allow subscribe * a/b\n#ok": nil,
+	}
+	for text, exp := range cases {
+		_, err := Parse(strings.NewReader(text))
+		if !errors.Is(err, exp) {
+			t.Errorf("%q: got %v, expected %v", text, err, exp)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "acl.conf")
+	os.WriteFile(filename, []byte(rules), 0644)
+	acl, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := len(acl.Rules()); v != 8 {
+		t.Error("rules", v)
+	}
+	if v := acl.Rules()[2].String(); v != "allow pubsub * clients/%c/#" {
+		t.Error(v)
+	}
+
+	if _, err := Load("no-such-file"); err == nil {
+		t.Error("expected error")
+	}
+	os.WriteFile(filename, []byte("bad"), 0644)
+	if _, err := Load(filename); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var acl ACL
+	acl.Add(
+		Rule{Allow: false, Action: Connect, User: "banned"},
+		Rule{Allow: true, Action: Connect, User: "*"},
+		Rule{Allow: true, Action: PubSub, User: "*", Filter: "a/#"},
+	)
+
+	c := mq.NewConnect()
+	c.SetUsername("banned")
+	if v := CheckConnect(&acl, c); v != mq.NotAuthorized {
+		t.Error("CheckConnect", v)
+	}
+	c.SetUsername("john")
+	if v := CheckConnect(&acl, c); v != mq.Success {
+		t.Error("CheckConnect", v)
+	}
+	s := NewSession(c)
+
+	if v := CheckPublish(&acl, s, mq.Pub(1, "a/b", "")); v != mq.Success {
+		t.Error("CheckPublish", v)
+	}
+	if v := CheckPublish(&acl, s, mq.Pub(1, "b", "")); v != mq.NotAuthorized {
+		t.Error("CheckPublish", v)
+	}
+
+	sub := mq.NewSubscribe()
+	sub.AddFilters(
+		mq.NewTopicFilter("a/+", mq.OptQoS2),
+		mq.NewTopicFilter("b/+", mq.OptQoS1),
+		mq.NewTopicFilter("a/b", mq.OptQoS1|mq.OptNL),
+	)
+	exp := []mq.ReasonCode{mq.GrantedQoS2, mq.NotAuthorized, mq.GrantedQoS1}
+	if got := CheckSubscribe(&acl, s, sub); !reflect.DeepEqual(got, exp) {
+		t.Errorf("CheckSubscribe got %v, expected %v", got, exp)
+	}
+}
+
+func TestAction_String(t *testing.T) {
+	for name, a := range actionNames {
+		if v := a.String(); v != name {
+			t.Errorf("%v != %v", v, name)
+		}
+	}
+	if v := Action(0).String(); v != "Action(0)" {
+		t.Error(v)
+	}
+}
@@ -0,0 +1,93 @@
+package acl
+
+import (
+	"strings"
+)
+
+// validFilter returns false if wildcards are not used as whole
+// levels or # is not the last level, see 4.7.1 Topic wildcards.
+func validFilter(filter string) bool {
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#" && i != len(levels)-1:
+			return false
+		case level != "#" && level != "+" && strings.ContainsAny(level, "#+"):
+			return false
+		}
+	}
+	return true
+}
+
+// substitute replaces %c and %u with the client ID and username in
+// one pass. It returns false if a used value is empty or contains
+// characters that would change the meaning of the filter.
+func substitute(filter string, s Session) (string, bool) {
+	for k, v := range map[string]string{"%c": s.ClientID, "%u": s.Username} {
+		if strings.Contains(filter, k) && (v == "" || strings.ContainsAny(v, "/+#%")) {
+			return "", false
+		}
+	}
+	r := strings.NewReplacer("%c", s.ClientID, "%u", s.Username)
+	return r.Replace(filter), true
+}
+
+// matches returns true if the topic name matches the filter. Topics
+// starting with $ are not matched by a leading wildcard, see 4.7.2.
+func matches(filter, topic string) bool {
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return false
+	}
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	if strings.HasPrefix(topic, "$") && (f[0] == "+" || f[0] == "#") {
+		return false
+	}
+	for i, level := range f {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(t):
+			return false
+		case level != "+" && level != t[i]:
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// covers returns true if every topic matched by sub is also
+// matched by filter. Shared subscriptions are checked without the
+// $share/{ShareName} prefix.
+func covers(filter, sub string) bool {
+	if strings.HasPrefix(sub, "$share/") {
+		parts := strings.SplitN(sub, "/", 3)
+		if len(parts) != 3 {
+			return false
+		}
+		sub = parts[2]
+	}
+	if sub == "" || !validFilter(sub) {
+		return false
+	}
+	f := strings.Split(filter, "/")
+	s := strings.Split(sub, "/")
+	if strings.HasPrefix(sub, "$") && (f[0] == "+" || f[0] == "#") {
+		return false
+	}
+	for i, level := range f {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(s):
+			return false
+		case s[i] == "#":
+			return false
+		case level == "+":
+			continue
+		case level != s[i]:
+			return false
+		}
+	}
+	return len(f) == len(s)
+}
@@ -0,0 +1,80 @@
+package acl
+
+import "testing"
+
+func Test_matches(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		exp           bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/b", "a/b/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b/c", true},
+		{"#", "$SYS/a", false},
+		{"+/a", "$SYS/a", false},
+		{"$SYS/#", "$SYS/a", true},
+		{"+", "", false},
+		{"#", "a/+", false},
+	}
+	for _, c := range cases {
+		if got := matches(c.filter, c.topic); got != c.exp {
+			t.Errorf("matches(%q, %q) = %v", c.filter, c.topic, got)
+		}
+	}
+}
+
+func Test_covers(t *testing.T) {
+	cases := []struct {
+		filter, sub string
+		exp         bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/+", false},
+		{"a/+", "a/+", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/#", false},
+		{"a/#", "a/#", true},
+		{"a/#", "a/+/c", true},
+		{"a/#", "a", true},
+		{"#", "$SYS/#", false},
+		{"a/b/c", "a/b", false},
+		{"#", "a/#/b", false},
+		{"#", "$share/g/a/b", true},
+		{"#", "$share/g", false},
+		{"#", "", false},
+	}
+	for _, c := range cases {
+		if got := covers(c.filter, c.sub); got != c.exp {
+			t.Errorf("covers(%q, %q) = %v", c.filter, c.sub, got)
+		}
+	}
+}
+
+func Test_substitute(t *testing.T) {
+	pink := Session{ClientID: "pink", Username: "john"}
+	anon := Session{ClientID: "pink"}
+	cases := []struct {
+		s           Session
+		filter, exp string
+		ok          bool
+	}{
+		{pink, "a/%c/%u", "a/pink/john", true},
+		{pink, "a/b", "a/b", true},
+		{anon, "a/%u", "", false},
+		{anon, "a/%c", "a/pink", true},
+		{Session{ClientID: "%u", Username: "x/#"}, "a/%c", "", false},
+		{Session{ClientID: "a+b"}, "a/%c", "", false},
+	}
+	for _, c := range cases {
+		got, ok := substitute(c.filter, c.s)
+		if got != c.exp || ok != c.ok {
+			t.Errorf("substitute(%q) = %q, %v", c.filter, got, ok)
+		}
+	}
+}
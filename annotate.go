@@ -0,0 +1,273 @@
+package mq
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Annotate returns the location of every field in the wire format of
+// the given packet, in order.
+func Annotate(p Packet) []Span {
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	spans, _ := AnnotateBytes(buf.Bytes())
+	return spans
+}
+
+// AnnotateBytes returns the location of every field of the packet
+// in data, which must start with the fixed header. On malformed data
+// the spans found before the failing field are returned together
+// with an error naming the offset.
+func AnnotateBytes(data []byte) ([]Span, error) {
+	a := &annotator{data: data, end: len(data)}
+	a.add("FixedHeader", 0, 1)
+	remaining := a.vbint("RemainingLength", 0)
+	if a.err != nil {
+		return a.spans, fmt.Errorf("AnnotateBytes: %w", a.err)
+	}
+	if a.i+remaining > len(data) {
+		return a.spans, fmt.Errorf(
+			"AnnotateBytes: remaining length %v exceeds data: %w",
+			remaining, ErrMissingData,
+		)
+	}
+	a.end = a.i + remaining
+
+	fixed := data[0]
+	switch fixed & 0b1111_0000 {
+	case CONNECT:
+		a.str("ProtocolName")
+		a.add("ProtocolVersion", 0, 1)
+		var flags bits
+		if a.more() {
+			flags = bits(data[a.i])
+		}
+		a.add("Flags", 0, 1)
+		a.add("KeepAlive", 0, 2)
+		a.properties("PropertyLength")
+		a.str("ClientID")
+		if flags.Has(WillFlag) {
+			a.properties("WillPropertyLength")
+			a.str("WillTopic")
+			a.str("WillPayload")
+		}
+		if flags.Has(UsernameFlag) {
+			a.str("Username")
+		}
+		if flags.Has(PasswordFlag) {
+			a.str("Password")
+		}
+
+	case CONNACK:
+		a.add("Flags", 0, 1)
+		a.add("ReasonCode", 0, 1)
+		a.properties("PropertyLength")
+
+	case PUBLISH:
+		a.str("TopicName")
+		if bits(fixed).Has(QoS1) || bits(fixed).Has(QoS2) {
+			a.add("PacketID", 0, 2)
+		}
+		a.properties("PropertyLength")
+		if a.more() {
+			a.add("Payload", 0, a.end-a.i)
+		}
+
+	case PUBACK, PUBREC, PUBREL, PUBCOMP:
+		a.add("PacketID", 0, 2)
+		// reason code and properties are optional
+		if a.more() {
+			a.add("ReasonCode", 0, 1)
+		}
+		if a.more() {
+			a.properties("PropertyLength")
+		}
+
+	case SUBSCRIBE:
+		a.add("PacketID", 0, 2)
+		a.properties("PropertyLength")
+		for a.more() {
+			a.str("TopicFilter")
+			a.add("Options", 0, 1)
+		}
+
+	case UNSUBSCRIBE:
+		a.add("PacketID", 0, 2)
+		a.properties("PropertyLength")
+		for a.more() {
+			a.str("TopicFilter")
+		}
+
+	case SUBACK, UNSUBACK:
+		a.add("PacketID", 0, 2)
+		a.properties("PropertyLength")
+		for a.more() {
+			a.add("ReasonCode", 0, 1)
+		}
+
+	case PINGREQ, PINGRESP:
+
+	case DISCONNECT, AUTH:
+		if a.more() {
+			a.add("ReasonCode", 0, 1)
+		}
+		if a.more() {
+			a.properties("PropertyLength")
+		}
+
+	default:
+		if a.more() {
+			a.add("Data", 0, a.end-a.i)
+		}
+	}
+
+	if a.err == nil && a.i < a.end {
+		a.err = fmt.Errorf("offset %v: %v bytes after last field",
+			a.i, a.end-a.i,
+		)
+	}
+	if a.err != nil {
+		return a.spans, fmt.Errorf(
+			"AnnotateBytes %s: %w", typeNames[fixed&0b1111_0000], a.err,
+		)
+	}
+	return a.spans, nil
+}
+
+// Span locates one field in the wire format of a packet.
+type Span struct {
+	Offset int // from the start of the packet
+	Len    int
+	Name   string
+	Ident  Ident // set for properties, including Name
+}
+
+// String returns offset, length and name, e.g.
+//
+//	0002 5 TopicName
+func (s Span) String() string {
+	return fmt.Sprintf("%04x %v %s", s.Offset, s.Len, s.Name)
+}
+
+// ----------------------------------------
+
+// annotator walks the wire format and records spans until the first
+// error.
+type annotator struct {
+	data  []byte
+	i     int // current offset
+	end   int // end of packet
+	spans []Span
+	err   error
+}
+
+func (a *annotator) more() bool {
+	return a.err == nil && a.i < a.end
+}
+
+func (a *annotator) add(name string, id Ident, width int) {
+	if a.err != nil {
+		return
+	}
+	if a.i+width > a.end {
+		a.err = fmt.Errorf("offset %v %s: %w", a.i, name, ErrMissingData)
+		return
+	}
+	a.spans = append(a.spans, Span{
+		Offset: a.i, Len: width, Name: name, Ident: id,
+	})
+	a.i += width
+}
+
+// vbint adds a variable byte integer and returns its value.
+func (a *annotator) vbint(name string, id Ident) int {
+	if a.err != nil {
+		return 0
+	}
+	var value, multiplier int = 0, 1
+	start := a.i
+	if id > 0 {
+		start++
+	}
+	for j := start; j < a.end; j++ {
+		if j-start > 3 {
+			a.err = fmt.Errorf("offset %v %s: size exceeded", a.i, name)
+			return 0
+		}
+		value += int(a.data[j]&0x7f) * multiplier
+		if a.data[j]&0x80 == 0 {
+			a.add(name, id, j+1-a.i)
+			return value
+		}
+		multiplier *= 128
+	}
+	a.err = fmt.Errorf("offset %v %s: %w", a.i, name, ErrMissingData)
+	return 0
+}
+
+// str adds a two byte length prefixed string or binary data.
+func (a *annotator) str(name string) {
+	a.add(name, 0, a.strWidth(a.i, name))
+}
+
+func (a *annotator) strWidth(i int, name string) int {
+	if a.err != nil {
+		return 0
+	}
+	if i+2 > a.end {
+		a.err = fmt.Errorf("offset %v %s: %w", i, name, ErrMissingData)
+		return 0
+	}
+	return 2 + (int(a.data[i])<<8 | int(a.data[i+1]))
+}
+
+// properties adds the property length followed by one span per
+// property.
+func (a *annotator) properties(name string) {
+	size := a.vbint(name, 0)
+	if a.err != nil {
+		return
+	}
+	end := a.i + size
+	if end > a.end {
+		a.err = fmt.Errorf("offset %v %s %v: %w", a.i, name, size, ErrMissingData)
+		return
+	}
+	for a.err == nil && a.i < end {
+		id := Ident(a.data[a.i])
+		switch id {
+		case PayloadFormatIndicator, RequestProblemInfo,
+			RequestResponseInfo, MaxQoS, RetainAvailable,
+			WildcardSubAvailable, SubIDsAvailable, SharedSubAvailable:
+			a.add(id.String(), id, 2)
+
+		case ServerKeepAlive, ReceiveMax, TopicAliasMax, TopicAlias:
+			a.add(id.String(), id, 3)
+
+		case MessageExpiryInterval, SessionExpiryInterval,
+			WillDelayInterval, MaxPacketSize:
+			a.add(id.String(), id, 5)
+
+		case SubscriptionID:
+			a.vbint(id.String(), id)
+
+		case ContentType, ResponseTopic, CorrelationData,
+			AssignedClientID, AuthMethod, AuthData,
+			ResponseInformation, ServerReference, ReasonString:
+			a.add(id.String(), id, 1+a.strWidth(a.i+1, id.String()))
+
+		case UserProperty:
+			key := a.strWidth(a.i+1, "UserProperty key")
+			value := a.strWidth(a.i+1+key, "UserProperty value")
+			a.add(id.String(), id, 1+key+value)
+
+		default:
+			a.err = fmt.Errorf(
+				"offset %v: unknown property id 0x%02x", a.i, byte(id),
+			)
+		}
+	}
+	if a.err == nil && a.i != end {
+		a.err = fmt.Errorf("offset %v %s: properties exceed length", a.i, name)
+	}
+}
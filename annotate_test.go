@@ -0,0 +1,231 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ExampleAnnotate() {
+	p := Pub(1, "a/b", "gopher")
+	p.SetPacketID(9)
+	p.SetContentType("text/plain")
+
+	for _, s := range Annotate(p) {
+		fmt.Println(s)
+	}
+	// output:
+	// 0000 1 FixedHeader
+	// 0001 1 RemainingLength
+	// 0002 5 TopicName
+	// 0007 2 PacketID
+	// 0009 1 PropertyLength
+	// 000a 13 ContentType
+	// 0017 6 Payload
+}
+
+func TestAnnotate(t *testing.T) {
+	for _, p := range annotatedPackets() {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		data := buf.Bytes()
+
+		spans, err := AnnotateBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// spans must cover all data without gaps
+		var i int
+		for _, s := range spans {
+			if s.Offset != i {
+				t.Fatalf("%v: gap before %v", p, s)
+			}
+			if s.Len == 0 {
+				t.Errorf("%v: empty %v", p, s)
+			}
+			if s.Ident > 0 && (s.Name != s.Ident.String() || Ident(data[s.Offset]) != s.Ident) {
+				t.Errorf("%v: property %v", p, s)
+			}
+			i += s.Len
+		}
+		if i != len(data) {
+			t.Errorf("%v: spans cover %v of %v bytes\n%v", p, i, len(data), spans)
+		}
+	}
+}
+
+func TestAnnotate_fields(t *testing.T) {
+	c := NewConnect()
+	c.SetClientID("pink")
+	c.SetUsername("gopher")
+	c.SetPassword([]byte("cute"))
+	c.SetWill(Pub(1, "client/gone", "pink"))
+	c.SetWillDelayInterval(3)
+
+	exp := "FixedHeader RemainingLength ProtocolName ProtocolVersion Flags KeepAlive PropertyLength ClientID WillPropertyLength WillDelayInterval WillTopic WillPayload Username Password"
+	if got := names(Annotate(c)); got != exp {
+		t.Errorf("\ngot: %s\nexp: %s", got, exp)
+	}
+
+	s := NewSubscribe()
+	s.SetPacketID(1)
+	s.AddFilters(NewTopicFilter("a/#", OptQoS1), NewTopicFilter("b", OptNL))
+	exp = "FixedHeader RemainingLength PacketID PropertyLength TopicFilter Options TopicFilter Options"
+	if got := names(Annotate(s)); got != exp {
+		t.Errorf("\ngot: %s\nexp: %s", got, exp)
+	}
+}
+
+func TestAnnotateBytes_malformed(t *testing.T) {
+	cases := map[string]struct {
+		data []byte
+		exp  string
+	}{
+		"empty":           {nil, "offset 0 FixedHeader"},
+		"remaining":       {[]byte{0x30, 0x80}, "offset 1 RemainingLength"},
+		"remaining size":  {[]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}, "size exceeded"},
+		"exceeds":         {[]byte{0x30, 0x05, 0x00}, "remaining length 5 exceeds data"},
+		"property":        {[]byte{0x30, 0x07, 0, 1, 'a', 3, 0x67, 0, 0}, "offset 6: unknown property id 0x67"},
+		"property length": {[]byte{0x30, 0x05, 0, 1, 'a', 9, 0}, "offset 6 PropertyLength 9"},
+		"property string": {[]byte{0xe0, 0x05, 0, 3, 0x1f, 0, 9}, "offset 4 ReasonString: missing data"},
+		"property exceed": {[]byte{0xe0, 0x05, 0, 1, 0x01, 1, 0}, "properties exceed length"},
+		"user property":   {[]byte{0xe0, 0x06, 0, 4, 0x26, 0, 0, 0}, "UserProperty value"},
+		"string":          {[]byte{0x30, 0x02, 0, 5}, "offset 2 TopicName"},
+		"trailing":        {[]byte{0xc0, 0x01, 0}, "offset 2: 1 bytes after last field"},
+		"subscription id": {[]byte{0x30, 0x07, 0, 1, 'a', 3, 0x0b, 0x80, 0x80}, "offset 6 SubscriptionID"},
+	}
+	for name, c := range cases {
+		spans, err := AnnotateBytes(c.data)
+		if err == nil || !strings.Contains(err.Error(), c.exp) {
+			t.Errorf("%s: expected %q in %v\n%v", name, c.exp, err, spans)
+		}
+	}
+
+	_, err := AnnotateBytes([]byte{0x30, 0x02, 0, 5})
+	if !errors.Is(err, ErrMissingData) {
+		t.Error(err)
+	}
+}
+
+func TestAnnotateBytes_undefined(t *testing.T) {
+	spans, err := AnnotateBytes([]byte{0x00, 0x02, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(spans); got != "FixedHeader RemainingLength Data" {
+		t.Error(got)
+	}
+}
+
+func names(spans []Span) string {
+	v := make([]string, len(spans))
+	for i, s := range spans {
+		v[i] = s.Name
+	}
+	return strings.Join(v, " ")
+}
+
+// annotatedPackets returns packets of all types with as many fields
+// set as possible.
+func annotatedPackets() []Packet {
+	c := NewConnect()
+	c.SetClientID("pink")
+	c.SetKeepAlive(30)
+	c.SetUsername("gopher")
+	c.SetPassword([]byte("cute"))
+	c.SetSessionExpiryInterval(60)
+	c.SetReceiveMax(10)
+	c.SetMaxPacketSize(1024)
+	c.SetTopicAliasMax(5)
+	c.SetRequestResponseInfo(true)
+	c.SetRequestProblemInfo(false)
+	c.SetAuthMethod("plain")
+	c.SetAuthData([]byte("secret"))
+	c.AddUserProp("color", "red")
+	will := Pub(2, "client/gone", "pink")
+	will.SetPayloadFormat(true)
+	will.SetMessageExpiryInterval(9)
+	will.SetContentType("text/plain")
+	will.SetResponseTopic("a/b")
+	will.SetCorrelationData([]byte("1"))
+	will.AddUserProp("shape", "round")
+	c.SetWill(will)
+	c.SetWillDelayInterval(3)
+
+	ack := NewConnAck()
+	ack.SetSessionPresent(true)
+	ack.SetSessionExpiryInterval(60)
+	ack.SetReceiveMax(10)
+	ack.SetMaxQoS(1)
+	ack.SetRetainAvailable(true)
+	ack.SetMaxPacketSize(1024)
+	ack.SetAssignedClientID("x")
+	ack.SetTopicAliasMax(5)
+	ack.SetReasonString("ok")
+	ack.SetWildcardSubAvailable(true)
+	ack.SetSubIdentifiersAvailable(true)
+	ack.SetSharedSubAvailable(true)
+	ack.SetServerKeepAlive(30)
+	ack.SetResponseInformation("info")
+	ack.SetServerReference("other")
+	ack.SetAuthMethod("plain")
+	ack.SetAuthData([]byte("secret"))
+	ack.AddUserProp("color", "red")
+
+	pub := Pub(1, "a/b", "gopher")
+	pub.SetPacketID(1)
+	pub.SetTopicAlias(3)
+	pub.AddSubscriptionID(300)
+	pub.AddUserProp("color", "red")
+
+	puback := NewPubAck()
+	puback.SetPacketID(1)
+	puback.SetReasonCode(NotAuthorized)
+	puback.SetReasonString("no")
+
+	pubrec := NewPubRec()
+	pubrec.SetPacketID(2)
+	pubrel := NewPubRel()
+	pubrel.SetPacketID(3)
+	pubrel.SetReasonCode(PacketIdentifierNotFound)
+	pubcomp := NewPubComp()
+	pubcomp.SetPacketID(4)
+
+	sub := NewSubscribe()
+	sub.SetPacketID(5)
+	sub.SetSubscriptionID(7)
+	sub.AddFilters(NewTopicFilter("a/#", OptQoS1))
+
+	suback := NewSubAck()
+	suback.SetPacketID(5)
+	suback.SetReasonString("partial")
+	suback.AddReasonCode(GrantedQoS1)
+	suback.AddReasonCode(NotAuthorized)
+
+	unsub := NewUnsubscribe()
+	unsub.SetPacketID(6)
+	unsub.AddFilter("a/#")
+	unsub.AddFilter("b")
+
+	unsuback := NewUnsubAck()
+	unsuback.SetPacketID(6)
+	unsuback.AddReasonCode(Success)
+
+	dis := NewDisconnect()
+	dis.SetReasonCode(ServerShuttingDown)
+	dis.AddUserProp("color", "red")
+
+	auth := NewAuth()
+	auth.SetReasonCode(ContinueAuth)
+	auth.SetAuthMethod("plain")
+	auth.SetAuthData([]byte("secret"))
+
+	return []Packet{
+		c, NewConnect(), ack, NewConnAck(), pub, Pub(0, "a", ""),
+		puback, pubrec, pubrel, pubcomp,
+		sub, suback, unsub, unsuback,
+		NewPingReq(), NewPingResp(), dis, NewDisconnect(), auth, NewAuth(),
+	}
+}
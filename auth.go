@@ -30,6 +30,24 @@ func (p *Auth) AuthData() []byte     { return []byte(p.authData) }
 func (p *Auth) SetReasonString(v string) { p.reasonString = wstring(v) }
 func (p *Auth) ReasonString() string     { return string(p.reasonString) }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *Auth) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	if err := wellFormedReason(p, AUTH); err != nil {
+		return err
+	}
+	// reason code and properties may be omitted on success
+	omitted := p.ReasonCode() == Success && len(p.authData) == 0 &&
+		len(p.reasonString) == 0 && len(p.UserProperties) == 0
+	if len(p.authMethod) == 0 && !omitted {
+		return newMalformed(p, "auth method", "empty")
+	}
+	return nil
+}
+
 func (p *Auth) String() string {
 	return fmt.Sprintf("%s %v bytes",
 		firstByte(p.fixed).String(),
@@ -37,8 +55,8 @@ func (p *Auth) String() string {
 	)
 }
 
-func (p *Auth) dump(w io.Writer) {
-	fmt.Fprintf(w, "AuthData: %q\n", string(p.AuthData()))
+func (p *Auth) dump(w io.Writer, d *Dumper) {
+	fmt.Fprintf(w, "AuthData: %q\n", d.secret(p.AuthData()))
 	fmt.Fprintf(w, "AuthMethod: %q\n", p.AuthMethod())
 	fmt.Fprintf(w, "ReasonCode: %v\n", p.ReasonCode())
 	fmt.Fprintf(w, "ReasonString: %q\n", p.ReasonString())
@@ -53,6 +71,10 @@ func (p *Auth) WriteTo(w io.Writer) (int64, error) {
 }
 
 func (p *Auth) UnmarshalBinary(data []byte) error {
+	// reason code and properties may be omitted on success
+	if len(data) == 0 {
+		return nil
+	}
 	b := &buffer{data: data}
 	b.get(&p.reasonCode)
 	b.getAny(p.propertyMap(), p.appendUserProperty)
@@ -0,0 +1,254 @@
+package mq
+
+import (
+	"fmt"
+)
+
+// Authenticator implements one side of an enhanced authentication
+// method, see 4.12 Enhanced authentication
+// https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_Toc3901256
+type Authenticator interface {
+	// AuthMethod returns the name of the authentication method,
+	// e.g. SCRAM-SHA-256.
+	AuthMethod() string
+
+	// Step is called with the authentication data from the other
+	// side, nil for the first client step. It returns data to send
+	// back and done when no more data is expected.
+	Step(data []byte) (next []byte, done bool, err error)
+}
+
+// NewClientAuth returns a client side driver of the enhanced
+// authentication exchange. newAuth is called at the start of each
+// exchange.
+func NewClientAuth(newAuth func() Authenticator) *ClientAuth {
+	return &ClientAuth{newAuth: newAuth}
+}
+
+type ClientAuth struct {
+	newAuth func() Authenticator
+	current Authenticator
+	done    bool
+}
+
+// Start begins an exchange by setting the authentication method
+// and initial data of the connect packet.
+func (c *ClientAuth) Start(p *Connect) error {
+	data, err := c.begin()
+	if err != nil {
+		return err
+	}
+	p.SetAuthMethod(c.current.AuthMethod())
+	p.SetAuthData(data)
+	return nil
+}
+
+// ReAuthenticate begins a new exchange on an established connection.
+func (c *ClientAuth) ReAuthenticate() (*Auth, error) {
+	data, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	return c.auth(ReAuthenticate, data), nil
+}
+
+func (c *ClientAuth) begin() ([]byte, error) {
+	c.current = c.newAuth()
+	data, done, err := c.current.Step(nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.current.AuthMethod(), err)
+	}
+	c.done = done
+	return data, nil
+}
+
+// Handle steps the exchange with an incoming Auth or ConnAck
+// packet. The returned packet, if not nil, should be sent to the
+// server. A non nil error means the exchange failed and the
+// connection should be closed after sending the returned packet.
+func (c *ClientAuth) Handle(p Packet) (Packet, error) {
+	if c.current == nil {
+		return c.fail(ProtocolError, ErrNoExchange)
+	}
+	switch p := p.(type) {
+	case *Auth:
+		if p.AuthMethod() != c.current.AuthMethod() {
+			return c.fail(BadAuthenticationMethod, badMethod(p.AuthMethod()))
+		}
+		switch p.ReasonCode() {
+		case ContinueAuth:
+			return c.step(p.AuthData())
+		case Success: // re-authentication complete
+			return c.finish(p.AuthData())
+		}
+		return c.fail(ProtocolError, fmt.Errorf("unexpected %v", p.ReasonCode()))
+
+	case *ConnAck:
+		if code := p.ReasonCode(); code >= 0x80 {
+			c.current = nil
+			return nil, fmt.Errorf("%w: %v", ErrAuthFailed, code)
+		}
+		if p.AuthMethod() != c.current.AuthMethod() {
+			return c.fail(BadAuthenticationMethod, badMethod(p.AuthMethod()))
+		}
+		return c.finish(p.AuthData())
+	}
+	return c.fail(ProtocolError, fmt.Errorf("unexpected %v", p))
+}
+
+func (c *ClientAuth) step(data []byte) (Packet, error) {
+	next, done, err := c.current.Step(data)
+	if err != nil {
+		return c.fail(NotAuthorized, err)
+	}
+	c.done = done
+	return c.auth(ContinueAuth, next), nil
+}
+
+// finish handles the final data from the server which completes
+// the exchange.
+func (c *ClientAuth) finish(data []byte) (Packet, error) {
+	if !c.done || len(data) > 0 {
+		if _, done, err := c.current.Step(data); err != nil {
+			return c.fail(NotAuthorized, err)
+		} else if !done {
+			return c.fail(NotAuthorized, ErrIncomplete)
+		}
+	}
+	c.current = nil
+	return nil, nil
+}
+
+func (c *ClientAuth) auth(code ReasonCode, data []byte) *Auth {
+	a := NewAuth()
+	a.SetReasonCode(code)
+	a.SetAuthMethod(c.current.AuthMethod())
+	a.SetAuthData(data)
+	return a
+}
+
+func (c *ClientAuth) fail(code ReasonCode, err error) (Packet, error) {
+	c.current = nil
+	d := NewDisconnect()
+	d.SetReasonCode(code)
+	return d, fmt.Errorf("%w: %w", ErrAuthFailed, err)
+}
+
+// ----------------------------------------
+
+// NewServerAuth returns a server side driver of the enhanced
+// authentication exchange for one connection. newAuth is called at
+// the start of each exchange.
+func NewServerAuth(newAuth func() Authenticator) *ServerAuth {
+	return &ServerAuth{newAuth: newAuth}
+}
+
+type ServerAuth struct {
+	newAuth   func() Authenticator
+	current   Authenticator
+	connected bool
+}
+
+// Handle steps the exchange with an incoming Connect or Auth
+// packet. The returned packet is an Auth packet if the exchange
+// continues, a ConnAck when a connect exchange completes or a
+// Disconnect if re-authentication fails. A non nil error means the
+// exchange failed and the connection should be closed after sending
+// the returned packet.
+func (s *ServerAuth) Handle(p Packet) (Packet, error) {
+	switch p := p.(type) {
+	case *Connect:
+		if s.connected {
+			return s.fail(ProtocolError, fmt.Errorf("second %v", p))
+		}
+		return s.begin(p.AuthMethod(), p.AuthData())
+
+	case *Auth:
+		if !s.connected && s.current == nil {
+			return s.fail(ProtocolError, ErrNoExchange)
+		}
+		switch p.ReasonCode() {
+		case ReAuthenticate:
+			if !s.connected || s.current != nil {
+				return s.fail(ProtocolError, fmt.Errorf("unexpected %v", p.ReasonCode()))
+			}
+			return s.begin(p.AuthMethod(), p.AuthData())
+
+		case ContinueAuth:
+			if s.current == nil {
+				return s.fail(ProtocolError, ErrNoExchange)
+			}
+			if p.AuthMethod() != s.current.AuthMethod() {
+				return s.fail(BadAuthenticationMethod, badMethod(p.AuthMethod()))
+			}
+			return s.step(p.AuthData())
+		}
+		return s.fail(ProtocolError, fmt.Errorf("unexpected %v", p.ReasonCode()))
+	}
+	return s.fail(ProtocolError, fmt.Errorf("unexpected %v", p))
+}
+
+// Connected returns true once a connect exchange has completed
+// successfully.
+func (s *ServerAuth) Connected() bool { return s.connected }
+
+func (s *ServerAuth) begin(method string, data []byte) (Packet, error) {
+	s.current = s.newAuth()
+	if method != s.current.AuthMethod() {
+		return s.fail(BadAuthenticationMethod, badMethod(method))
+	}
+	return s.step(data)
+}
+
+func (s *ServerAuth) step(data []byte) (Packet, error) {
+	next, done, err := s.current.Step(data)
+	if err != nil {
+		return s.fail(NotAuthorized, err)
+	}
+	if !done {
+		return s.auth(ContinueAuth, next), nil
+	}
+	defer func() { s.current = nil }()
+	if s.connected { // re-authentication
+		return s.auth(Success, next), nil
+	}
+	s.connected = true
+	a := NewConnAck()
+	a.SetAuthMethod(s.current.AuthMethod())
+	a.SetAuthData(next)
+	return a, nil
+}
+
+func (s *ServerAuth) auth(code ReasonCode, data []byte) *Auth {
+	a := NewAuth()
+	a.SetReasonCode(code)
+	a.SetAuthMethod(s.current.AuthMethod())
+	a.SetAuthData(data)
+	return a
+}
+
+// fail returns a ConnAck during connect or a Disconnect once
+// connected.
+func (s *ServerAuth) fail(code ReasonCode, err error) (Packet, error) {
+	s.current = nil
+	err = fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	if s.connected {
+		d := NewDisconnect()
+		d.SetReasonCode(code)
+		return d, err
+	}
+	a := NewConnAck()
+	a.SetReasonCode(code)
+	return a, err
+}
+
+func badMethod(v string) error {
+	return fmt.Errorf("%w %q", ErrBadAuthMethod, v)
+}
+
+var (
+	ErrAuthFailed    = fmt.Errorf("authentication failed")
+	ErrBadAuthMethod = fmt.Errorf("bad authentication method")
+	ErrNoExchange    = fmt.Errorf("no authentication exchange")
+	ErrIncomplete    = fmt.Errorf("incomplete authentication exchange")
+)
@@ -0,0 +1,242 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClientAuth_connect(t *testing.T) {
+	client, server := newTestAuthPair("TEST", "TEST", "secret")
+
+	p := NewConnect()
+	if err := client.Start(p); err != nil {
+		t.Fatal(err)
+	}
+	exchange(t, client, server, p)
+	if !server.Connected() {
+		t.Error("server not connected")
+	}
+
+	// re-authentication mid session
+	a, err := client.ReAuthenticate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := exchange(t, client, server, a)
+	if a, ok := last.(*Auth); !ok || a.ReasonCode() != Success {
+		t.Error("expected Auth Success, got", last)
+	}
+}
+
+func TestServerAuth_badMethod(t *testing.T) {
+	client, server := newTestAuthPair("TEST", "OTHER", "secret")
+	p := NewConnect()
+	client.Start(p)
+
+	got, err := server.Handle(roundtrip(t, p))
+	if !errors.Is(err, ErrBadAuthMethod) {
+		t.Error("expected ErrBadAuthMethod, got", err)
+	}
+	expReason(t, got, BadAuthenticationMethod)
+}
+
+func TestServerAuth_notAuthorized(t *testing.T) {
+	client, server := newTestAuthPair("TEST", "TEST", "secret")
+	client.newAuth = func() Authenticator {
+		return &testAuth{method: "TEST", secret: "wrong"}
+	}
+	p := NewConnect()
+	client.Start(p)
+
+	got, err := server.Handle(roundtrip(t, p))
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Error("expected ErrAuthFailed, got", err)
+	}
+	expReason(t, got, NotAuthorized)
+	// and the client
+	if _, err := client.Handle(roundtrip(t, got)); err == nil {
+		t.Error("expected client error")
+	}
+}
+
+func TestServerAuth_reAuthenticateFails(t *testing.T) {
+	client, server := newTestAuthPair("TEST", "TEST", "secret")
+	p := NewConnect()
+	client.Start(p)
+	exchange(t, client, server, p)
+
+	a, _ := client.ReAuthenticate()
+	a.SetAuthMethod("OTHER")
+	got, err := server.Handle(roundtrip(t, a))
+	if err == nil {
+		t.Error("expected error")
+	}
+	if _, ok := got.(*Disconnect); !ok {
+		t.Error("expected Disconnect, got", got)
+	}
+	expReason(t, got, BadAuthenticationMethod)
+}
+
+func TestServerAuth_protocolError(t *testing.T) {
+	cont := NewAuth()
+	cont.SetReasonCode(ContinueAuth)
+	reauth := NewAuth()
+	reauth.SetReasonCode(ReAuthenticate)
+
+	cases := []Packet{
+		cont,         // no exchange started
+		reauth,       // not connected
+		NewPublish(), // not part of exchange
+	}
+	for _, p := range cases {
+		_, server := newTestAuthPair("TEST", "TEST", "secret")
+		got, err := server.Handle(p)
+		if err == nil {
+			t.Errorf("%v: expected error", p)
+		}
+		expReason(t, got, ProtocolError)
+	}
+
+	// second connect
+	client, server := newTestAuthPair("TEST", "TEST", "secret")
+	p := NewConnect()
+	client.Start(p)
+	exchange(t, client, server, p)
+	got, _ := server.Handle(p)
+	expReason(t, got, ProtocolError)
+}
+
+func TestClientAuth_Handle(t *testing.T) {
+	client, _ := newTestAuthPair("TEST", "TEST", "secret")
+	if got, err := client.Handle(NewAuth()); err == nil {
+		t.Error("expected error without exchange")
+	} else {
+		expReason(t, got, ProtocolError)
+	}
+
+	client.Start(NewConnect())
+	a := NewAuth()
+	a.SetReasonCode(ContinueAuth)
+	a.SetAuthMethod("OTHER")
+	got, err := client.Handle(a)
+	if !errors.Is(err, ErrBadAuthMethod) {
+		t.Error("expected ErrBadAuthMethod, got", err)
+	}
+	expReason(t, got, BadAuthenticationMethod)
+
+	client.Start(NewConnect())
+	a.SetAuthMethod("TEST")
+	a.SetReasonCode(ReAuthenticate)
+	if _, err := client.Handle(a); err == nil {
+		t.Error("expected error on unexpected reason code")
+	}
+
+	client.Start(NewConnect())
+	if _, err := client.Handle(NewPublish()); err == nil {
+		t.Error("expected error on unexpected packet")
+	}
+
+	// server completes before client
+	client.Start(NewConnect())
+	ack := NewConnAck()
+	ack.SetAuthMethod("TEST")
+	if _, err := client.Handle(ack); !errors.Is(err, ErrAuthFailed) {
+		t.Error("expected ErrAuthFailed, got", err)
+	}
+}
+
+// ----------------------------------------
+
+// exchange passes packets between client and server until no more
+// packets are returned. Returns the last non nil packet.
+func exchange(t *testing.T, client *ClientAuth, server *ServerAuth, p Packet) Packet {
+	t.Helper()
+	last := p
+	for i := 0; p != nil; i++ {
+		var err error
+		if i%2 == 0 {
+			p, err = server.Handle(roundtrip(t, p))
+		} else {
+			p, err = client.Handle(roundtrip(t, p))
+		}
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if p != nil {
+			last = p
+		}
+	}
+	return last
+}
+
+// roundtrip returns p written and read again. Fails if the read
+// packet is written differently. Undefined packets, which cannot be
+// written, are returned as is.
+func roundtrip(t *testing.T, p Packet) Packet {
+	t.Helper()
+	if _, ok := p.(*Undefined); ok {
+		return p
+	}
+	first := wire(p)
+	got, err := ReadPacket(bytes.NewReader(first))
+	if err != nil {
+		t.Fatalf("%v\n% x", err, first)
+	}
+	if second := wire(got); !bytes.Equal(first, second) {
+		t.Fatalf("round trip differs\n% x\n% x", first, second)
+	}
+	return got
+}
+
+func expReason(t *testing.T, p Packet, exp ReasonCode) {
+	t.Helper()
+	r, ok := p.(HasReason)
+	if !ok {
+		t.Fatalf("%T has no reason code", p)
+	}
+	if v := r.ReasonCode(); v != exp {
+		t.Errorf("got %v, expected %v", v, exp)
+	}
+}
+
+func newTestAuthPair(cm, sm, secret string) (*ClientAuth, *ServerAuth) {
+	client := NewClientAuth(func() Authenticator {
+		return &testAuth{method: cm, secret: secret}
+	})
+	server := NewServerAuth(func() Authenticator {
+		return &testAuth{method: sm, secret: secret, server: true}
+	})
+	return client, server
+}
+
+// testAuth implements a challenge response method where the client
+// first sends the secret, then the challenge with the secret.
+type testAuth struct {
+	method string
+	secret string
+	server bool
+	step   int
+}
+
+func (a *testAuth) AuthMethod() string { return a.method }
+
+func (a *testAuth) Step(data []byte) ([]byte, bool, error) {
+	a.step++
+	v := string(data)
+	switch {
+	case !a.server && a.step == 1:
+		return []byte(a.secret), false, nil
+	case !a.server && a.step == 2:
+		return []byte(v + a.secret), false, nil
+	case !a.server && a.step == 3 && v == "ok":
+		return nil, true, nil
+
+	case a.server && a.step == 1 && v == a.secret:
+		return []byte("challenge"), false, nil
+	case a.server && a.step == 2 && v == "challenge"+a.secret:
+		return []byte("ok"), true, nil
+	}
+	return nil, false, fmt.Errorf("step %v: unexpected %q", a.step, v)
+}
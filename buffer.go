@@ -55,7 +55,7 @@ func (b *buffer) getAny(fields map[Ident]func() wireType, addProp func(UserProp)
 			}
 
 		default:
-			b.err = fmt.Errorf("unknown property id 0x%02x", id)
+			b.err = fmt.Errorf("unknown property id 0x%02x", byte(id))
 		}
 	}
 }
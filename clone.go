@@ -0,0 +1,233 @@
+package mq
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+)
+
+// Clone methods return copies which can be modified without affecting
+// the original, e.g. when sending one publish packet to many
+// subscribers. Byte values, such as the payload, are shared as they
+// are replaced, never modified, by setters.
+
+func (p *Connect) Clone() *Connect {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	if p.will != nil {
+		c.will = p.will.Clone()
+	}
+	return &c
+}
+
+func (p *ConnAck) Clone() *ConnAck {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *Publish) Clone() *Publish {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	c.subscriptionIDs = append([]uint32(nil), p.subscriptionIDs...)
+	return &c
+}
+
+func (p *PubAck) Clone() *PubAck {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *PubRec) Clone() *PubRec {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *PubRel) Clone() *PubRel {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *PubComp) Clone() *PubComp {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *Subscribe) Clone() *Subscribe {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	if p.subscriptionID != nil {
+		v := *p.subscriptionID
+		c.subscriptionID = &v
+	}
+	c.filters = append([]TopicFilter(nil), p.filters...)
+	return &c
+}
+
+func (p *SubAck) Clone() *SubAck {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	c.reasonCodes = append([]uint8(nil), p.reasonCodes...)
+	return &c
+}
+
+func (p *Unsubscribe) Clone() *Unsubscribe {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	c.filters = append([]wstring(nil), p.filters...)
+	return &c
+}
+
+func (p *UnsubAck) Clone() *UnsubAck {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	c.reasonCodes = append([]uint8(nil), p.reasonCodes...)
+	return &c
+}
+
+func (p *PingReq) Clone() *PingReq {
+	c := *p
+	return &c
+}
+
+func (p *PingResp) Clone() *PingResp {
+	c := *p
+	return &c
+}
+
+func (p *Disconnect) Clone() *Disconnect {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *Auth) Clone() *Auth {
+	c := *p
+	c.UserProperties = p.UserProperties.clone()
+	return &c
+}
+
+func (p *Undefined) Clone() *Undefined {
+	c := *p
+	c.data = append([]byte(nil), p.data...)
+	return &c
+}
+
+func (p UserProperties) clone() UserProperties {
+	return append(UserProperties(nil), p...)
+}
+
+// ----------------------------------------
+
+// Equal returns true if a and b are of the same type with the same
+// content. The order of user properties and subscription identifiers
+// is ignored and absent values equal empty ones, e.g. a nil and an
+// empty correlation data, or their default for ConnAck capabilities.
+// Payloads of PublishStream are not read,
+// only their size compared.
+func Equal(a, b Packet) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	switch a := a.(type) {
+	case *PublishStream:
+		b := b.(*PublishStream)
+		return a.PayloadSize() == b.PayloadSize() && Equal(a.Publish, b.Publish)
+
+	case *Undefined:
+		b := b.(*Undefined)
+		return a.fixed == b.fixed && bytes.Equal(a.data, b.data)
+	}
+	return bytes.Equal(canonical(a), canonical(b))
+}
+
+// canonical returns the wire format of p with user properties and
+// subscription identifiers sorted and ConnAck capabilities with
+// default values omitted.
+func canonical(p Packet) []byte {
+	c := clonePacket(p)
+	if c == nil {
+		return wire(p)
+	}
+	if v, ok := c.(interface{ userProperties() *UserProperties }); ok {
+		v.userProperties().sort()
+	}
+	switch c := c.(type) {
+	case *Connect:
+		if c.will != nil {
+			c.will.UserProperties.sort()
+		}
+	case *ConnAck:
+		c.omitDefaults()
+	case *Publish:
+		sort.Slice(c.subscriptionIDs, func(i, j int) bool {
+			return c.subscriptionIDs[i] < c.subscriptionIDs[j]
+		})
+	}
+	return wire(c)
+}
+
+// clonePacket returns a clone of p, nil for unknown types.
+func clonePacket(p Packet) Packet {
+	switch p := p.(type) {
+	case *Connect:
+		return p.Clone()
+	case *ConnAck:
+		return p.Clone()
+	case *Publish:
+		return p.Clone()
+	case *PublishStream:
+		return p.Clone()
+	case *PubAck:
+		return p.Clone()
+	case *PubRec:
+		return p.Clone()
+	case *PubRel:
+		return p.Clone()
+	case *PubComp:
+		return p.Clone()
+	case *Subscribe:
+		return p.Clone()
+	case *SubAck:
+		return p.Clone()
+	case *Unsubscribe:
+		return p.Clone()
+	case *UnsubAck:
+		return p.Clone()
+	case *PingReq:
+		return p.Clone()
+	case *PingResp:
+		return p.Clone()
+	case *Disconnect:
+		return p.Clone()
+	case *Auth:
+		return p.Clone()
+	case *Undefined:
+		return p.Clone()
+	}
+	return nil
+}
+
+func wire(p Packet) []byte {
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+func (p *UserProperties) userProperties() *UserProperties { return p }
+
+func (p UserProperties) sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		if p[i][0] != p[j][0] {
+			return p[i][0] < p[j][0]
+		}
+		return p[i][1] < p[j][1]
+	})
+}
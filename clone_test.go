@@ -0,0 +1,155 @@
+package mq
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func ExamplePublish_Clone() {
+	p := Pub(2, "a/b", "gopher")
+	p.SetPacketID(1)
+	p.AddUserProp("color", "red")
+
+	c := p.Clone()
+	c.SetQoS(1)
+	c.SetPacketID(7)
+	c.AddSubscriptionID(3)
+	c.AddUserProp("size", "large")
+
+	fmt.Println(p, p.UserProperties)
+	fmt.Println(c, c.UserProperties)
+	// output:
+	// PUBLISH -2-- p1 a/b 29 bytes [color:red]
+	// PUBLISH --1- p7 a/b 45 bytes [color:red size:large]
+}
+
+func TestClone(t *testing.T) {
+	for _, p := range annotatedPackets() {
+		c := clonePacket(p)
+		if c == nil {
+			t.Fatalf("%T missing in clonePacket", p)
+		}
+		if reflect.ValueOf(c).Pointer() == reflect.ValueOf(p).Pointer() {
+			t.Errorf("%T.Clone returned the same packet", p)
+		}
+		if !reflect.DeepEqual(c, p) {
+			t.Errorf("%T.Clone differs\n%v\n%v", p, c, p)
+		}
+		if !Equal(c, p) {
+			t.Errorf("%T.Clone not Equal", p)
+		}
+		// modifying the clone leaves the original as is
+		if v, ok := c.(interface{ userProperties() *UserProperties }); ok {
+			before := wire(p)
+			v.userProperties().AddUserProp("added", "to clone")
+			if Equal(c, p) || string(before) != string(wire(p)) {
+				t.Errorf("%T.Clone shares user properties", p)
+			}
+		}
+	}
+}
+
+func TestClone_sharedCapacity(t *testing.T) {
+	p := NewPublish()
+	p.UserProperties = make(UserProperties, 0, 4)
+	p.AddUserProp("a", "1")
+	p.subscriptionIDs = make([]uint32, 0, 4)
+	p.AddSubscriptionID(1)
+
+	c := p.Clone()
+	c.AddUserProp("b", "2")
+	c.AddSubscriptionID(2)
+	c.subscriptionIDs[0] = 9
+
+	if v := p.UserProperties[:cap(p.UserProperties)][1]; v != (UserProp{}) {
+		t.Error("clone appended to original user properties", v)
+	}
+	if v := p.subscriptionIDs[:2]; v[0] != 1 || v[1] != 0 {
+		t.Error("clone modified original subscription IDs", v)
+	}
+
+	s := NewSubscribe()
+	s.SetSubscriptionID(3)
+	s.AddFilters(NewTopicFilter("a/b", OptQoS1))
+	sc := s.Clone()
+	sc.SetSubscriptionID(4)
+	sc.filters[0].SetFilter("c/d")
+	if s.SubscriptionID() != 3 || s.Filters()[0].Filter() != "a/b" {
+		t.Error("clone modified original", s)
+	}
+
+	will := Pub(1, "client/gone", "pink")
+	will.AddUserProp("a", "1")
+	con := NewConnect()
+	con.SetWill(will)
+	cc := con.Clone()
+	cc.Will().AddUserProp("b", "2")
+	if len(con.Will().UserProperties) != 1 {
+		t.Error("clone modified original will")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := Pub(1, "a/b", "gopher")
+	a.AddUserProp("color", "red", "size", "large")
+	a.AddSubscriptionID(1)
+	a.AddSubscriptionID(2)
+
+	b := Pub(1, "a/b", "gopher")
+	b.AddUserProp("size", "large", "color", "red")
+	b.AddSubscriptionID(2)
+	b.AddSubscriptionID(1)
+	b.SetCorrelationData([]byte{})
+	b.SetResponseTopic("")
+
+	if !Equal(a, b) {
+		t.Error("property order or empty values matter")
+	}
+	if b.SetPayload([]byte("pink")); Equal(a, b) {
+		t.Error("different payload is equal")
+	}
+
+	cases := []struct {
+		a, b Packet
+		exp  bool
+	}{
+		{nil, nil, true},
+		{a, nil, false},
+		{nil, a, false},
+		{NewPingReq(), NewPingReq(), true},
+		{NewPingReq(), NewPingResp(), false},
+		{NewPubAck(), NewPubRec(), false},
+		{&Undefined{data: []byte{1}}, &Undefined{data: []byte{1}}, true},
+		{&Undefined{data: []byte{1}}, &Undefined{data: []byte{2}}, false},
+	}
+	// absent capabilities are their defaults
+	ack := NewConnAck()
+	ack.SetMaxQoS(2)
+	ack.SetSharedSubAvailable(true)
+	if !Equal(NewConnAck(), ack) || !Equal(ack, NewConnAck()) {
+		t.Error("default capability differs from absent")
+	}
+	if ack.SetMaxQoS(1); Equal(NewConnAck(), ack) {
+		t.Error("MaxQoS 1 equals absent")
+	}
+
+	for i, c := range cases {
+		if got := Equal(c.a, c.b); got != c.exp {
+			t.Errorf("case %v: got %v, expected %v", i, got, c.exp)
+		}
+	}
+
+	// will properties in any order
+	wa := Pub(0, "client/gone", "pink")
+	wa.AddUserProp("a", "1", "b", "2")
+	ca := NewConnect()
+	ca.SetWill(wa)
+	wb := Pub(0, "client/gone", "pink")
+	wb.AddUserProp("b", "2", "a", "1")
+	cb := NewConnect()
+	cb.SetWill(wb)
+	if !Equal(ca, cb) {
+		t.Error("will user property order matters")
+	}
+}
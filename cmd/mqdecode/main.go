@@ -0,0 +1,204 @@
+// Command mqdecode prints mqtt packets given as hex, base64 or raw
+// bytes on stdin.
+//
+// Usage
+//
+//	mqdecode [OPTIONS]
+//
+// Each packet is printed with its String, the byte offsets of each
+// field and, unless -s is given, all fields using mq.Dump, e.g.
+//
+//	echo 30 0a 00 03 61 2f 62 00 67 6f 70 68 | mqdecode
+//
+// Hex input may contain whitespace, 0x prefixes and the separators
+// , : and -. With format auto, hex is tried before base64 and input
+// that is neither is used as is. Multiple packets are decoded until
+// the input ends.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gregoryv/mq"
+)
+
+func main() {
+	var (
+		format = flag.String("f", "auto", "input format: auto, hex, base64 or raw")
+		short  = flag.Bool("s", false, "skip dump of all fields")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mqdecode [OPTIONS] < input")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(os.Stdout, os.Stdin, *format, !*short); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer, r io.Reader, format string, dump bool) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data, err := decodeInput(in, format)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return ErrNoData
+	}
+
+	var failed bool
+	for off := 0; off < len(data); {
+		n, err := decodePacket(w, data, off, dump)
+		if err != nil {
+			failed = true
+		}
+		if n == 0 {
+			break // framing lost
+		}
+		off += n
+		fmt.Fprintln(w)
+	}
+	if failed {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// decodePacket writes the packet starting at offset off and returns
+// its size, or 0 if the size cannot be determined.
+func decodePacket(w io.Writer, data []byte, off int, dump bool) (int, error) {
+	headLen, remaining, err := mq.FixedHeaderLen(data[off:])
+	if err == nil && headLen == 0 {
+		err = fmt.Errorf("%w: incomplete fixed header", ErrTruncated)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "%04x error: %v\n", off, err)
+		rows(w, data[off:], off, "")
+		return 0, err
+	}
+	size := headLen + remaining
+	if off+size > len(data) {
+		err := fmt.Errorf("%w: need %v bytes, got %v",
+			ErrTruncated, size, len(data)-off,
+		)
+		fmt.Fprintf(w, "%04x error: %v\n", off, err)
+		rows(w, data[off:], off, "")
+		return 0, err
+	}
+	raw := data[off : off+size]
+
+	p, err := mq.ReadPacket(bytes.NewReader(raw))
+	if err != nil {
+		fmt.Fprintf(w, "%04x error: %v\n", off, err)
+	} else {
+		fmt.Fprintf(w, "%04x %v\n", off, p)
+	}
+	spans, aerr := mq.AnnotateBytes(raw)
+	var end int
+	for _, s := range spans {
+		rows(w, raw[s.Offset:s.Offset+s.Len], off+s.Offset, s.Name)
+		end = s.Offset + s.Len
+	}
+	if aerr != nil {
+		// point out where decoding failed
+		rows(w, raw[end:], off+end, "^ "+aerr.Error())
+		if end == len(raw) {
+			fmt.Fprintf(w, "  %04x  %-48s ^ %v\n", off+end, "", aerr)
+		}
+	}
+	if err == nil && dump {
+		mq.Dump(w, p)
+	}
+	return size, err
+}
+
+// rows writes data in hex, 16 bytes per row, prefixed with the
+// offset. The note is written after the first row.
+func rows(w io.Writer, data []byte, off int, note string) {
+	for i := 0; i < len(data); i += 16 {
+		end := i + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		line := fmt.Sprintf("  %04x  % x", off+i, data[i:end])
+		if i == 0 && note != "" {
+			fmt.Fprintf(w, "%-56s %s\n", line, note)
+			continue
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// ----------------------------------------
+
+// decodeInput converts input in the given format to bytes.
+func decodeInput(in []byte, format string) ([]byte, error) {
+	switch format {
+	case "raw":
+		return in, nil
+	case "hex":
+		return decodeHex(in)
+	case "base64":
+		return decodeBase64(in)
+	case "auto":
+		if v, err := decodeHex(in); err == nil {
+			return v, nil
+		}
+		if v, err := decodeBase64(in); err == nil {
+			return v, nil
+		}
+		return in, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrFormat, format)
+}
+
+func decodeHex(in []byte) ([]byte, error) {
+	s := strings.NewReplacer(
+		"0x", " ", "0X", " ", ",", " ", ":", " ", "-", " ",
+	).Replace(string(in))
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		if len(f) == 1 {
+			// allow single digit bytes, e.g. 0x0
+			fields[i] = "0" + f
+		}
+	}
+	v, err := hex.DecodeString(strings.Join(fields, ""))
+	if err != nil {
+		return nil, fmt.Errorf("hex: %w", err)
+	}
+	return v, nil
+}
+
+func decodeBase64(in []byte) ([]byte, error) {
+	s := strings.Join(strings.Fields(string(in)), "")
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if v, err := enc.DecodeString(s); err == nil {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("base64: invalid input")
+}
+
+var (
+	ErrFormat    = fmt.Errorf("unknown format")
+	ErrNoData    = fmt.Errorf("no data")
+	ErrTruncated = fmt.Errorf("truncated")
+	ErrMalformed = fmt.Errorf("malformed input")
+)
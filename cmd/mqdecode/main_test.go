@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+func Test_run(t *testing.T) {
+	var pub bytes.Buffer
+	mq.Pub(0, "a/b", "gopher").WriteTo(&pub)
+	mq.NewPingReq().WriteTo(&pub)
+	data := pub.Bytes()
+
+	h := hex.EncodeToString(data)
+	inputs := map[string]string{
+		"hex":    h,
+		"spaced": "30 0C 00 03 61 2F 62 00 67 6F 70 68 65 72\nC0 00\n",
+		"0x":     "0x30, 0x0c, 0x0, 0x3, 0x61, 0x2f, 0x62, 0x0, 0x67, 0x6f, 0x70, 0x68, 0x65, 0x72, 0xc0, 0x0",
+		"colon":  "30:0c:00:03:61:2f:62:00:67:6f:70:68:65:72:c0:00",
+		"base64": base64.StdEncoding.EncodeToString(data),
+		"raw":    string(data),
+	}
+	for name, in := range inputs {
+		var buf bytes.Buffer
+		if err := run(&buf, strings.NewReader(in), "auto", true); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, buf.String())
+			continue
+		}
+		out := buf.String()
+		for _, exp := range []string{
+			"0000 PUBLISH ---- p0 a/b",
+			"  0002  00 03 61 2f 62",
+			"TopicName: a/b",
+			"000e PINGREQ ----",
+		} {
+			if !strings.Contains(out, exp) {
+				t.Errorf("%s: missing %q\n%s", name, exp, out)
+			}
+		}
+	}
+}
+
+func Test_run_malformed(t *testing.T) {
+	cases := map[string]struct {
+		in  string
+		exp string
+	}{
+		"property":  {"30 0a 00 03 61 2f 62 05 67 6f 70 68", "0000 error: PUBLISH"},
+		"truncated": {"c0 00 30 0a 00", "0002 error: truncated: need 12 bytes, got 3"},
+		"header":    {"c0", "0000 error: truncated: incomplete fixed header"},
+		"length":    {"30 ff ff ff ff 01", "malformed remaining length"},
+	}
+	for name, c := range cases {
+		var buf bytes.Buffer
+		err := run(&buf, strings.NewReader(c.in), "hex", false)
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: expected ErrMalformed, got %v", name, err)
+		}
+		if out := buf.String(); !strings.Contains(out, c.exp) {
+			t.Errorf("%s: missing %q\n%s", name, c.exp, out)
+		}
+	}
+}
+
+func Test_run_badInput(t *testing.T) {
+	cases := map[string]struct {
+		in, format string
+		exp        error
+	}{
+		"format": {"00", "json", ErrFormat},
+		"empty":  {"", "raw", ErrNoData},
+	}
+	for name, c := range cases {
+		err := run(&bytes.Buffer{}, strings.NewReader(c.in), c.format, false)
+		if !errors.Is(err, c.exp) {
+			t.Errorf("%s: expected %v, got %v", name, c.exp, err)
+		}
+	}
+	if err := run(&bytes.Buffer{}, strings.NewReader("xyz"), "hex", false); err == nil {
+		t.Error("expected error on invalid hex")
+	}
+	if err := run(&bytes.Buffer{}, strings.NewReader("@@"), "base64", false); err == nil {
+		t.Error("expected error on invalid base64")
+	}
+}
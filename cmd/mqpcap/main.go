@@ -0,0 +1,72 @@
+// Command mqpcap prints a timeline of mqtt packets found in pcap or
+// pcapng capture files.
+//
+// Usage
+//
+//	mqpcap [OPTIONS] FILE
+//
+// Use - as FILE to read from stdin, e.g.
+//
+//	tcpdump -w - port 1883 | mqpcap -
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gregoryv/mq"
+	"github.com/gregoryv/mq/pcap"
+)
+
+func main() {
+	var (
+		ports = flag.String("p", "1883", "comma separated server ports")
+		dump  = flag.Bool("d", false, "dump all packet fields")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: mqpcap [OPTIONS] FILE")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(os.Stdout, flag.Arg(0), *ports, *dump); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(w io.Writer, filename, ports string, dump bool) error {
+	d := pcap.NewDecoder()
+	d.Ports = nil
+	for _, v := range strings.Split(ports, ",") {
+		port, err := strconv.ParseUint(strings.TrimSpace(v), 10, 16)
+		if err != nil {
+			return fmt.Errorf("bad port %q", v)
+		}
+		d.Ports = append(d.Ports, uint16(port))
+	}
+
+	r := io.Reader(os.Stdin)
+	if filename != "-" {
+		fh, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		r = fh
+	}
+	return d.Decode(r, func(e *pcap.Event) {
+		fmt.Fprintln(w, e)
+		if dump && e.Packet != nil {
+			mq.Dump(w, e.Packet)
+			fmt.Fprintln(w)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+func Test_run(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "x.pcap")
+	os.WriteFile(filename, capture(mq.Pub(0, "a/b", "gopher")), 0644)
+
+	var buf bytes.Buffer
+	if err := run(&buf, filename, "1883", true); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "10.0.0.1:50000 > 10.0.0.2:1883 PUBLISH") {
+		t.Error(out)
+	}
+	if !strings.Contains(out, "TopicName: a/b") {
+		t.Error("missing dump\n", out)
+	}
+
+	if err := run(&buf, filename, "x", false); err == nil {
+		t.Error("expected error on bad port")
+	}
+	if err := run(&buf, "no-such-file", "1883", false); err == nil {
+		t.Error("expected error on missing file")
+	}
+}
+
+// capture returns a classic pcap file with one raw IPv4 frame
+// holding the packet.
+func capture(p mq.Packet) []byte {
+	var data bytes.Buffer
+	p.WriteTo(&data)
+
+	be := binary.BigEndian
+	tcp := make([]byte, 20)
+	be.PutUint16(tcp[0:], 50000)
+	be.PutUint16(tcp[2:], 1883)
+	tcp[12] = 5 << 4
+	tcp = append(tcp, data.Bytes()...)
+
+	ip := []byte{0x45, 0, 0, 0, 0, 0, 0, 0, 64, 6, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2}
+	be.PutUint16(ip[2:], uint16(20+len(tcp)))
+	ip = append(ip, tcp...)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, be, []uint32{0xa1b2c3d4, 0x0002_0004, 0, 0, 65535, 101})
+	binary.Write(&buf, be, []uint32{0, 0, uint32(len(ip)), uint32(len(ip))})
+	buf.Write(ip)
+	return buf.Bytes()
+}
@@ -0,0 +1,197 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// NewConformance returns a checker for packets on one network
+// connection.
+func NewConformance() *Conformance {
+	return &Conformance{}
+}
+
+// Conformance checks a packet stream, in both directions, against
+// the normative requirements of the specification which depend on
+// previous packets, e.g. CONNECT must be the first packet sent by a
+// client.
+//
+// Server capabilities are read from the ConnAck getters, i.e. a
+// ConnAck without RetainAvailable means retain is available. The
+// same goes for SubIdentifiersAvailable and MaxQoS.
+type Conformance struct {
+	mu sync.Mutex
+
+	connect      *Connect
+	connAck      *ConnAck
+	disconnected [2]bool // by client, server
+
+	violations []*Violation
+}
+
+// FromClient checks packet p sent by the client. Returns the
+// violations caused by p joined, nil if none.
+func (c *Conformance) FromClient(p Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var v violations
+	if c.disconnected[0] {
+		v.add(p, "MQTT-3.14.4-1", "packet after DISCONNECT")
+	}
+
+	switch p := p.(type) {
+	case *Connect:
+		if c.connect != nil {
+			v.add(p, "MQTT-3.1.0-2", "second CONNECT")
+			break
+		}
+		c.connect = p
+
+	case *Disconnect:
+		c.disconnected[0] = true
+	}
+	if c.connect == nil {
+		v.add(p, "MQTT-3.1.0-1", "CONNECT must be the first packet")
+	}
+
+	if ack := c.connAck; ack != nil {
+		c.clientCapabilities(&v, ack, p)
+	}
+	return c.keep(v)
+}
+
+// clientCapabilities checks p against the server capabilities given
+// in the ConnAck.
+func (c *Conformance) clientCapabilities(v *violations, ack *ConnAck, p Packet) {
+	if max := ack.MaxPacketSize(); max > 0 {
+		if n := size(p); n > int(max) {
+			v.add(p, "MQTT-3.2.2-15", fmt.Sprintf(
+				"%v bytes exceeds MaxPacketSize %v", n, max,
+			))
+		}
+	}
+	switch p := p.(type) {
+	case *Publish:
+		if p.QoS() > ack.MaxQoS() {
+			v.add(p, "MQTT-3.2.2-11", fmt.Sprintf(
+				"QoS %v exceeds MaxQoS %v", p.QoS(), ack.MaxQoS(),
+			))
+		}
+		if p.Retain() && !ack.RetainAvailable() {
+			v.add(p, "MQTT-3.2.2-14", "retain not available")
+		}
+		if p.TopicAlias() > ack.TopicAliasMax() {
+			v.add(p, "MQTT-3.3.2-9", fmt.Sprintf(
+				"TopicAlias %v exceeds TopicAliasMax %v",
+				p.TopicAlias(), ack.TopicAliasMax(),
+			))
+		}
+
+	case *Subscribe:
+		if p.SubscriptionID() > -1 && !ack.SubIdentifiersAvailable() {
+			// no numbered requirement, it's a Protocol Error
+			v.add(p, "3.2.2.3.12", "subscription identifiers not available")
+		}
+	}
+}
+
+// FromServer checks packet p sent by the server. Returns the
+// violations caused by p joined, nil if none.
+func (c *Conformance) FromServer(p Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var v violations
+	if c.disconnected[1] {
+		v.add(p, "MQTT-3.14.4-1", "packet after DISCONNECT")
+	}
+
+	switch p := p.(type) {
+	case *ConnAck:
+		if c.connAck != nil {
+			v.add(p, "MQTT-3.2.0-2", "second CONNACK")
+			break
+		}
+		c.connAck = p
+
+	case *Auth:
+
+	case *Disconnect:
+		c.disconnected[1] = true
+
+	default:
+		if c.connAck == nil || c.connAck.ReasonCode() != Success {
+			v.add(p, "MQTT-3.2.0-1", "successful CONNACK must be sent first")
+		}
+	}
+
+	if connect := c.connect; connect != nil {
+		if max := connect.MaxPacketSize(); max > 0 {
+			if n := size(p); n > int(max) {
+				v.add(p, "MQTT-3.1.2-24", fmt.Sprintf(
+					"%v bytes exceeds MaxPacketSize %v", n, max,
+				))
+			}
+		}
+		if p, ok := p.(*Publish); ok && p.TopicAlias() > connect.TopicAliasMax() {
+			v.add(p, "MQTT-3.3.2-11", fmt.Sprintf(
+				"TopicAlias %v exceeds TopicAliasMax %v",
+				p.TopicAlias(), connect.TopicAliasMax(),
+			))
+		}
+	}
+	return c.keep(v)
+}
+
+// Violations returns all violations found so far in the order they
+// were found.
+func (c *Conformance) Violations() []*Violation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Violation(nil), c.violations...)
+}
+
+func (c *Conformance) keep(v violations) error {
+	if len(v) == 0 {
+		return nil
+	}
+	c.violations = append(c.violations, v...)
+	if len(v) == 1 {
+		return v[0]
+	}
+	errs := make([]error, len(v))
+	for i := range v {
+		errs[i] = v[i]
+	}
+	return errors.Join(errs...)
+}
+
+// size returns the width of p in wire format without writing it, a
+// PublishStream payload is not read.
+func size(p Packet) int {
+	if p, ok := p.(interface{ width() int }); ok {
+		return p.width()
+	}
+	return 0
+}
+
+// ----------------------------------------
+
+// Violation of a normative requirement in the specification.
+type Violation struct {
+	// Ref is the requirement, e.g. MQTT-3.1.0-1, or the section
+	// for requirements without a number.
+	Ref    string
+	Reason string
+	Packet Packet
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s %s: %v", v.Ref, v.Reason, v.Packet)
+}
+
+type violations []*Violation
+
+func (v *violations) add(p Packet, ref, reason string) {
+	*v = append(*v, &Violation{Ref: ref, Reason: reason, Packet: p})
+}
@@ -0,0 +1,169 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ExampleConformance() {
+	c := NewConformance()
+	err := c.FromClient(Pub(0, "a/b", "hello"))
+	fmt.Println(err)
+	// output:
+	// MQTT-3.1.0-1 CONNECT must be the first packet: PUBLISH ---- p0 a/b 13 bytes
+}
+
+func TestConformance_capabilityDefaults(t *testing.T) {
+	c := NewConformance()
+	c.FromClient(NewConnect())
+	c.FromServer(roundtrip(t, NewConnAck()))
+
+	retain := Pub(2, "a/b", "")
+	retain.SetPacketID(1)
+	retain.SetRetain(true)
+	sub := NewSubscribe()
+	sub.SetPacketID(2)
+	sub.SetSubscriptionID(1)
+	sub.AddFilters(NewTopicFilter("a/#", 0))
+	for _, p := range []Packet{retain, sub} {
+		if err := c.FromClient(p); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConformance_stream(t *testing.T) {
+	ack := NewConnAck()
+	ack.SetMaxPacketSize(30)
+	c := NewConformance()
+	c.FromClient(NewConnect())
+	c.FromServer(ack)
+
+	p := NewPublishStream()
+	p.SetTopicName("a/b")
+	payload := "0123456789abcdef0123456789"
+	p.SetPayload(strings.NewReader(payload), int64(len(payload)))
+	expectRef(t, c.FromClient(p), "MQTT-3.2.2-15")
+
+	// payload is left for the caller
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != p.width() {
+		t.Errorf("wrote %v bytes, expected %v", buf.Len(), p.width())
+	}
+}
+
+func TestConformance_FromClient(t *testing.T) {
+	ack := NewConnAck()
+	ack.SetMaxQoS(1)
+	ack.SetRetainAvailable(false)
+	ack.SetSubIdentifiersAvailable(false)
+	ack.SetTopicAliasMax(2)
+	ack.SetMaxPacketSize(30)
+
+	retain := Pub(0, "a/b", "")
+	retain.SetRetain(true)
+	alias := Pub(0, "a/b", "")
+	alias.SetTopicAlias(3)
+	sub := NewSubscribe()
+	sub.SetPacketID(1)
+	sub.SetSubscriptionID(1)
+	sub.AddFilters(NewTopicFilter("a/#", 0))
+	large := Pub(0, "a/b", "0123456789abcdef0123456789")
+
+	cases := []struct {
+		p   Packet
+		ref string
+	}{
+		{NewConnect(), "MQTT-3.1.0-2"},
+		{Pub(2, "a/b", ""), "MQTT-3.2.2-11"},
+		{retain, "MQTT-3.2.2-14"},
+		{alias, "MQTT-3.3.2-9"},
+		{sub, "3.2.2.3.12"},
+		{large, "MQTT-3.2.2-15"},
+	}
+	c := NewConformance()
+	if err := c.FromClient(NewConnect()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FromServer(ack); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FromClient(Pub(1, "a/b", "")); err != nil {
+		t.Error(err)
+	}
+	for _, tc := range cases {
+		err := c.FromClient(tc.p)
+		var v *Violation
+		if !errors.As(err, &v) || v.Ref != tc.ref {
+			t.Errorf("%v: expected %s, got %v", tc.p, tc.ref, err)
+		}
+	}
+	if got := len(c.Violations()); got != len(cases) {
+		t.Errorf("got %v violations, expected %v", got, len(cases))
+	}
+
+	// multiple violations by one packet
+	both := Pub(2, "a/b", "")
+	both.SetRetain(true)
+	err := c.FromClient(both)
+	if err == nil {
+		t.Fatal("expected violations")
+	}
+	if got := len(c.Violations()); got != len(cases)+2 {
+		t.Errorf("got %v violations, expected %v", got, len(cases)+2)
+	}
+
+	c.FromClient(NewDisconnect())
+	expectRef(t, c.FromClient(NewPingReq()), "MQTT-3.14.4-1")
+}
+
+func TestConformance_FromServer(t *testing.T) {
+	c := NewConformance()
+	expectRef(t, c.FromClient(NewPingReq()), "MQTT-3.1.0-1")
+	expectRef(t, c.FromServer(NewPingResp()), "MQTT-3.2.0-1")
+
+	connect := NewConnect()
+	connect.SetTopicAliasMax(1)
+	connect.SetMaxPacketSize(20)
+	c.FromClient(connect)
+	if err := c.FromServer(NewAuth()); err != nil {
+		t.Error(err)
+	}
+	if err := c.FromServer(NewConnAck()); err != nil {
+		t.Error(err)
+	}
+	if err := c.FromServer(NewPingResp()); err != nil {
+		t.Error(err)
+	}
+	expectRef(t, c.FromServer(NewConnAck()), "MQTT-3.2.0-2")
+
+	alias := Pub(0, "a/b", "")
+	alias.SetTopicAlias(2)
+	expectRef(t, c.FromServer(alias), "MQTT-3.3.2-11")
+	expectRef(t, c.FromServer(Pub(0, "a/b", "0123456789abcdef")), "MQTT-3.1.2-24")
+
+	c.FromServer(NewDisconnect())
+	expectRef(t, c.FromServer(NewPingResp()), "MQTT-3.14.4-1")
+
+	// failed connack
+	c = NewConformance()
+	c.FromClient(NewConnect())
+	ack := NewConnAck()
+	ack.SetReasonCode(NotAuthorized)
+	c.FromServer(ack)
+	expectRef(t, c.FromServer(NewPingResp()), "MQTT-3.2.0-1")
+}
+
+func expectRef(t *testing.T, err error, ref string) {
+	t.Helper()
+	var v *Violation
+	if !errors.As(err, &v) || v.Ref != ref {
+		t.Errorf("expected %s, got %v", ref, err)
+	}
+}
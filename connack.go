@@ -36,11 +36,45 @@ type ConnAck struct {
 	serverReference         wstring
 	authMethod              wstring
 	authData                bindata
+
+	// capabilities set or read, absent ones are available
+	present capability
+}
+
+// capability marks a server capability property as present.
+type capability uint8
+
+const (
+	hasMaxQoS capability = 1 << iota
+	hasRetainAvailable
+	hasWildcardSubAvailable
+	hasSubIDsAvailable
+	hasSharedSubAvailable
+)
+
+// omitDefaults marks capabilities set to their default as absent,
+// both mean the same.
+func (p *ConnAck) omitDefaults() {
+	if p.MaxQoS() == 2 {
+		p.present &^= hasMaxQoS
+	}
+	if p.RetainAvailable() {
+		p.present &^= hasRetainAvailable
+	}
+	if p.WildcardSubAvailable() {
+		p.present &^= hasWildcardSubAvailable
+	}
+	if p.SubIdentifiersAvailable() {
+		p.present &^= hasSubIDsAvailable
+	}
+	if p.SharedSubAvailable() {
+		p.present &^= hasSharedSubAvailable
+	}
 }
 
 func (p *ConnAck) HasFlag(v byte) bool { return p.flags.Has(v) }
 
-func (p *ConnAck) SetSessionPresent(v bool) { p.flags.toggle(1, true) }
+func (p *ConnAck) SetSessionPresent(v bool) { p.flags.toggle(1, v) }
 func (p *ConnAck) SessionPresent() bool     { return p.flags.Has(1) }
 
 func (p *ConnAck) SetSessionExpiryInterval(v uint32) { p.sessionExpiryInterval = wuint32(v) }
@@ -49,11 +83,30 @@ func (p *ConnAck) SessionExpiryInterval() uint32     { return uint32(p.sessionEx
 func (p *ConnAck) SetReceiveMax(v uint16) { p.receiveMax = wuint16(v) }
 func (p *ConnAck) ReceiveMax() uint16     { return uint16(p.receiveMax) }
 
-func (p *ConnAck) SetMaxQoS(v uint8) { p.maxQoS = wuint8(v) }
-func (p *ConnAck) MaxQoS() uint8     { return uint8(p.maxQoS) }
+// SetMaxQoS sets the highest QoS the server supports. Unless set
+// MaxQoS returns 2, as absent means all QoS levels are supported.
+func (p *ConnAck) SetMaxQoS(v uint8) {
+	p.maxQoS = wuint8(v)
+	p.present |= hasMaxQoS
+}
+
+func (p *ConnAck) MaxQoS() uint8 {
+	if p.present&hasMaxQoS == 0 {
+		return 2
+	}
+	return uint8(p.maxQoS)
+}
+
+// SetRetainAvailable sets if the server supports retained messages,
+// available unless set.
+func (p *ConnAck) SetRetainAvailable(v bool) {
+	p.retainAvailable = wbool(v)
+	p.present |= hasRetainAvailable
+}
 
-func (p *ConnAck) SetRetainAvailable(v bool) { p.retainAvailable = wbool(v) }
-func (p *ConnAck) RetainAvailable() bool     { return bool(p.retainAvailable) }
+func (p *ConnAck) RetainAvailable() bool {
+	return p.present&hasRetainAvailable == 0 || bool(p.retainAvailable)
+}
 
 func (p *ConnAck) SetMaxPacketSize(v uint32) { p.maxPacketSize = wuint32(v) }
 func (p *ConnAck) MaxPacketSize() uint32     { return uint32(p.maxPacketSize) }
@@ -70,14 +123,38 @@ func (p *ConnAck) ReasonCode() ReasonCode     { return ReasonCode(p.reasonCode)
 func (p *ConnAck) SetReasonString(v string) { p.reasonString = wstring(v) }
 func (p *ConnAck) ReasonString() string     { return string(p.reasonString) }
 
-func (p *ConnAck) SetWildcardSubAvailable(v bool) { p.wildcardSubAvailable = wbool(v) }
-func (p *ConnAck) WildcardSubAvailable() bool     { return bool(p.wildcardSubAvailable) }
+// SetWildcardSubAvailable sets if the server supports wildcard
+// subscriptions, available unless set.
+func (p *ConnAck) SetWildcardSubAvailable(v bool) {
+	p.wildcardSubAvailable = wbool(v)
+	p.present |= hasWildcardSubAvailable
+}
+
+func (p *ConnAck) WildcardSubAvailable() bool {
+	return p.present&hasWildcardSubAvailable == 0 || bool(p.wildcardSubAvailable)
+}
+
+// SetSubIdentifiersAvailable sets if the server supports
+// subscription identifiers, available unless set.
+func (p *ConnAck) SetSubIdentifiersAvailable(v bool) {
+	p.subIdentifiersAvailable = wbool(v)
+	p.present |= hasSubIDsAvailable
+}
+
+func (p *ConnAck) SubIdentifiersAvailable() bool {
+	return p.present&hasSubIDsAvailable == 0 || bool(p.subIdentifiersAvailable)
+}
 
-func (p *ConnAck) SetSubIdentifiersAvailable(v bool) { p.subIdentifiersAvailable = wbool(v) }
-func (p *ConnAck) SubIdentifiersAvailable() bool     { return bool(p.subIdentifiersAvailable) }
+// SetSharedSubAvailable sets if the server supports shared
+// subscriptions, available unless set.
+func (p *ConnAck) SetSharedSubAvailable(v bool) {
+	p.sharedSubAvailable = wbool(v)
+	p.present |= hasSharedSubAvailable
+}
 
-func (p *ConnAck) SetSharedSubAvailable(v bool) { p.sharedSubAvailable = wbool(v) }
-func (p *ConnAck) SharedSubAvailable() bool     { return bool(p.sharedSubAvailable) }
+func (p *ConnAck) SharedSubAvailable() bool {
+	return p.present&hasSharedSubAvailable == 0 || bool(p.sharedSubAvailable)
+}
 
 func (p *ConnAck) SetServerKeepAlive(v uint16) { p.serverKeepAlive = wuint16(v) }
 func (p *ConnAck) ServerKeepAlive() uint16     { return uint16(p.serverKeepAlive) }
@@ -97,6 +174,27 @@ func (p *ConnAck) AuthData() []byte     { return []byte(p.authData) }
 // end settings
 // ----------------------------------------
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *ConnAck) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	if err := wellFormedReason(p, CONNACK); err != nil {
+		return err
+	}
+	if p.flags&^1 != 0 {
+		return newMalformed(p, "flags", "reserved bit set")
+	}
+	if p.SessionPresent() && p.ReasonCode() != Success {
+		return newMalformed(p, "session present", "set on failure")
+	}
+	if len(p.authData) > 0 && len(p.authMethod) == 0 {
+		return newMalformed(p, "auth data", "without auth method")
+	}
+	return nil
+}
+
 func (p *ConnAck) String() string {
 	return withReason(p, fmt.Sprintf("%s %s %s %v bytes",
 		firstByte(p.fixed).String(),
@@ -118,9 +216,9 @@ func withReason(p HasReason, v string) string {
 	return v
 }
 
-func (p *ConnAck) dump(w io.Writer) {
+func (p *ConnAck) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "AssignedClientID: %q\n", p.AssignedClientID())
-	fmt.Fprintf(w, "AuthData: %q\n", string(p.AuthData()))
+	fmt.Fprintf(w, "AuthData: %q\n", d.secret(p.AuthData()))
 	fmt.Fprintf(w, "AuthMethod: %q\n", p.AuthMethod())
 	fmt.Fprintf(w, "MaxPacketSize: %v\n", p.MaxPacketSize())
 	fmt.Fprintf(w, "MaxQoS: %v\n", p.MaxQoS())
@@ -172,15 +270,15 @@ func (p *ConnAck) properties(b []byte, i int) int {
 	n := i
 	i += p.receiveMax.fillProp(b, i, ReceiveMax)
 	i += p.sessionExpiryInterval.fillProp(b, i, SessionExpiryInterval)
-	i += p.maxQoS.fillProp(b, i, MaxQoS)
-	i += p.retainAvailable.fillProp(b, i, RetainAvailable)
+	i += p.capabilityProp(b, i, hasMaxQoS, &p.maxQoS, MaxQoS)
+	i += p.capabilityProp(b, i, hasRetainAvailable, &p.retainAvailable, RetainAvailable)
 	i += p.maxPacketSize.fillProp(b, i, MaxPacketSize)
 	i += p.assignedClientID.fillProp(b, i, AssignedClientID)
 	i += p.topicAliasMax.fillProp(b, i, TopicAliasMax)
 	i += p.reasonString.fillProp(b, i, ReasonString)
-	i += p.wildcardSubAvailable.fillProp(b, i, WildcardSubAvailable)
-	i += p.subIdentifiersAvailable.fillProp(b, i, SubIDsAvailable)
-	i += p.sharedSubAvailable.fillProp(b, i, SharedSubAvailable)
+	i += p.capabilityProp(b, i, hasWildcardSubAvailable, &p.wildcardSubAvailable, WildcardSubAvailable)
+	i += p.capabilityProp(b, i, hasSubIDsAvailable, &p.subIdentifiersAvailable, SubIDsAvailable)
+	i += p.capabilityProp(b, i, hasSharedSubAvailable, &p.sharedSubAvailable, SharedSubAvailable)
 	i += p.serverKeepAlive.fillProp(b, i, ServerKeepAlive)
 	i += p.responseInformation.fillProp(b, i, ResponseInformation)
 	i += p.serverReference.fillProp(b, i, ServerReference)
@@ -190,6 +288,18 @@ func (p *ConnAck) properties(b []byte, i int) int {
 	return i - n
 }
 
+// capabilityProp fills property v if present, also when zero as
+// absent means available.
+func (p *ConnAck) capabilityProp(b []byte, i int, c capability, v wireType, id Ident) int {
+	if p.present&c == 0 {
+		return 0
+	}
+	n := i
+	i += id.fill(b, i)
+	i += v.fill(b, i)
+	return i - n
+}
+
 func (p *ConnAck) UnmarshalBinary(data []byte) error {
 	b := &buffer{data: data}
 	b.get(&p.flags)
@@ -202,15 +312,15 @@ func (p *ConnAck) propertyMap() map[Ident]func() wireType {
 	return map[Ident]func() wireType{
 		ReceiveMax:            func() wireType { return &p.receiveMax },
 		SessionExpiryInterval: func() wireType { return &p.sessionExpiryInterval },
-		MaxQoS:                func() wireType { return &p.maxQoS },
-		RetainAvailable:       func() wireType { return &p.retainAvailable },
+		MaxQoS:                func() wireType { p.present |= hasMaxQoS; return &p.maxQoS },
+		RetainAvailable:       func() wireType { p.present |= hasRetainAvailable; return &p.retainAvailable },
 		MaxPacketSize:         func() wireType { return &p.maxPacketSize },
 		AssignedClientID:      func() wireType { return &p.assignedClientID },
 		TopicAliasMax:         func() wireType { return &p.topicAliasMax },
 		ReasonString:          func() wireType { return &p.reasonString },
-		WildcardSubAvailable:  func() wireType { return &p.wildcardSubAvailable },
-		SubIDsAvailable:       func() wireType { return &p.subIdentifiersAvailable },
-		SharedSubAvailable:    func() wireType { return &p.sharedSubAvailable },
+		WildcardSubAvailable:  func() wireType { p.present |= hasWildcardSubAvailable; return &p.wildcardSubAvailable },
+		SubIDsAvailable:       func() wireType { p.present |= hasSubIDsAvailable; return &p.subIdentifiersAvailable },
+		SharedSubAvailable:    func() wireType { p.present |= hasSharedSubAvailable; return &p.sharedSubAvailable },
 		ServerKeepAlive:       func() wireType { return &p.serverKeepAlive },
 		ResponseInformation:   func() wireType { return &p.responseInformation },
 		ServerReference:       func() wireType { return &p.serverReference },
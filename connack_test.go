@@ -24,20 +24,20 @@ func ExampleDump_connAck() {
 	// AuthData: ""
 	// AuthMethod: ""
 	// MaxPacketSize: 0
-	// MaxQoS: 0
+	// MaxQoS: 2
 	// ReasonCode: NotAuthorized
 	// ReasonString: ""
 	// ReceiveMax: 0
 	// ResponseInformation: ""
-	// RetainAvailable: false
+	// RetainAvailable: true
 	// ServerKeepAlive: 0
 	// ServerReference: ""
 	// SessionExpiryInterval: 0
 	// SessionPresent: true
-	// SharedSubAvailable: false
-	// SubIdentifiersAvailable: false
+	// SharedSubAvailable: true
+	// SubIdentifiersAvailable: true
 	// TopicAliasMax: 0
-	// WildcardSubAvailable: false
+	// WildcardSubAvailable: true
 	// UserProperties
 	//   0. color: "red"
 }
@@ -80,6 +80,28 @@ func ExampleConnAck_String() {
 	// 0 s Session present
 }
 
+func TestConnAck_capabilitiesOff(t *testing.T) {
+	a := NewConnAck()
+	a.SetMaxQoS(0)
+	a.SetRetainAvailable(false)
+	a.SetWildcardSubAvailable(false)
+	a.SetSubIdentifiersAvailable(false)
+	a.SetSharedSubAvailable(false)
+
+	data, _ := a.MarshalJSON()
+	j, err := ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []Packet{roundtrip(t, a), j} {
+		b := p.(*ConnAck)
+		if b.MaxQoS() != 0 || b.RetainAvailable() || b.WildcardSubAvailable() ||
+			b.SubIdentifiersAvailable() || b.SharedSubAvailable() {
+			t.Errorf("capabilities lost: %v", b)
+		}
+	}
+}
+
 func TestConnAck(t *testing.T) {
 	a := NewConnAck()
 	size := unsafe.Sizeof(a)
@@ -87,6 +109,10 @@ func TestConnAck(t *testing.T) {
 	eq(t, a.SetSessionPresent, a.SessionPresent, true)
 	eq(t, a.SetSessionExpiryInterval, a.SessionExpiryInterval, 199)
 	eq(t, a.SetReceiveMax, a.ReceiveMax, 81)
+	if a.MaxQoS() != 2 || !a.RetainAvailable() || !a.WildcardSubAvailable() ||
+		!a.SubIdentifiersAvailable() || !a.SharedSubAvailable() {
+		t.Error("absent capabilities must be available")
+	}
 	eq(t, a.SetMaxQoS, a.MaxQoS, 1)
 	eq(t, a.SetRetainAvailable, a.RetainAvailable, true)
 	eq(t, a.SetMaxPacketSize, a.MaxPacketSize, 250)
@@ -109,6 +135,10 @@ func TestConnAck(t *testing.T) {
 	if !a.HasFlag(SessionPresent) {
 		t.Error("HasFlag should be true for 1 if sessionPresent is set")
 	}
+	a.SetSessionPresent(false)
+	if a.HasFlag(SessionPresent) {
+		t.Error("SetSessionPresent(false) should clear flag")
+	}
 
 	if false {
 		var buf bytes.Buffer
@@ -14,6 +14,8 @@ import (
 // The other direction is also important to be able to write out large
 // packets without loading everything into memory each packet must
 // implement io.WriterTo.
+//
+// PublishStream and ReadStream do this for publish payloads.
 
 var mqtt5 = []byte("MQTT")
 
@@ -170,9 +172,11 @@ func (p *Connect) SetUsername(v string) {
 }
 func (p *Connect) Username() string { return string(p.username) }
 
+// SetPassword sets the password and PasswordFlag, nil clears both.
+// An empty, non nil, password is sent as such.
 func (p *Connect) SetPassword(v []byte) {
 	p.password = v
-	p.flags.toggle(PasswordFlag, len(p.password) > 0)
+	p.flags.toggle(PasswordFlag, v != nil)
 }
 func (p *Connect) Password() []byte { return p.password }
 
@@ -188,14 +192,42 @@ func (p *Connect) String() string {
 	)
 }
 
-func (p *Connect) dump(w io.Writer) {
-	fmt.Fprintf(w, "AuthData: %v\n", p.AuthData())
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *Connect) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	if p.flags.Has(Reserved) {
+		return newMalformed(p, "flags", "reserved bit set")
+	}
+	if p.flags.Has(WillQoS1 | WillQoS2) {
+		return newMalformed(p, "will QoS", "invalid")
+	}
+	if !p.flags.Has(WillFlag) {
+		if p.willQoS() > 0 {
+			return newMalformed(p, "will QoS", "set without will")
+		}
+		if p.flags.Has(WillRetain) {
+			return newMalformed(p, "will retain", "set without will")
+		}
+	}
+	// Password without username is allowed in version 5, see
+	// 3.1.2.9 Password Flag.
+	if len(p.authData) > 0 && len(p.authMethod) == 0 {
+		return newMalformed(p, "auth data", "without auth method")
+	}
+	return nil
+}
+
+func (p *Connect) dump(w io.Writer, d *Dumper) {
+	fmt.Fprintf(w, "AuthData: %v\n", d.secret(p.AuthData()))
 	fmt.Fprintf(w, "AuthMethod: %v\n", p.AuthMethod())
 	fmt.Fprintf(w, "CleanStart: %v\n", p.CleanStart())
 	fmt.Fprintf(w, "ClientID: %v\n", p.ClientID())
 	fmt.Fprintf(w, "KeepAlive: %v\n", p.KeepAlive())
 	fmt.Fprintf(w, "MaxPacketSize: %v\n", p.MaxPacketSize())
-	fmt.Fprintf(w, "Password: %q\n", stars(len(p.Password())))
+	fmt.Fprintf(w, "Password: %q\n", d.secret(p.Password()))
 	fmt.Fprintf(w, "ProtocolName: %v\n", p.ProtocolName())
 	fmt.Fprintf(w, "ProtocolVersion: %v\n", p.ProtocolVersion())
 	fmt.Fprintf(w, "ReceiveMax: %v\n", p.ReceiveMax())
@@ -203,11 +235,11 @@ func (p *Connect) dump(w io.Writer) {
 	fmt.Fprintf(w, "RequestResponseInfo: %v\n", p.RequestResponseInfo())
 	fmt.Fprintf(w, "SessionExpiryInterval: %v\n", p.SessionExpiryInterval())
 	fmt.Fprintf(w, "TopicAliasMax: %v\n", p.TopicAliasMax())
-	fmt.Fprintf(w, "Username: %v\n", stars(len(p.Username())))
+	fmt.Fprintf(w, "Username: %v\n", d.secret(p.username))
 
 	if p.will != nil {
 		fmt.Fprintln(w, "Will")
-		p.will.dump(w)
+		p.will.dump(&indented{w: w}, d)
 	}
 
 	p.UserProperties.dump(w)
@@ -231,6 +263,10 @@ func (p *Connect) WriteTo(w io.Writer) (int64, error) {
 	return int64(n), err
 }
 
+func (p *Connect) width() int {
+	return p.fill(_LEN, 0)
+}
+
 func (p *Connect) fill(b []byte, i int) int {
 	remainingLen := vbint(p.variableHeader(_LEN, 0) + p.payload(_LEN, 0))
 
@@ -288,9 +324,13 @@ func (p *Connect) payload(b []byte, i int) int {
 		properties := func(b []byte, i int) int {
 			n := i
 
-			for id, v := range p.willPropertyMap() {
-				i += v().fillProp(b, i, id)
-			}
+			// fixed order, same as the spec
+			i += p.willDelayInterval.fillProp(b, i, WillDelayInterval)
+			i += p.will.payloadFormat.fillProp(b, i, PayloadFormatIndicator)
+			i += p.will.messageExpiryInterval.fillProp(b, i, MessageExpiryInterval)
+			i += p.will.contentType.fillProp(b, i, ContentType)
+			i += p.will.responseTopic.fillProp(b, i, ResponseTopic)
+			i += p.will.correlationData.fillProp(b, i, CorrelationData)
 			i += p.will.UserProperties.properties(b, i)
 
 			return i - n
@@ -331,6 +371,7 @@ func (p *Connect) UnmarshalBinary(data []byte) error {
 	if bits(p.flags).Has(WillFlag) {
 		p.will = NewPublish()
 		p.will.SetQoS(p.willQoS())
+		p.will.SetRetain(p.flags.Has(WillRetain))
 		buf.getAny(p.willPropertyMap(), p.appendWillProperty)
 		get(&p.will.topicName)
 		get(&p.willPayload)
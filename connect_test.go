@@ -91,6 +91,23 @@ func TestConnect(t *testing.T) {
 	testControlPacket(t, c)
 }
 
+func TestConnect_willRetain(t *testing.T) {
+	will := Pub(1, "client/gone", "pink")
+	will.SetRetain(true)
+	c := NewConnect()
+	c.SetWill(will)
+
+	var buf bytes.Buffer
+	c.WriteTo(&buf)
+	p, err := ReadPacket(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.(*Connect).Will().Retain() {
+		t.Error("will retain lost")
+	}
+}
+
 // eq is used to check equality of set and "get" funcs
 // Thank you generics.
 func eq[T any](t *testing.T, set func(T), get func() T, value T) {
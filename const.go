@@ -1,5 +1,7 @@
 package mq
 
+import "fmt"
+
 // 2.1.2 MQTT Control Packet type
 //
 // https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_MQTT_Control_Packet
@@ -56,6 +58,43 @@ const (
 	SharedSubAvailable     Ident = 0x2a
 )
 
+func (v Ident) String() string {
+	if name, found := identNames[v]; found {
+		return name
+	}
+	return fmt.Sprintf("Ident(0x%02x)", byte(v))
+}
+
+var identNames = map[Ident]string{
+	PayloadFormatIndicator: "PayloadFormatIndicator",
+	MessageExpiryInterval:  "MessageExpiryInterval",
+	ContentType:            "ContentType",
+	ResponseTopic:          "ResponseTopic",
+	CorrelationData:        "CorrelationData",
+	SubscriptionID:         "SubscriptionID",
+	SessionExpiryInterval:  "SessionExpiryInterval",
+	AssignedClientID:       "AssignedClientID",
+	ServerKeepAlive:        "ServerKeepAlive",
+	AuthMethod:             "AuthMethod",
+	AuthData:               "AuthData",
+	RequestProblemInfo:     "RequestProblemInfo",
+	WillDelayInterval:      "WillDelayInterval",
+	RequestResponseInfo:    "RequestResponseInfo",
+	ResponseInformation:    "ResponseInformation",
+	ServerReference:        "ServerReference",
+	ReasonString:           "ReasonString",
+	ReceiveMax:             "ReceiveMax",
+	TopicAliasMax:          "TopicAliasMax",
+	TopicAlias:             "TopicAlias",
+	MaxQoS:                 "MaxQoS",
+	RetainAvailable:        "RetainAvailable",
+	UserProperty:           "UserProperty",
+	MaxPacketSize:          "MaxPacketSize",
+	WildcardSubAvailable:   "WildcardSubAvailable",
+	SubIDsAvailable:        "SubIDsAvailable",
+	SharedSubAvailable:     "SharedSubAvailable",
+}
+
 const (
 	maxUint16 = 1<<16 - 1
 )
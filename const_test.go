@@ -9,3 +9,12 @@ func Test_ReasonStringCode(t *testing.T) {
 		}
 	}
 }
+
+func TestIdent_String(t *testing.T) {
+	if v := TopicAlias.String(); v != "TopicAlias" {
+		t.Error(v)
+	}
+	if v := Ident(0x67).String(); v != "Ident(0x67)" {
+		t.Error(v)
+	}
+}
@@ -0,0 +1,311 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Diff returns the fields that differ between a and b, in the order
+// they are encoded by MarshalJSON. Values are not redacted and each
+// user property, filter and reason code is compared separately.
+// Packets of different types differ only in field Type.
+func Diff(a, b Packet) []FieldDiff {
+	an, bn := typeName(a), typeName(b)
+	if an != bn {
+		return []FieldDiff{{Field: "Type", A: an, B: bn}}
+	}
+	af, aerr := packetFields(an, a)
+	bf, berr := packetFields(bn, b)
+	if aerr != nil || berr != nil {
+		return []FieldDiff{{Field: an, A: errString(aerr), B: errString(berr)}}
+	}
+
+	var diffs []FieldDiff
+	for _, name := range merge(af, bf) {
+		a, inA := af.get(name)
+		b, inB := bf.get(name)
+		switch {
+		case !inA && !af.optional(an, name):
+			diffs = append(diffs, FieldDiff{Field: name, B: b.value, OnlyB: true})
+			continue
+		case !inB && !bf.optional(an, name):
+			diffs = append(diffs, FieldDiff{Field: name, A: a.value, OnlyA: true})
+			continue
+		case !inA:
+			a.value = omitted(name, b.zero)
+		case !inB:
+			b.value = omitted(name, a.zero)
+		}
+		if a.value != b.value {
+			diffs = append(diffs, FieldDiff{Field: name, A: a.value, B: b.value})
+		}
+	}
+	return diffs
+}
+
+// merge returns the names of a with the names only in b inserted
+// after the name preceding them in b.
+func merge(a, b fields) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for _, f := range a {
+		names = append(names, f.name)
+	}
+	at := 0 // insert position
+	for _, f := range b {
+		if i := index(names, f.name); i >= 0 {
+			at = i + 1
+			continue
+		}
+		names = append(names[:at], append([]string{f.name}, names[at:]...)...)
+		at++
+	}
+	return names
+}
+
+func index(names []string, name string) int {
+	for i, v := range names {
+		if v == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// FieldDiff is one field differing between two packets.
+type FieldDiff struct {
+	// Field is the path of the field, e.g. Publish.TopicName or
+	// Connect.Will.UserProperties[1].Key
+	Field string
+
+	// A and B are the values as encoded by MarshalJSON, binary data
+	// is base64 encoded
+	A, B string
+
+	// OnlyA or OnlyB is set if the field is missing in the other
+	// packet, e.g. an additional user property
+	OnlyA, OnlyB bool
+}
+
+// String returns the difference as "Field: A != B", missing values
+// are written as -.
+func (d FieldDiff) String() string {
+	a, b := d.A, d.B
+	if d.OnlyB {
+		a = "-"
+	}
+	if d.OnlyA {
+		b = "-"
+	}
+	return fmt.Sprintf("%s: %s != %s", d.Field, a, b)
+}
+
+// WriteDiffText writes one line per difference.
+func WriteDiffText(w io.Writer, diffs []FieldDiff) error {
+	for _, d := range diffs {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDiffHTML writes the differences as an HTML table with columns
+// field, a and b.
+func WriteDiffHTML(w io.Writer, diffs []FieldDiff) error {
+	var buf bytes.Buffer
+	buf.WriteString("<table class=\"diff\">\n")
+	buf.WriteString("<tr><th>Field</th><th>a</th><th>b</th></tr>\n")
+	for _, d := range diffs {
+		a, b := html.EscapeString(d.A), html.EscapeString(d.B)
+		if d.OnlyB {
+			a = "-"
+		}
+		if d.OnlyA {
+			b = "-"
+		}
+		fmt.Fprintf(&buf, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(d.Field), a, b,
+		)
+	}
+	buf.WriteString("</table>\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ----------------------------------------
+
+func typeName(p Packet) string {
+	if p == nil {
+		return "nil"
+	}
+	t := reflect.TypeOf(p)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// omitted returns the value of a field omitted by the encoding, the
+// zero value or the default of a ConnAck capability.
+func omitted(name, zero string) string {
+	if v, found := capabilityDefaults[name]; found {
+		return v
+	}
+	return zero
+}
+
+var capabilityDefaults = map[string]string{
+	"ConnAck.MaxQoS":                  "2",
+	"ConnAck.RetainAvailable":         "true",
+	"ConnAck.WildcardSubAvailable":    "true",
+	"ConnAck.SubIdentifiersAvailable": "true",
+	"ConnAck.SharedSubAvailable":      "true",
+}
+
+type field struct {
+	name, value string
+	zero        string // value if omitted, e.g. 0 for numbers
+}
+
+type fields []field
+
+func (f fields) get(name string) (field, bool) {
+	for _, v := range f {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return field{}, false
+}
+
+// optional returns true if the field name, missing in f, is a value
+// omitted by the encoding rather than a missing item, e.g. an
+// additional user property or a will only set in one packet.
+func (f fields) optional(root, name string) bool {
+	i := strings.LastIndexAny(name, ".[")
+	if i < 0 || name[i] == '[' {
+		return false
+	}
+	parent := name[:i]
+	if parent == root {
+		return true
+	}
+	for _, v := range f {
+		if strings.HasPrefix(v.name, parent+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isUserProp returns true for paths like Publish.UserProperties[1].
+func isUserProp(path string) bool {
+	return strings.HasSuffix(path, "]") &&
+		strings.HasSuffix(strings.TrimRight(path, "0123456789]"), ".UserProperties[")
+}
+
+// flattenUserProp adds the encoded key value pair as one field
+// written as in Dump, e.g. color: "red".
+func flattenUserProp(dec *json.Decoder, path string, res *fields) error {
+	var kv []interface{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		kv = append(kv, t)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if len(kv) != 2 {
+		return fmt.Errorf("flattenUserProp: %v", kv)
+	}
+	*res = append(*res, field{name: path, value: fmt.Sprintf("%v: %q", kv[0], kv[1])})
+	return nil
+}
+
+// packetFields returns the fields of p as encoded by MarshalJSON.
+// Objects and arrays are flattened into named fields, e.g.
+// Subscribe.Filters[0].Filter.
+func packetFields(root string, p Packet) (fields, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var res fields
+	return res, flatten(dec, root, &res)
+}
+
+func flatten(dec *json.Decoder, path string, res *fields) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		if t == '[' && isUserProp(path) {
+			return flattenUserProp(dec, path, res)
+		}
+		for i := 0; dec.More(); i++ {
+			name := fmt.Sprintf("%s[%v]", path, i)
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name = fmt.Sprintf("%s.%v", path, key)
+			}
+			if err := flatten(dec, name, res); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token() // closing delimiter
+		return err
+
+	case string:
+		*res = append(*res, field{name: path, value: t})
+	case json.Number:
+		*res = append(*res, field{name: path, value: t.String(), zero: "0"})
+	case bool:
+		*res = append(*res, field{name: path, value: fmt.Sprint(t), zero: "false"})
+	default: // null
+		*res = append(*res, field{name: path, value: fmt.Sprint(t)})
+	}
+	return nil
+}
+
+// indented writes each line to w prefixed with two spaces.
+type indented struct {
+	w       io.Writer
+	midLine bool
+}
+
+func (in *indented) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, c := range p {
+		if !in.midLine {
+			buf.WriteString("  ")
+		}
+		buf.WriteByte(c)
+		in.midLine = c != '\n'
+	}
+	if _, err := in.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
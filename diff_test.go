@@ -0,0 +1,150 @@
+package mq
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func ExampleDiff() {
+	a := Pub(1, "a/b", "gopher")
+	a.AddUserProp("color", "red")
+
+	b := Pub(1, "a/b", "gopher")
+	b.SetMessageExpiryInterval(60)
+	b.AddUserProp("color", "blue", "size", "large")
+
+	WriteDiffText(os.Stdout, Diff(a, b))
+	// output:
+	// Publish.MessageExpiryInterval: 0 != 60
+	// Publish.UserProperties[0]: color: "red" != color: "blue"
+	// Publish.UserProperties[1]: - != size: "large"
+}
+
+func TestDiff(t *testing.T) {
+	for _, p := range annotatedPackets() {
+		if d := Diff(p, clonePacket(p)); len(d) > 0 {
+			t.Errorf("%T: %v", p, d)
+		}
+	}
+
+	if d := Diff(NewPingReq(), NewPingResp()); len(d) != 1 || d[0].Field != "Type" {
+		t.Error(d)
+	}
+
+	// nested will
+	a := NewConnect()
+	a.SetClientID("pink")
+	a.AddUserProp("color", "red")
+	will := Pub(0, "client/gone", "pink")
+	will.AddUserProp("color", "red")
+	a.SetWill(will)
+
+	b := a.Clone()
+	b.Will().AddUserProp("size", "large")
+	b.Will().SetTopicName("client/lost")
+	var buf bytes.Buffer
+	WriteDiffText(&buf, Diff(a, b))
+	exp := `Connect.Will.TopicName: client/gone != client/lost
+Connect.Will.UserProperties[1]: - != size: "large"
+`
+	if got := buf.String(); got != exp {
+		t.Errorf("got\n%s\nexpected\n%s", got, exp)
+	}
+
+	// only in a
+	d := Diff(b, a)
+	if last := d[len(d)-1]; !last.OnlyA || last.String() != `Connect.Will.UserProperties[1]: size: "large" != -` {
+		t.Error(last)
+	}
+}
+
+func TestDiff_values(t *testing.T) {
+	// secrets of equal length
+	a, b := NewConnect(), NewConnect()
+	a.SetPassword([]byte("secret"))
+	b.SetPassword([]byte("terces"))
+	if d := Diff(a, b); len(d) != 1 || d[0].Field != "Connect.Password" {
+		t.Error(d)
+	}
+
+	// values with newlines
+	p := Pub(0, "a", "")
+	p.SetResponseTopic("r\nTopicName: zz")
+	if d := Diff(p, p.Clone()); len(d) > 0 {
+		t.Error(d)
+	}
+
+	// each reason code
+	x, y := NewSubAck(), NewSubAck()
+	for _, c := range []ReasonCode{Success, NotAuthorized} {
+		x.AddReasonCode(c)
+	}
+	for i := 0; i < 3; i++ {
+		y.AddReasonCode(Success)
+	}
+	var buf bytes.Buffer
+	WriteDiffText(&buf, Diff(x, y))
+	exp := `SubAck.ReasonCodes[1]: 135 != 0
+SubAck.ReasonCodes[2]: - != 0
+`
+	if got := buf.String(); got != exp {
+		t.Errorf("got\n%s\nexpected\n%s", got, exp)
+	}
+
+	// repeated names
+	f, g := NewSubscribe(), NewSubscribe()
+	f.AddFilters(NewTopicFilter("a", OptQoS1), NewTopicFilter("b", OptQoS1))
+	g.AddFilters(NewTopicFilter("a", OptQoS1), NewTopicFilter("b", OptQoS2))
+	if d := Diff(f, g); len(d) != 1 || d[0].Field != "Subscribe.Filters[1].Options" {
+		t.Error(d)
+	}
+	// absent capabilities are their defaults
+	ack := NewConnAck()
+	ack.SetMaxQoS(2)
+	ack.SetRetainAvailable(true)
+	if d := Diff(NewConnAck(), ack); len(d) > 0 {
+		t.Error(d)
+	}
+	ack.SetMaxQoS(1)
+	buf.Reset()
+	WriteDiffText(&buf, Diff(NewConnAck(), ack))
+	if got, exp := buf.String(), "ConnAck.MaxQoS: 2 != 1\n"; got != exp {
+		t.Errorf("got %q, expected %q", got, exp)
+	}
+}
+
+func TestWriteDiffHTML(t *testing.T) {
+	a := Pub(0, "a/b", "gopher")
+	b := Pub(0, "a/<b>", "gopher")
+	b.AddUserProp("x", "y")
+
+	var buf bytes.Buffer
+	if err := WriteDiffHTML(&buf, Diff(a, b)); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, exp := range []string{
+		"<td>Publish.TopicName</td><td>a/b</td><td>a/&lt;b&gt;</td>",
+		"<td>Publish.UserProperties[0]</td><td>-</td>",
+	} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("missing %s\n%s", exp, got)
+		}
+	}
+}
+
+func TestDump_connectWill(t *testing.T) {
+	c := NewConnect()
+	will := Pub(0, "client/gone", "pink")
+	will.AddUserProp("color", "red")
+	c.SetWill(will)
+
+	var buf bytes.Buffer
+	Dump(&buf, c)
+	if got := buf.String(); !strings.Contains(got, "Will\n  ContentType: \n") ||
+		!strings.Contains(got, "  UserProperties\n    0. color: \"red\"\n") {
+		t.Error(got)
+	}
+}
@@ -21,6 +21,15 @@ type Disconnect struct {
 func (p *Disconnect) SetReasonCode(v ReasonCode) { p.reasonCode = wuint8(v) }
 func (p *Disconnect) ReasonCode() ReasonCode     { return ReasonCode(p.reasonCode) }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *Disconnect) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	return wellFormedReason(p, DISCONNECT)
+}
+
 func (p *Disconnect) String() string {
 	return withReason(p, fmt.Sprintf("%s %v bytes",
 		firstByte(p.fixed).String(),
@@ -28,8 +37,8 @@ func (p *Disconnect) String() string {
 	))
 }
 
-func (p *Disconnect) dump(w io.Writer) {
-	fmt.Fprintf(w, "ReasonCode: %v\n", p.ReasonCode())
+func (p *Disconnect) dump(w io.Writer, d *Dumper) {
+	fmt.Fprintf(w, "ReasonCode: %v\n", p.ReasonCode().NameFor(DISCONNECT))
 	p.UserProperties.dump(w)
 }
 
@@ -66,6 +75,10 @@ func (p *Disconnect) variableHeader(b []byte, i int) int {
 }
 
 func (p *Disconnect) UnmarshalBinary(data []byte) error {
+	// reason code and properties may be omitted on success
+	if len(data) == 0 {
+		return nil
+	}
 	b := &buffer{data: data}
 	b.get(&p.reasonCode)
 	b.getAny(p.propertyMap(), p.appendUserProperty)
@@ -83,6 +83,7 @@ func docPacket(file, fn string, a mq.ControlPacket, b *packets.ControlPacket) *E
 				Pre("b \n", hex.Dump(B.Bytes())),
 
 				Pre(fmt.Sprintf("a %v\nb %v", A.Bytes(), B.Bytes())),
+				Pre(diffText(a, B.Bytes())),
 				map[bool]string{
 					true:  "a == b",
 					false: "a != b",
@@ -92,6 +93,17 @@ func docPacket(file, fn string, a mq.ControlPacket, b *packets.ControlPacket) *E
 	)
 }
 
+// diffText returns the fields of a which differ when reading data.
+func diffText(a mq.ControlPacket, data []byte) string {
+	b, err := mq.ReadPacket(bytes.NewReader(data))
+	if err != nil {
+		return err.Error()
+	}
+	var buf bytes.Buffer
+	mq.WriteDiffText(&buf, mq.Diff(a, b))
+	return buf.String()
+}
+
 func alignPackets(a mq.ControlPacket, b *packets.ControlPacket) string {
 	var abuf bytes.Buffer
 	a.WriteTo(&abuf)
@@ -0,0 +1,115 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"unicode/utf8"
+)
+
+// NewDumper returns a dumper with the same output as Dump, i.e.
+// payloads as Go byte slices and credentials redacted.
+func NewDumper() *Dumper {
+	return &Dumper{}
+}
+
+// Dumper writes all packet fields with configurable rendering of
+// payloads and credentials.
+//
+// Payloads are rendered as indented JSON if enabled and the content
+// type is application/json, as text if enabled and the payload format
+// indicates UTF-8, otherwise as hex if enabled or as a Go byte slice.
+type Dumper struct {
+	maxPayload  int
+	hex         bool
+	text        bool
+	prettyJSON  bool
+	showSecrets bool
+}
+
+// SetMaxPayload limits the number of payload bytes written, 0 means
+// no limit. JSON and text payloads are formatted before being cut at
+// a character boundary. Truncated payloads are followed by their full
+// size.
+func (d *Dumper) SetMaxPayload(v int) { d.maxPayload = v }
+func (d *Dumper) MaxPayload() int     { return d.maxPayload }
+
+// SetHex writes binary payloads as hex.
+func (d *Dumper) SetHex(v bool) { d.hex = v }
+func (d *Dumper) Hex() bool     { return d.hex }
+
+// SetText writes payloads as quoted text if Publish.PayloadFormat is
+// true and the payload is valid UTF-8.
+func (d *Dumper) SetText(v bool) { d.text = v }
+func (d *Dumper) Text() bool     { return d.text }
+
+// SetPrettyJSON writes valid JSON payloads indented if
+// Publish.ContentType is application/json.
+func (d *Dumper) SetPrettyJSON(v bool) { d.prettyJSON = v }
+func (d *Dumper) PrettyJSON() bool     { return d.prettyJSON }
+
+// SetRedact redacts username, password and auth data, default true.
+func (d *Dumper) SetRedact(v bool) { d.showSecrets = !v }
+func (d *Dumper) Redact() bool     { return !d.showSecrets }
+
+// Dump writes all fields of p to w, including empty value ones.
+func (d *Dumper) Dump(w io.Writer, p Packet) {
+	if p, ok := p.(interface{ dump(io.Writer, *Dumper) }); ok {
+		p.dump(w, d)
+	}
+}
+
+// payload returns the rendered payload of p.
+func (d *Dumper) payload(p *Publish) string {
+	data := p.Payload()
+	switch {
+	case d.prettyJSON && isJSON(p.ContentType()) && json.Valid(data):
+		var buf bytes.Buffer
+		// indented so that each line belongs to the payload
+		json.Indent(&buf, data, "  ", "  ")
+		v, more := d.truncate(buf.String(), len(data))
+		return v + more
+
+	case d.text && p.PayloadFormat() && utf8.Valid(data):
+		v, more := d.truncate(string(data), len(data))
+		return fmt.Sprintf("%q", v) + more
+	}
+	var more string
+	if d.maxPayload > 0 && len(data) > d.maxPayload {
+		data = data[:d.maxPayload]
+		more = fmt.Sprintf(" ... %v bytes", len(p.payload))
+	}
+	if d.hex {
+		return hex.EncodeToString(data) + more
+	}
+	return fmt.Sprint(data) + more
+}
+
+// truncate returns at most maxPayload bytes of the rendered payload v,
+// cut at a rune boundary, followed by the payload size if cut.
+func (d *Dumper) truncate(v string, size int) (string, string) {
+	n := d.maxPayload
+	if n <= 0 || len(v) <= n {
+		return v, ""
+	}
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return v[:n], fmt.Sprintf(" ... %v bytes", size)
+}
+
+// secret returns v or stars if redacted.
+func (d *Dumper) secret(v []byte) string {
+	if d.showSecrets {
+		return string(v)
+	}
+	return stars(len(v))
+}
+
+func isJSON(contentType string) bool {
+	v, _, err := mime.ParseMediaType(contentType)
+	return err == nil && v == "application/json"
+}
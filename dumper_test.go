@@ -0,0 +1,230 @@
+package mq
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func ExampleDumper() {
+	p := Pub(0, "a/b", `{"color":"red","size":3}`)
+	p.SetContentType("application/json; charset=utf-8")
+
+	d := NewDumper()
+	d.SetPrettyJSON(true)
+	var buf bytes.Buffer
+	d.Dump(&buf, p)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "Payload: ") || strings.HasPrefix(line, " ") {
+			os.Stdout.WriteString(line + "\n")
+		}
+	}
+	// output:
+	// Payload: {
+	//     "color": "red",
+	//     "size": 3
+	//   }
+}
+
+func TestDumper(t *testing.T) {
+	d := NewDumper()
+	eq(t, d.SetMaxPayload, d.MaxPayload, 4)
+	eq(t, d.SetHex, d.Hex, true)
+	eq(t, d.SetText, d.Text, true)
+	eq(t, d.SetPrettyJSON, d.PrettyJSON, true)
+	eq(t, d.SetRedact, d.Redact, false)
+	eq(t, d.SetRedact, d.Redact, true)
+
+	cases := []struct {
+		name   string
+		dumper func() *Dumper
+		pub    func() *Publish
+		exp    string
+	}{
+		{
+			name:   "default",
+			dumper: NewDumper,
+			pub:    func() *Publish { return Pub(0, "a/b", "hi") },
+			exp:    "Payload: [104 105]\n",
+		},
+		{
+			name: "hex",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetHex(true)
+				return d
+			},
+			pub: func() *Publish { return Pub(0, "a/b", "hi") },
+			exp: "Payload: 6869\n",
+		},
+		{
+			name: "text",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetText(true)
+				d.SetHex(true)
+				return d
+			},
+			pub: func() *Publish {
+				p := Pub(0, "a/b", "hi")
+				p.SetPayloadFormat(true)
+				return p
+			},
+			exp: "Payload: \"hi\"\n",
+		},
+		{
+			name: "text without payload format",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetText(true)
+				return d
+			},
+			pub: func() *Publish { return Pub(0, "a/b", "hi") },
+			exp: "Payload: [104 105]\n",
+		},
+		{
+			name: "text invalid UTF-8",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetText(true)
+				d.SetHex(true)
+				return d
+			},
+			pub: func() *Publish {
+				p := Pub(0, "a/b", "\xff")
+				p.SetPayloadFormat(true)
+				return p
+			},
+			exp: "Payload: ff\n",
+		},
+		{
+			name: "max payload",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetHex(true)
+				d.SetMaxPayload(2)
+				return d
+			},
+			pub: func() *Publish { return Pub(0, "a/b", "gopher") },
+			exp: "Payload: 676f ... 6 bytes\n",
+		},
+		{
+			name: "json not application/json",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetPrettyJSON(true)
+				d.SetText(true)
+				return d
+			},
+			pub: func() *Publish {
+				p := Pub(0, "a/b", `{"a":1}`)
+				p.SetContentType("text/plain")
+				p.SetPayloadFormat(true)
+				return p
+			},
+			exp: "Payload: \"{\\\"a\\\":1}\"\n",
+		},
+		{
+			name: "text truncated at rune",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetText(true)
+				d.SetMaxPayload(3)
+				return d
+			},
+			pub: func() *Publish {
+				p := Pub(0, "a/b", "håll")
+				p.SetPayloadFormat(true)
+				return p
+			},
+			exp: "Payload: \"hå\" ... 5 bytes\n",
+		},
+		{
+			name: "json truncated",
+			dumper: func() *Dumper {
+				d := NewDumper()
+				d.SetPrettyJSON(true)
+				d.SetMaxPayload(13)
+				return d
+			},
+			pub: func() *Publish {
+				p := Pub(0, "a/b", `{"a":"ö"}`)
+				p.SetContentType("application/json")
+				return p
+			},
+			exp: "Payload: {\n    \"a\": \" ... 10 bytes\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			c.dumper().Dump(&buf, c.pub())
+			if got := buf.String(); !strings.Contains(got, c.exp) {
+				t.Errorf("missing %q\n%s", c.exp, got)
+			}
+		})
+	}
+}
+
+func TestDumper_redact(t *testing.T) {
+	c := NewConnect()
+	c.SetUsername("john.doe")
+	c.SetPassword([]byte("secret"))
+	c.SetAuthMethod("SCRAM-SHA-256")
+	c.SetAuthData([]byte("first"))
+
+	a := NewAuth()
+	a.SetAuthData([]byte("final"))
+
+	ack := NewConnAck()
+	ack.SetAuthData([]byte("final"))
+
+	secrets := []string{"john.doe", "secret", "first", "final"}
+	for _, p := range []Packet{c, a, ack} {
+		var buf bytes.Buffer
+		Dump(&buf, p)
+		for _, s := range secrets {
+			if strings.Contains(buf.String(), s) {
+				t.Errorf("%T: %s not redacted\n%s", p, s, buf.String())
+			}
+		}
+	}
+
+	d := NewDumper()
+	d.SetRedact(false)
+	var buf bytes.Buffer
+	for _, p := range []Packet{c, a, ack} {
+		d.Dump(&buf, p)
+	}
+	for _, s := range secrets {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("missing %s\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestDumper_will(t *testing.T) {
+	will := Pub(0, "client/gone", `{"id":1}`)
+	will.SetContentType("application/json")
+	c := NewConnect()
+	c.SetWill(will)
+
+	var buf bytes.Buffer
+	h := NewDumpHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+	d := NewDumper()
+	d.SetPrettyJSON(true)
+	h.SetDumper(d)
+	if h.Dumper() != d {
+		t.Fatal("SetDumper")
+	}
+	slog.New(h).Debug("in", "p", c)
+
+	exp := `p.Will.Payload="{\n\"id\": 1\n}"`
+	if got := buf.String(); !strings.Contains(got, exp) {
+		t.Errorf("missing %s\n%s", exp, got)
+	}
+}
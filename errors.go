@@ -57,6 +57,24 @@ func (e *Malformed) Error() string {
 	return buf.String()
 }
 
+// wellFormedFixed returns a Malformed error if the flags of the fixed
+// header differ from the given ones.
+func wellFormedFixed(p Packet, fixed bits, flags byte) *Malformed {
+	if v := byte(fixed) & 0b0000_1111; v != flags {
+		return newMalformed(p, "fixed header flags", fmt.Sprintf("%04b", v))
+	}
+	return nil
+}
+
+// wellFormedReason returns a Malformed error if the reason code is
+// not valid for the given packet type.
+func wellFormedReason(p HasReason, packetType byte) *Malformed {
+	if c := p.ReasonCode(); !c.ValidFor(packetType) {
+		return newMalformed(p, "reason code", c.String())
+	}
+	return nil
+}
+
 func withForm(p HasWellFormed, v string) string {
 	if err := p.WellFormed(); err != nil {
 		return fmt.Sprintf("%s, malformed! %s %s", v, err.reason, err.ref)
@@ -0,0 +1,211 @@
+/*
+Package fault injects faults into mqtt traffic for robustness tests
+of clients and servers.
+
+A Conn wraps a connection and applies rules to each packet written
+to it, or read from it, e.g.
+
+	conn := fault.NewConn(c)
+	conn.Add(
+		fault.Drop(fault.Type(mq.PUBACK)),
+		fault.Delay(fault.Type(mq.PINGRESP), time.Second),
+	)
+
+Packets are matched by type or any predicate. Rules with a
+probability below one use a random source per direction seeded with
+SetSeed, so a scenario is repeated exactly by using the same seed,
+regardless of how reads and writes interleave.
+*/
+package fault
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+// NewConn returns c with faults injected as defined by added rules.
+// Without rules all traffic passes unchanged.
+func NewConn(c net.Conn) *Conn {
+	conn := &Conn{
+		Conn:  c,
+		sleep: time.Sleep,
+	}
+	conn.SetSeed(1)
+	return conn
+}
+
+// Conn applies rules to packets passing through it. Rules are applied
+// in the order they are added and all matching rules apply, e.g. a
+// packet may be both delayed and duplicated.
+type Conn struct {
+	net.Conn
+
+	mu    sync.Mutex
+	rules []*Rule
+	seed  int64
+	sleep func(time.Duration)
+
+	wmu  sync.Mutex
+	sent stream
+
+	rmu      sync.Mutex
+	received stream
+	ready    []byte // to be read
+	err      error  // returned by Read once ready is consumed
+}
+
+// SetSeed sets the seed of random choices, default 1. Sent and
+// received packets use separate sources with the same seed.
+func (c *Conn) SetSeed(v int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seed = v
+	c.sent.rand = rand.New(rand.NewSource(v))
+	c.received.rand = rand.New(rand.NewSource(v))
+}
+
+func (c *Conn) Seed() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seed
+}
+
+// Add adds rules applied after already added ones.
+func (c *Conn) Add(rules ...*Rule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = append(c.rules, rules...)
+}
+
+// Write splits p into packets and writes them to the wrapped
+// connection after applying rules. Incomplete packets are buffered
+// until the rest is written. Malformed packets are written unchanged.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.sent.buf = append(c.sent.buf, p...)
+	for {
+		buf := bytes.NewReader(c.sent.buf)
+		r := &source{r: buf}
+		packet, _ := mq.ReadPacket(r)
+		if r.err != nil {
+			return len(p), nil // incomplete
+		}
+		size := len(c.sent.buf) - buf.Len()
+		raw := c.sent.buf[:size:size]
+		c.sent.buf = c.sent.buf[size:]
+
+		out := c.apply(false, &c.sent, raw, packet)
+		if out.delay > 0 {
+			c.sleep(out.delay)
+		}
+		for _, frame := range out.frames {
+			if _, err := c.Conn.Write(frame); err != nil {
+				return 0, err
+			}
+		}
+		if out.cut {
+			c.Conn.Close()
+			return 0, fmt.Errorf("Write: %w", net.ErrClosed)
+		}
+	}
+}
+
+// Read reads packets from the wrapped connection and returns them
+// after applying rules.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for len(c.ready) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		var raw bytes.Buffer
+		r := &source{r: io.TeeReader(c.Conn, &raw)}
+		packet, _ := mq.ReadPacket(r)
+		if r.err != nil && raw.Len() == 0 {
+			return 0, r.err
+		}
+		if r.err != nil {
+			// partial packet followed by the error
+			c.ready, c.err = raw.Bytes(), r.err
+			continue
+		}
+		out := c.apply(true, &c.received, raw.Bytes(), packet)
+		if out.delay > 0 {
+			c.sleep(out.delay)
+		}
+		c.ready = bytes.Join(out.frames, nil)
+		if out.cut {
+			c.Conn.Close()
+			c.err = io.EOF
+		}
+	}
+	n := copy(p, c.ready)
+	c.ready = c.ready[n:]
+	return n, nil
+}
+
+// apply returns the result of applying matching rules on one packet.
+// Packet is nil if raw is malformed.
+func (c *Conn) apply(received bool, s *stream, raw []byte, packet mq.Packet) result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := result{frames: [][]byte{raw}}
+	if packet != nil {
+		for _, rule := range c.rules {
+			if rule.received != received || !rule.applies(packet, s.rand) {
+				continue
+			}
+			rule.fault(&out, s, s.rand)
+			if len(out.frames) == 0 || out.cut {
+				break
+			}
+		}
+	}
+	if len(out.frames) > 0 && !out.cut {
+		// previously reordered packets follow
+		out.frames = append(out.frames, s.held...)
+		s.held = nil
+	}
+	return out
+}
+
+// ----------------------------------------
+
+// stream is the state of one direction.
+type stream struct {
+	buf  []byte   // incomplete packet
+	held [][]byte // reordered packets
+	rand *rand.Rand
+}
+
+// result of applying rules to one packet.
+type result struct {
+	frames [][]byte // to write, in order
+	delay  time.Duration
+	cut    bool // close after frames
+}
+
+// source records the first error reading from r, to tell an
+// incomplete packet from a malformed one.
+type source struct {
+	r   io.Reader
+	err error
+}
+
+func (s *source) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
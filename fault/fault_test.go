@@ -0,0 +1,360 @@
+package fault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+func Example() {
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	conn.Add(Drop(Type(mq.PUBACK)))
+
+	go func() {
+		for i := uint16(1); i <= 3; i++ {
+			ack := mq.NewPubAck()
+			ack.SetPacketID(i)
+			ack.WriteTo(conn)
+		}
+		conn.Close()
+	}()
+	for {
+		p, err := mq.ReadPacket(server)
+		if err != nil {
+			break
+		}
+		fmt.Println(p)
+	}
+	fmt.Println("no packets")
+	// output:
+	// no packets
+}
+
+func TestConn_passthrough(t *testing.T) {
+	var buf bytes.Buffer
+	for _, p := range packets() {
+		p.WriteTo(&buf)
+	}
+	// malformed remaining length
+	buf.Write([]byte{0xc0, 0xff, 0xff, 0xff, 0xff, 0x01})
+	exp := buf.Bytes()
+
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	go func() {
+		for _, b := range exp {
+			conn.Write([]byte{b})
+		}
+		conn.Close()
+	}()
+	got, _ := io.ReadAll(server)
+	if !bytes.Equal(got, exp) {
+		t.Errorf("got\n%v\nexpected\n%v", got, exp)
+	}
+}
+
+func TestConn_malformedPacket(t *testing.T) {
+	// PUBLISH with truncated topic name
+	bad := []byte{0x30, 0x01, 0x00}
+	got := transfer(t, []*Rule{Drop(Any)}, func(w io.Writer) {
+		w.Write(bad)
+		mq.NewPingReq().WriteTo(w)
+	})
+	if !bytes.Equal(got, bad) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestConn_rules(t *testing.T) {
+	pub := Type(mq.PUBLISH)
+	cases := []struct {
+		name  string
+		rules func() []*Rule
+		exp   []string
+	}{
+		{
+			name:  "drop",
+			rules: func() []*Rule { return []*Rule{Drop(pub)} },
+			exp:   []string{"PUBACK", "PINGREQ"},
+		},
+		{
+			name: "skip",
+			rules: func() []*Rule {
+				r := Drop(Type(mq.PUBLISH, mq.PUBACK))
+				r.SetSkip(1)
+				return []*Rule{r}
+			},
+			exp: []string{"PUBLISH", "PINGREQ"},
+		},
+		{
+			name: "times",
+			rules: func() []*Rule {
+				r := Drop(Any)
+				r.SetTimes(2)
+				return []*Rule{r}
+			},
+			exp: []string{"PINGREQ"},
+		},
+		{
+			name:  "duplicate",
+			rules: func() []*Rule { return []*Rule{Duplicate(pub)} },
+			exp:   []string{"PUBLISH", "PUBLISH", "PUBACK", "PINGREQ"},
+		},
+		{
+			name:  "reorder",
+			rules: func() []*Rule { return []*Rule{Reorder(pub)} },
+			exp:   []string{"PUBACK", "PUBLISH", "PINGREQ"},
+		},
+		{
+			name: "reorder and drop next",
+			rules: func() []*Rule {
+				return []*Rule{Reorder(pub), Drop(Type(mq.PUBACK))}
+			},
+			exp: []string{"PINGREQ", "PUBLISH"},
+		},
+		{
+			name: "received",
+			rules: func() []*Rule {
+				r := Drop(Any)
+				r.SetReceived(true)
+				return []*Rule{r}
+			},
+			exp: []string{"PUBLISH", "PUBACK", "PINGREQ"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := transfer(t, c.rules(), writePackets)
+			if got := types(t, data); !reflect.DeepEqual(got, c.exp) {
+				t.Errorf("got %v, expected %v", got, c.exp)
+			}
+		})
+	}
+}
+
+func TestConn_Read(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	drop := Drop(Type(mq.PUBLISH))
+	drop.SetReceived(true)
+	conn.Add(drop, Duplicate(Type(mq.PUBLISH)))
+	go func() {
+		writePackets(server)
+		server.Close()
+	}()
+	data, err := io.ReadAll(oneByteReader{conn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"PUBACK", "PINGREQ"}
+	if got := types(t, data); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestConn_delay(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	var slept []time.Duration
+	conn.sleep = func(d time.Duration) { slept = append(slept, d) }
+	conn.Add(
+		Delay(Type(mq.PUBACK), time.Second),
+		Delay(Any, time.Millisecond),
+	)
+	go func() {
+		writePackets(conn)
+		conn.Close()
+	}()
+	io.ReadAll(server)
+	exp := []time.Duration{
+		time.Millisecond, time.Second + time.Millisecond, time.Millisecond,
+	}
+	if !reflect.DeepEqual(slept, exp) {
+		t.Errorf("slept %v, expected %v", slept, exp)
+	}
+}
+
+func TestConn_corrupt(t *testing.T) {
+	var buf bytes.Buffer
+	writePackets(&buf)
+	exp := buf.Bytes()
+
+	corrupt := func(seed int64) []byte {
+		return transfer(t, []*Rule{Corrupt(Type(mq.PUBLISH))}, writePackets, seed)
+	}
+	got := corrupt(7)
+	if len(got) != len(exp) {
+		t.Fatalf("got %v bytes, expected %v", len(got), len(exp))
+	}
+	var bits int
+	for i := range got {
+		for x := got[i] ^ exp[i]; x > 0; x &= x - 1 {
+			bits++
+		}
+	}
+	if bits != 1 {
+		t.Errorf("%v bits differ\n%v\n%v", bits, got, exp)
+	}
+	// fixed header is intact
+	if !bytes.Equal(got[:2], exp[:2]) {
+		t.Error("corrupt fixed header", got[:2])
+	}
+	if !bytes.Equal(corrupt(7), got) {
+		t.Error("same seed, different result")
+	}
+}
+
+func TestConn_cut(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	conn.Add(Cut(Type(mq.PUBACK)))
+	result := make(chan error, 1)
+	go func() {
+		var err error
+		for _, p := range packets() {
+			if _, err = p.WriteTo(conn); err != nil {
+				break
+			}
+		}
+		result <- err
+	}()
+
+	if _, err := mq.ReadPacket(server); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mq.ReadPacket(server); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+	if err := <-result; !errors.Is(err, net.ErrClosed) {
+		t.Error("expected net.ErrClosed, got", err)
+	}
+}
+
+func TestConn_cutReceived(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	cut := Cut(Type(mq.PUBLISH))
+	cut.SetReceived(true)
+	conn.Add(cut)
+	go writePackets(server)
+
+	if _, err := mq.ReadPacket(conn); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Error("expected io.EOF, got", err)
+	}
+}
+
+func TestConn_probability(t *testing.T) {
+	run := func(seed int64) []string {
+		r := Drop(Any)
+		r.SetProbability(0.5)
+		data := transfer(t, []*Rule{r}, func(w io.Writer) {
+			for i := 0; i < 20; i++ {
+				writePackets(w)
+			}
+		}, seed)
+		return types(t, data)
+	}
+	got := run(3)
+	if len(got) == 0 || len(got) == 60 {
+		t.Errorf("dropped %v of 60", 60-len(got))
+	}
+	if !reflect.DeepEqual(run(3), got) {
+		t.Error("same seed, different result")
+	}
+	if reflect.DeepEqual(run(4), got) {
+		t.Error("different seed, same result")
+	}
+}
+
+func TestConn_SetSeed(t *testing.T) {
+	conn := NewConn(nil)
+	if conn.Seed() != 1 {
+		t.Error("default seed", conn.Seed())
+	}
+	conn.SetSeed(9)
+	if conn.Seed() != 9 {
+		t.Error("SetSeed")
+	}
+	// directions do not share a source
+	if conn.sent.rand == conn.received.rand {
+		t.Fatal("shared random source")
+	}
+	conn.sent.rand.Int63()
+	if a, b := conn.sent.rand.Int63(), conn.received.rand.Int63(); a == b {
+		t.Error("received source advanced by sent packets")
+	}
+}
+
+// ----------------------------------------
+
+// transfer writes using write to a Conn with the given rules and
+// returns what arrives at the other end. An optional seed sets the
+// seed of the Conn.
+func transfer(t *testing.T, rules []*Rule, write func(io.Writer), seed ...int64) []byte {
+	t.Helper()
+	client, server := net.Pipe()
+	conn := NewConn(client)
+	for _, v := range seed {
+		conn.SetSeed(v)
+	}
+	conn.Add(rules...)
+	go func() {
+		write(conn)
+		conn.Close()
+	}()
+	data, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func packets() []mq.Packet {
+	pub := mq.Pub(1, "a/b", "hello")
+	pub.SetPacketID(1)
+	ack := mq.NewPubAck()
+	ack.SetPacketID(1)
+	return []mq.Packet{pub, ack, mq.NewPingReq()}
+}
+
+func writePackets(w io.Writer) {
+	for _, p := range packets() {
+		p.WriteTo(w)
+	}
+}
+
+// types returns the type names of packets in data.
+func types(t *testing.T, data []byte) []string {
+	t.Helper()
+	var res []string
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		p, err := mq.ReadPacket(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, strings.Fields(p.String())[0])
+	}
+	return res
+}
+
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
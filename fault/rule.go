@@ -0,0 +1,194 @@
+package fault
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+// Drop returns a rule dropping matching packets.
+func Drop(m Match) *Rule {
+	return newRule(m, func(out *result, _ *stream, _ *rand.Rand) {
+		out.frames = nil
+	})
+}
+
+// Delay returns a rule delaying matching packets by d. Following
+// packets in the same direction wait for the delayed one.
+func Delay(m Match, d time.Duration) *Rule {
+	return newRule(m, func(out *result, _ *stream, _ *rand.Rand) {
+		out.delay += d
+	})
+}
+
+// Duplicate returns a rule sending matching packets twice.
+func Duplicate(m Match) *Rule {
+	return newRule(m, func(out *result, _ *stream, _ *rand.Rand) {
+		out.frames = append(out.frames, out.frames...)
+	})
+}
+
+// Reorder returns a rule holding matching packets until the next
+// packet in the same direction has passed. Held packets are lost if
+// no packet follows.
+func Reorder(m Match) *Rule {
+	return newRule(m, func(out *result, s *stream, _ *rand.Rand) {
+		s.held = append(s.held, out.frames...)
+		out.frames = nil
+	})
+}
+
+// Corrupt returns a rule flipping one random bit after the fixed
+// header of matching packets, or in the first byte if there is no
+// data after the header.
+func Corrupt(m Match) *Rule {
+	return newRule(m, func(out *result, _ *stream, r *rand.Rand) {
+		for i, frame := range out.frames {
+			c := append([]byte{}, frame...)
+			start, _, _ := mq.FixedHeaderLen(c)
+			if start >= len(c) {
+				start = 0
+			}
+			c[start+r.Intn(len(c)-start)] ^= 1 << r.Intn(8)
+			out.frames[i] = c
+		}
+	})
+}
+
+// Cut returns a rule sending a random part of matching packets,
+// at least one byte but not all, and then closing the connection.
+func Cut(m Match) *Rule {
+	return newRule(m, func(out *result, _ *stream, r *rand.Rand) {
+		frame := out.frames[0]
+		n := 1
+		if len(frame) > 2 {
+			n += r.Intn(len(frame) - 1)
+		}
+		out.frames = [][]byte{frame[:n]}
+		out.cut = true
+	})
+}
+
+func newRule(m Match, fault func(*result, *stream, *rand.Rand)) *Rule {
+	return &Rule{
+		match:       m,
+		fault:       fault,
+		probability: 1,
+	}
+}
+
+// Rule applies a fault to matching packets.
+type Rule struct {
+	match Match
+	fault func(*result, *stream, *rand.Rand)
+
+	probability float64
+	skip        int
+	times       int
+	received    bool
+
+	matched int // packets matched so far
+	applied int // faults applied so far
+}
+
+// SetProbability sets the probability, 0 to 1, that the fault is
+// applied to a matching packet, default 1.
+func (r *Rule) SetProbability(v float64) { r.probability = v }
+func (r *Rule) Probability() float64     { return r.probability }
+
+// SetSkip sets the number of matching packets to pass before the
+// fault is applied, e.g. 1 to drop the second PUBACK.
+func (r *Rule) SetSkip(v int) { r.skip = v }
+func (r *Rule) Skip() int     { return r.skip }
+
+// SetTimes limits the number of times the fault is applied, 0 means
+// no limit.
+func (r *Rule) SetTimes(v int) { r.times = v }
+func (r *Rule) Times() int     { return r.times }
+
+// SetReceived applies the rule to packets read from the connection
+// instead of written to it.
+func (r *Rule) SetReceived(v bool) { r.received = v }
+func (r *Rule) Received() bool     { return r.received }
+
+// Applied returns the number of times the fault was applied.
+func (r *Rule) Applied() int { return r.applied }
+
+func (r *Rule) applies(p mq.Packet, rnd *rand.Rand) bool {
+	if r.times > 0 && r.applied >= r.times {
+		return false
+	}
+	if !r.match(p) {
+		return false
+	}
+	r.matched++
+	if r.matched <= r.skip {
+		return false
+	}
+	if r.probability < 1 && rnd.Float64() >= r.probability {
+		return false
+	}
+	r.applied++
+	return true
+}
+
+// ----------------------------------------
+
+// Match returns true if a rule applies to p.
+type Match func(p mq.Packet) bool
+
+// Type returns a match of packets of the given types, e.g.
+// mq.PUBLISH.
+func Type(types ...byte) Match {
+	return func(p mq.Packet) bool {
+		v := packetType(p)
+		for _, t := range types {
+			if v == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// packetType returns the type of p as found in the first byte of the
+// fixed header, without encoding p.
+func packetType(p mq.Packet) byte {
+	switch p.(type) {
+	case *mq.Connect:
+		return mq.CONNECT
+	case *mq.ConnAck:
+		return mq.CONNACK
+	case *mq.Publish, *mq.PublishStream:
+		return mq.PUBLISH
+	case *mq.PubAck:
+		return mq.PUBACK
+	case *mq.PubRec:
+		return mq.PUBREC
+	case *mq.PubRel:
+		return mq.PUBREL
+	case *mq.PubComp:
+		return mq.PUBCOMP
+	case *mq.Subscribe:
+		return mq.SUBSCRIBE
+	case *mq.SubAck:
+		return mq.SUBACK
+	case *mq.Unsubscribe:
+		return mq.UNSUBSCRIBE
+	case *mq.UnsubAck:
+		return mq.UNSUBACK
+	case *mq.PingReq:
+		return mq.PINGREQ
+	case *mq.PingResp:
+		return mq.PINGRESP
+	case *mq.Disconnect:
+		return mq.DISCONNECT
+	case *mq.Auth:
+		return mq.AUTH
+	}
+	return mq.UNDEFINED
+}
+
+// Any matches all packets.
+func Any(mq.Packet) bool { return true }
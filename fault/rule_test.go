@@ -0,0 +1,59 @@
+package fault
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+func TestRule(t *testing.T) {
+	r := Drop(Any)
+	if r.Probability() != 1 {
+		t.Error("default probability", r.Probability())
+	}
+	r.SetProbability(0.5)
+	r.SetSkip(2)
+	r.SetTimes(3)
+	r.SetReceived(true)
+	if r.Probability() != 0.5 || r.Skip() != 2 || r.Times() != 3 || !r.Received() {
+		t.Errorf("%+v", r)
+	}
+}
+
+func TestRule_Applied(t *testing.T) {
+	r := Drop(Type(mq.PINGREQ))
+	r.SetSkip(1)
+	r.SetTimes(2)
+	data := transfer(t, []*Rule{r}, func(w io.Writer) {
+		for i := 0; i < 4; i++ {
+			mq.NewPingReq().WriteTo(w)
+		}
+	})
+	if r.Applied() != 2 || len(data) != 4 {
+		t.Errorf("applied %v, %v bytes arrived", r.Applied(), len(data))
+	}
+}
+
+func TestType(t *testing.T) {
+	m := Type(mq.PUBACK, mq.PINGREQ)
+	if !m(mq.NewPubAck()) || !m(mq.NewPingReq()) || m(mq.NewPingResp()) {
+		t.Error("Type")
+	}
+
+	packets := []mq.Packet{
+		mq.NewConnect(), mq.NewConnAck(), mq.NewPublish(), mq.NewPubAck(),
+		mq.NewPubRec(), mq.NewPubRel(), mq.NewPubComp(), mq.NewSubscribe(),
+		mq.NewSubAck(), mq.NewUnsubscribe(), mq.NewUnsubAck(),
+		mq.NewPingReq(), mq.NewPingResp(), mq.NewDisconnect(), mq.NewAuth(),
+		mq.NewPublishStream(),
+	}
+	for _, p := range packets {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		if got, exp := packetType(p), buf.Bytes()[0]&0xf0; got != exp {
+			t.Errorf("%T: got %v, expected %v", p, got, exp)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewFramer returns a framer reading packets from r, with
+// resynchronization disabled.
+func NewFramer(r io.Reader) *Framer {
+	return &Framer{
+		r:       r,
+		maxSize: 64 << 10,
+	}
+}
+
+// Framer splits a byte stream into packets in wire format, similar to
+// bufio.Scanner. Use it on lossy transports, e.g. serial lines, where
+// one corrupt byte would make ReadPacket lose track of the stream.
+//
+// Scan returns false on a framing error, which is then returned by
+// Err. Without resynchronization the error is permanent. With it,
+// Scan may be called again and continues with the next plausible
+// packet, dropping bytes until one is found.
+type Framer struct {
+	r io.Reader
+
+	resync  bool
+	maxSize int
+
+	buf   []byte // data read but not yet consumed is buf[off:]
+	off   int
+	pos   int64 // position in stream of buf[off]
+	eof   bool
+	frame []byte
+
+	// true while dropping bytes after a reported error
+	resyncing bool
+	done      bool
+	err       error
+	stats     FramerStats
+}
+
+// SetResync enables resynchronization after framing errors. While
+// resynchronizing, frames are only accepted if they are well formed
+// packets.
+func (f *Framer) SetResync(v bool) { f.resync = v }
+func (f *Framer) Resync() bool     { return f.resync }
+
+// SetMaxSize sets the largest remaining length accepted, default
+// 64KiB. Larger values are framing errors.
+func (f *Framer) SetMaxSize(v int) { f.maxSize = v }
+func (f *Framer) MaxSize() int     { return f.maxSize }
+
+// Scan advances to the next packet, available through Bytes. It
+// returns false at the end of the stream or on an error.
+func (f *Framer) Scan() bool {
+	f.frame = nil
+	if f.done {
+		return false
+	}
+	f.err = nil
+	for {
+		n, err := f.next()
+		if err == nil {
+			f.frame = f.buf[f.off : f.off+n]
+			f.consume(n)
+			f.resyncing = false
+			f.stats.Frames++
+			return true
+		}
+		var ferr *FrameError
+		switch {
+		case errors.Is(err, io.EOF) && len(f.buf) == f.off:
+			f.done = true
+			return false
+
+		case !errors.As(err, &ferr):
+			f.done = true
+			f.err = err
+			return false
+
+		case !f.resync:
+			f.done = true
+			f.err = err
+			f.stats.Errors++
+			return false
+		}
+		// resynchronize by dropping one byte at a time
+		reported := f.resyncing
+		f.resyncing = true
+		f.consume(1)
+		f.stats.Dropped++
+		if !reported {
+			f.err = err
+			f.stats.Errors++
+			return false
+		}
+	}
+}
+
+// Bytes returns the packet found by the last call to Scan. The slice
+// may be overwritten by the next call to Scan.
+func (f *Framer) Bytes() []byte { return f.frame }
+
+// Packet returns the packet found by the last call to Scan.
+func (f *Framer) Packet() (Packet, error) {
+	return ReadPacket(bytes.NewReader(f.frame))
+}
+
+// Err returns the error of the last call to Scan, nil at the end of
+// the stream.
+func (f *Framer) Err() error { return f.err }
+
+// Stats returns statistics of the stream so far.
+func (f *Framer) Stats() FramerStats { return f.stats }
+
+// next returns the size of the packet starting at buf[off].
+func (f *Framer) next() (int, error) {
+	if err := f.need(1); err != nil {
+		return 0, err
+	}
+	fixed := f.buf[f.off]
+	if !plausibleFixed(fixed) {
+		return 0, f.frameErr(fmt.Errorf("invalid fixed header %08b", fixed))
+	}
+
+	// remaining length
+	var n, remainingLen int
+	for i := 2; n == 0; i++ {
+		if err := f.need(i); err != nil {
+			return 0, f.truncated(err)
+		}
+		var err error
+		n, remainingLen, err = FixedHeaderLen(f.buf[f.off : f.off+i])
+		if err != nil {
+			return 0, f.frameErr(err)
+		}
+	}
+	if remainingLen > f.maxSize {
+		return 0, f.frameErr(fmt.Errorf(
+			"remaining length %v exceeds max size %v", remainingLen, f.maxSize,
+		))
+	}
+
+	n += remainingLen
+	if err := f.need(n); err != nil {
+		return 0, f.truncated(err)
+	}
+	if f.resyncing {
+		if err := wellFormedFrame(f.buf[f.off : f.off+n]); err != nil {
+			return 0, f.frameErr(err)
+		}
+	}
+	return n, nil
+}
+
+// need reads until at least n bytes are buffered.
+func (f *Framer) need(n int) error {
+	for len(f.buf)-f.off < n {
+		if f.eof {
+			return io.EOF
+		}
+		if f.off > 0 {
+			m := copy(f.buf, f.buf[f.off:])
+			f.buf = f.buf[:m]
+			f.off = 0
+		}
+		if cap(f.buf)-len(f.buf) < 512 {
+			f.buf = append(f.buf, make([]byte, 4096)...)[:len(f.buf)]
+		}
+		m, err := f.r.Read(f.buf[len(f.buf):cap(f.buf)])
+		f.buf = f.buf[:len(f.buf)+m]
+		if errors.Is(err, io.EOF) {
+			f.eof = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Framer) consume(n int) {
+	f.off += n
+	f.pos += int64(n)
+}
+
+func (f *Framer) frameErr(err error) *FrameError {
+	return &FrameError{Offset: f.pos, Err: err}
+}
+
+// truncated returns a FrameError if the stream ended within a
+// packet.
+func (f *Framer) truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		return f.frameErr(io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// plausibleFixed returns true if v is a valid first byte of a
+// packet.
+func plausibleFixed(v byte) bool {
+	switch v & 0b1111_0000 {
+	case 0:
+		return false
+	case PUBLISH:
+		return v&QoS3 != QoS3
+	case PUBREL, SUBSCRIBE, UNSUBSCRIBE:
+		return v&0b0000_1111 == 0b0010
+	}
+	return v&0b0000_1111 == 0
+}
+
+// wellFormedFrame returns an error if data is not exactly one well
+// formed packet.
+func wellFormedFrame(data []byte) error {
+	p, err := ReadWellFormed(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if _, ok := p.(*Undefined); ok {
+		return fmt.Errorf("undefined packet")
+	}
+	return nil
+}
+
+// ----------------------------------------
+
+// FramerStats holds counters of a Framer.
+type FramerStats struct {
+	// Frames is the number of packets found
+	Frames int64
+
+	// Errors is the number of framing errors
+	Errors int64
+
+	// Dropped is the number of bytes skipped while resynchronizing
+	Dropped int64
+}
+
+// FrameError is returned by Framer.Err when the stream does not
+// contain a plausible packet.
+type FrameError struct {
+	// Offset in stream where the packet was expected
+	Offset int64
+	Err    error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("frame at offset %v: %v", e.Offset, e.Err)
+}
+
+func (e *FrameError) Unwrap() error { return e.Err }
@@ -0,0 +1,199 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func ExampleFramer() {
+	var stream bytes.Buffer
+	Pub(0, "a/b", "gopher").WriteTo(&stream)
+	stream.Write([]byte{0x00, 0x13, 0x37}) // noise
+	NewPingReq().WriteTo(&stream)
+
+	f := NewFramer(&stream)
+	f.SetResync(true)
+	for {
+		if f.Scan() {
+			p, _ := f.Packet()
+			fmt.Println(p)
+			continue
+		}
+		if err := f.Err(); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		break
+	}
+	fmt.Printf("%+v\n", f.Stats())
+	// output:
+	// PUBLISH ---- p0 a/b 14 bytes
+	// frame at offset 14: invalid fixed header 00000000
+	// PINGREQ ---- 2 bytes
+	// {Frames:2 Errors:1 Dropped:3}
+}
+
+func TestFramer(t *testing.T) {
+	var stream bytes.Buffer
+	var frames [][]byte
+	for _, p := range annotatedPackets() {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		frames = append(frames, buf.Bytes())
+		stream.Write(buf.Bytes())
+	}
+
+	// one byte at a time
+	f := NewFramer(io.LimitReader(&oneByteReader{&stream}, int64(stream.Len())))
+	var i int
+	for f.Scan() {
+		if !bytes.Equal(f.Bytes(), frames[i]) {
+			t.Errorf("frame %v\n% x\n% x", i, f.Bytes(), frames[i])
+		}
+		if _, err := f.Packet(); err != nil {
+			t.Error(err)
+		}
+		i++
+	}
+	if err := f.Err(); err != nil {
+		t.Error(err)
+	}
+	if i != len(frames) {
+		t.Errorf("got %v frames, expected %v", i, len(frames))
+	}
+	if s := f.Stats(); s.Frames != int64(len(frames)) || s.Errors != 0 || s.Dropped != 0 {
+		t.Errorf("%+v", s)
+	}
+	if f.Scan() {
+		t.Error("Scan after end of stream")
+	}
+}
+
+func TestFramer_noResync(t *testing.T) {
+	var stream bytes.Buffer
+	NewPingReq().WriteTo(&stream)
+	stream.WriteByte(0xff) // AUTH with reserved flags
+	NewPingReq().WriteTo(&stream)
+
+	f := NewFramer(&stream)
+	if !f.Scan() {
+		t.Fatal(f.Err())
+	}
+	if f.Scan() {
+		t.Fatal("expected framing error")
+	}
+	var ferr *FrameError
+	if !errors.As(f.Err(), &ferr) || ferr.Offset != 2 {
+		t.Errorf("expected FrameError at offset 2, got %v", f.Err())
+	}
+	if f.Scan() {
+		t.Error("framing error should be permanent")
+	}
+}
+
+func TestFramer_resync(t *testing.T) {
+	var stream bytes.Buffer
+	packets := annotatedPackets()
+	for i, p := range packets {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		data := buf.Bytes()
+		if i == 3 {
+			data[0] = 0 // corrupt fixed header
+		}
+		stream.Write(data)
+	}
+
+	f := NewFramer(&stream)
+	f.SetResync(true)
+	var frames, errs int
+	for {
+		if f.Scan() {
+			frames++
+			continue
+		}
+		if f.Err() == nil {
+			break
+		}
+		errs++
+	}
+	if frames != len(packets)-1 || errs != 1 {
+		t.Errorf("got %v frames, %v errors", frames, errs)
+	}
+	if s := f.Stats(); s.Dropped == 0 {
+		t.Errorf("%+v", s)
+	}
+}
+
+func TestFramer_truncated(t *testing.T) {
+	var buf bytes.Buffer
+	Pub(0, "a/b", "gopher").WriteTo(&buf)
+	data := buf.Bytes()
+
+	f := NewFramer(bytes.NewReader(data[:len(data)-1]))
+	if f.Scan() {
+		t.Fatal("expected error")
+	}
+	if err := f.Err(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+
+	// dropped while resynchronizing
+	f = NewFramer(bytes.NewReader(data[:len(data)-1]))
+	f.SetResync(true)
+	for f.Scan() || f.Err() != nil {
+	}
+	if s := f.Stats(); s.Frames != 0 || s.Dropped != int64(len(data)-1) {
+		t.Errorf("%+v", s)
+	}
+}
+
+func TestFramer_maxSize(t *testing.T) {
+	var buf bytes.Buffer
+	Pub(0, "a/b", "gopher").WriteTo(&buf)
+
+	f := NewFramer(&buf)
+	eq(t, f.SetMaxSize, f.MaxSize, 4)
+	eq(t, f.SetResync, f.Resync, false)
+	if f.Scan() {
+		t.Fatal("expected error")
+	}
+	if err := f.Err(); err == nil {
+		t.Error("expected error on max size")
+	}
+}
+
+func TestFramer_readError(t *testing.T) {
+	broken := fmt.Errorf("broken")
+	f := NewFramer(&errReader{broken})
+	f.SetResync(true)
+	if f.Scan() {
+		t.Fatal("expected error")
+	}
+	if err := f.Err(); !errors.Is(err, broken) {
+		t.Error("expected read error, got", err)
+	}
+	if f.Scan() {
+		t.Error("read error should be permanent")
+	}
+}
+
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.r.Read(p[:1])
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) { return 0, r.err }
@@ -8,6 +8,7 @@ require (
 	github.com/gregoryv/asserter v0.4.2
 	github.com/gregoryv/draw v0.32.0
 	github.com/gregoryv/web v0.25.0
+	golang.org/x/net v0.35.0
 )
 
 require (
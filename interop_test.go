@@ -0,0 +1,791 @@
+package mq_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/gregoryv/mq"
+)
+
+// TestInterop_paho builds random packets of every type in both mq
+// and paho.golang and checks that each library reads the other ones
+// wire format and writes it again byte by byte. Packets listed in
+// normalise are compared after sorting their properties, as the
+// libraries write properties in different order.
+//
+// Known limitations of paho.golang v0.11.0 are avoided
+//
+//   - will properties other than WillDelayInterval and user
+//     properties are neither written nor read
+//   - subscribe options other than QoS are not read
+//   - publish flags retain and duplicate are only read into the
+//     fixed header
+//   - a read packet cannot be written again, only its content
+//   - reserved flags of AUTH are set to 1
+func TestInterop_paho(t *testing.T) {
+	for _, c := range interopCases {
+		for seed := int64(0); seed < 50; seed++ {
+			g := &gen{rand.New(rand.NewSource(seed))}
+			a, b := c.build(g)
+			for _, diff := range interop(a, b) {
+				t.Errorf("%s seed %v: %s", c.name, seed, diff)
+			}
+		}
+	}
+}
+
+// interop returns differences found when packet a, written by mq, is
+// read and written again by paho and packet b, written by paho, is
+// read and written again by mq.
+func interop(a mq.Packet, b *packets.ControlPacket) []string {
+	var A, B bytes.Buffer
+	if _, err := a.WriteTo(&A); err != nil {
+		return []string{err.Error()}
+	}
+	if _, err := b.WriteTo(&B); err != nil {
+		return []string{err.Error()}
+	}
+	var diffs []string
+
+	// mq -> paho -> bytes
+	pb, err := packets.ReadPacket(bytes.NewReader(A.Bytes()))
+	if err != nil {
+		diffs = append(diffs, fmt.Sprintf("paho read: %v\n% x", err, A.Bytes()))
+	} else {
+		if p, ok := pb.Content.(*packets.Publish); ok {
+			p.Retain = pb.Flags&1 == 1
+			p.Duplicate = pb.Flags&8 == 8
+		}
+		for _, d := range fieldDiff("", reflect.ValueOf(b.Content), reflect.ValueOf(pb.Content)) {
+			diffs = append(diffs, "paho read "+d)
+		}
+		var again bytes.Buffer
+		pb.Content.(io.WriterTo).WriteTo(&again)
+		if d := wireDiff(A.Bytes(), again.Bytes()); d != "" {
+			diffs = append(diffs, "paho write mq "+d)
+		}
+	}
+
+	// paho -> mq -> bytes
+	pa, err := mq.ReadPacket(bytes.NewReader(B.Bytes()))
+	if err != nil {
+		diffs = append(diffs, fmt.Sprintf("mq read: %v\n% x", err, B.Bytes()))
+	} else {
+		for _, d := range mq.Diff(a, pa) {
+			diffs = append(diffs, "mq read "+d.String())
+		}
+		var again bytes.Buffer
+		pa.WriteTo(&again)
+		if d := wireDiff(B.Bytes(), again.Bytes()); d != "" {
+			diffs = append(diffs, "mq write paho "+d)
+		}
+	}
+	return diffs
+}
+
+// wireDiff returns both packets in hex if they differ after being
+// normalised.
+func wireDiff(exp, got []byte) string {
+	if bytes.Equal(normalise(exp), normalise(got)) {
+		return ""
+	}
+	return fmt.Sprintf("\n% x\n% x, expected", got, exp)
+}
+
+// normalise returns packet data with differences allowed by the
+// specification removed
+//
+//   - CONNECT, CONNACK and PUBLISH have their properties, also the
+//     will properties, sorted by identifier as paho writes them in
+//     a different order
+//   - PUBACK, PUBREC, PUBREL and PUBCOMP are written in full as mq
+//     leaves out a Success reason code and an empty property length
+//     which paho writes, see 3.4.2.1
+func normalise(data []byte) []byte {
+	typ := data[0] >> 4
+	_, n := uvarint(data[1:])
+	at := 1 + n // variable header
+	v := append([]byte(nil), data...)
+	switch typ {
+	case packets.CONNECT:
+		flags := v[at+7]
+		at = sortProps(v, at+10)
+		at += 2 + int(binary.BigEndian.Uint16(v[at:])) // client id
+		if flags&0x04 != 0 {
+			sortProps(v, at) // will properties
+		}
+
+	case packets.CONNACK:
+		sortProps(v, at+2)
+
+	case packets.PUBLISH:
+		at += 2 + int(binary.BigEndian.Uint16(v[at:])) // topic name
+		if qos := v[0] >> 1 & 3; qos > 0 {
+			at += 2
+		}
+		sortProps(v, at)
+
+	case packets.PUBACK, packets.PUBREC, packets.PUBREL, packets.PUBCOMP:
+		// packet id, reason code and property length
+		if rest := len(v) - at; rest < 4 {
+			v = append(v, make([]byte, 4-rest)...)
+			v[1] = 4
+		}
+	}
+	return v
+}
+
+// sortProps sorts the properties in v starting at the property length
+// on position at by identifier, keeping the order of user
+// properties. Returns the position after the properties.
+func sortProps(v []byte, at int) int {
+	size, n := uvarint(v[at:])
+	start, end := at+n, at+n+size
+	var props [][]byte
+	for i := start; i < end; {
+		w := propWidth(v[i:])
+		props = append(props, append([]byte(nil), v[i:i+w]...))
+		i += w
+	}
+	sort.SliceStable(props, func(i, j int) bool {
+		return props[i][0] < props[j][0]
+	})
+	copy(v[start:end], bytes.Join(props, nil))
+	return end
+}
+
+// propWidth returns the width of the property at the start of v.
+func propWidth(v []byte) int {
+	str := func(i int) int { return 2 + int(binary.BigEndian.Uint16(v[i:])) }
+	switch v[0] {
+	case 0x01, 0x17, 0x19, 0x24, 0x25, 0x28, 0x29, 0x2A:
+		return 2
+	case 0x13, 0x21, 0x22, 0x23:
+		return 3
+	case 0x02, 0x11, 0x18, 0x27:
+		return 5
+	case 0x0B:
+		_, n := uvarint(v[1:])
+		return 1 + n
+	case 0x26:
+		return 1 + str(1) + str(1+str(1))
+	default: // strings and binary data
+		return 1 + str(1)
+	}
+}
+
+// uvarint returns the variable byte integer at the start of v and
+// its width.
+func uvarint(v []byte) (int, int) {
+	var x, shift int
+	for i, b := range v {
+		x |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return x, i + 1
+		}
+		shift += 7
+	}
+	return x, len(v)
+}
+
+// fieldDiff returns the fields that differ between exp and got, nil
+// pointers to structs are treated as empty structs.
+func fieldDiff(name string, exp, got reflect.Value) []string {
+	if exp.Kind() == reflect.Ptr && exp.Type().Elem().Kind() == reflect.Struct {
+		return fieldDiff(name, deref(exp), deref(got))
+	}
+	if exp.Kind() == reflect.Interface {
+		return fieldDiff(name, exp.Elem(), got.Elem())
+	}
+	if exp.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(exp.Interface(), got.Interface()) {
+			return []string{fmt.Sprintf("%s: %v, expected %v",
+				name, show(got), show(exp),
+			)}
+		}
+		return nil
+	}
+	var diffs []string
+	for i := 0; i < exp.NumField(); i++ {
+		f := exp.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		diffs = append(diffs, fieldDiff(
+			strings.TrimPrefix(name+"."+f.Name, "."), exp.Field(i), got.Field(i),
+		)...)
+	}
+	return diffs
+}
+
+func deref(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.New(v.Type().Elem()).Elem()
+	}
+	return v.Elem()
+}
+
+func show(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem().Interface()
+	}
+	return v.Interface()
+}
+
+// ----------------------------------------
+
+var interopCases = []struct {
+	name  string
+	build func(*gen) (mq.Packet, *packets.ControlPacket)
+}{
+	{"CONNECT", interopConnect},
+	{"CONNACK", interopConnAck},
+	{"PUBLISH", interopPublish},
+	{"PUBACK", interopPubResp(packets.PUBACK)},
+	{"PUBREC", interopPubResp(packets.PUBREC)},
+	{"PUBREL", interopPubResp(packets.PUBREL)},
+	{"PUBCOMP", interopPubResp(packets.PUBCOMP)},
+	{"SUBSCRIBE", interopSubscribe},
+	{"SUBACK", interopSubAck(packets.SUBACK)},
+	{"UNSUBSCRIBE", interopUnsubscribe},
+	{"UNSUBACK", interopSubAck(packets.UNSUBACK)},
+	{"PINGREQ", func(*gen) (mq.Packet, *packets.ControlPacket) {
+		return mq.NewPingReq(), packets.NewControlPacket(packets.PINGREQ)
+	}},
+	{"PINGRESP", func(*gen) (mq.Packet, *packets.ControlPacket) {
+		return mq.NewPingResp(), packets.NewControlPacket(packets.PINGRESP)
+	}},
+	{"DISCONNECT", interopDisconnect},
+	{"AUTH", interopAuth},
+}
+
+func interopConnect(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a := mq.NewConnect()
+	b := packets.NewControlPacket(packets.CONNECT)
+	c := b.Content.(*packets.Connect)
+	props := c.Properties
+
+	v := g.str(1)
+	a.SetClientID(v)
+	c.ClientID = v
+
+	k := g.u16()
+	a.SetKeepAlive(k)
+	c.KeepAlive = k
+
+	clean := g.flag()
+	a.SetCleanStart(clean)
+	c.CleanStart = clean
+
+	if g.flag() {
+		v := g.str(1)
+		a.SetUsername(v)
+		c.Username, c.UsernameFlag = v, true
+	}
+	if g.flag() {
+		v := []byte(g.str(1))
+		a.SetPassword(v)
+		c.Password, c.PasswordFlag = v, true
+	}
+
+	u32 := g.u32()
+	a.SetSessionExpiryInterval(u32)
+	props.SessionExpiryInterval = ptr(u32)
+
+	u16 := g.u16()
+	a.SetReceiveMax(u16)
+	props.ReceiveMaximum = ptr(u16)
+
+	u32 = g.u32()
+	a.SetMaxPacketSize(u32)
+	props.MaximumPacketSize = ptr(u32)
+
+	u16 = g.u16()
+	a.SetTopicAliasMax(u16)
+	props.TopicAliasMaximum = ptr(u16)
+
+	on := g.flag()
+	a.SetRequestResponseInfo(on)
+	props.RequestResponseInfo = flagPtr(on)
+
+	on = g.flag()
+	a.SetRequestProblemInfo(on)
+	props.RequestProblemInfo = flagPtr(on)
+
+	if g.flag() {
+		v := g.str(1)
+		a.SetAuthMethod(v)
+		props.AuthMethod = v
+		data := g.bytes()
+		a.SetAuthData(data)
+		props.AuthData = data
+	}
+	props.User = g.users(&a.UserProperties)
+
+	if g.flag() {
+		will := mq.NewPublish()
+		qos := uint8(g.Intn(3))
+		will.SetQoS(qos)
+		c.WillQOS = qos
+
+		on := g.flag()
+		will.SetRetain(on)
+		c.WillRetain = on
+
+		v := g.str(1)
+		will.SetTopicName(v)
+		c.WillTopic = v
+
+		payload := []byte(g.str(1))
+		will.SetPayload(payload)
+		c.WillMessage = payload
+
+		c.WillProperties = &packets.Properties{}
+		c.WillProperties.User = g.users(&will.UserProperties)
+		a.SetWill(will)
+		c.WillFlag = true
+
+		u32 := g.u32()
+		a.SetWillDelayInterval(u32)
+		c.WillProperties.WillDelayInterval = ptr(u32)
+	}
+	return a, b
+}
+
+func interopConnAck(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a := mq.NewConnAck()
+	b := packets.NewControlPacket(packets.CONNACK)
+	c := b.Content.(*packets.Connack)
+	c.Properties = &packets.Properties{}
+	props := c.Properties
+
+	code := g.reasonCode(mq.CONNACK)
+	a.SetReasonCode(code)
+	c.ReasonCode = byte(code)
+
+	on := g.flag() && code == mq.Success
+	a.SetSessionPresent(on)
+	c.SessionPresent = on
+
+	u32 := g.u32()
+	a.SetSessionExpiryInterval(u32)
+	props.SessionExpiryInterval = ptr(u32)
+
+	u16 := g.u16()
+	a.SetReceiveMax(u16)
+	props.ReceiveMaximum = ptr(u16)
+
+	qos := uint8(g.Intn(2))
+	a.SetMaxQoS(qos)
+	props.MaximumQOS = &qos // set capabilities are written, also 0
+
+	on = g.flag()
+	a.SetRetainAvailable(on)
+	props.RetainAvailable = capPtr(on)
+
+	u32 = g.u32()
+	a.SetMaxPacketSize(u32)
+	props.MaximumPacketSize = ptr(u32)
+
+	v := g.str(0)
+	a.SetAssignedClientID(v)
+	props.AssignedClientID = v
+
+	u16 = g.u16()
+	a.SetTopicAliasMax(u16)
+	props.TopicAliasMaximum = ptr(u16)
+
+	v = g.str(0)
+	a.SetReasonString(v)
+	props.ReasonString = v
+
+	on = g.flag()
+	a.SetWildcardSubAvailable(on)
+	props.WildcardSubAvailable = capPtr(on)
+
+	on = g.flag()
+	a.SetSubIdentifiersAvailable(on)
+	props.SubIDAvailable = capPtr(on)
+
+	on = g.flag()
+	a.SetSharedSubAvailable(on)
+	props.SharedSubAvailable = capPtr(on)
+
+	u16 = g.u16()
+	a.SetServerKeepAlive(u16)
+	props.ServerKeepAlive = ptr(u16)
+
+	v = g.str(0)
+	a.SetResponseInformation(v)
+	props.ResponseInfo = v
+
+	v = g.str(0)
+	a.SetServerReference(v)
+	props.ServerReference = v
+
+	if g.flag() {
+		v := g.str(1)
+		a.SetAuthMethod(v)
+		props.AuthMethod = v
+		data := g.bytes()
+		a.SetAuthData(data)
+		props.AuthData = data
+	}
+	props.User = g.users(&a.UserProperties)
+	return a, b
+}
+
+func interopPublish(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a, c := interopPublishContent(g)
+	b := packets.NewControlPacket(packets.PUBLISH)
+	b.Content = c
+	b.FixedHeader.Flags = c.QoS << 1
+	if c.Duplicate {
+		b.FixedHeader.Flags |= 1 << 3
+	}
+	if c.Retain {
+		b.FixedHeader.Flags |= 1
+	}
+	return a, b
+}
+
+func interopPublishContent(g *gen) (*mq.Publish, *packets.Publish) {
+	a := mq.NewPublish()
+	c := &packets.Publish{Properties: &packets.Properties{}}
+	props := c.Properties
+
+	qos := uint8(g.Intn(3))
+	a.SetQoS(qos)
+	c.QoS = qos
+	if qos > 0 {
+		id := g.u16() | 1
+		a.SetPacketID(id)
+		c.PacketID = id
+		dup := g.flag()
+		a.SetDuplicate(dup)
+		c.Duplicate = dup
+	}
+	on := g.flag()
+	a.SetRetain(on)
+	c.Retain = on
+
+	v := g.str(1)
+	a.SetTopicName(v)
+	c.Topic = v
+
+	payload := []byte(g.str(1))
+	a.SetPayload(payload)
+	c.Payload = payload
+
+	on = g.flag()
+	a.SetPayloadFormat(on)
+	props.PayloadFormat = flagPtr(on)
+
+	u32 := g.u32()
+	a.SetMessageExpiryInterval(u32)
+	props.MessageExpiry = ptr(u32)
+
+	u16 := g.u16()
+	a.SetTopicAlias(u16)
+	props.TopicAlias = ptr(u16)
+
+	v = g.str(0)
+	a.SetResponseTopic(v)
+	props.ResponseTopic = v
+
+	if g.flag() {
+		data := g.bytes()
+		a.SetCorrelationData(data)
+		props.CorrelationData = data
+	}
+
+	v = g.str(0)
+	a.SetContentType(v)
+	props.ContentType = v
+
+	if g.flag() {
+		// paho supports one subscription identifier
+		id := int(g.u16()) + 1
+		a.AddSubscriptionID(uint32(id))
+		props.SubscriptionIdentifier = &id
+	}
+	props.User = g.users(&a.UserProperties)
+	return a, c
+}
+
+func interopPubResp(typ byte) func(*gen) (mq.Packet, *packets.ControlPacket) {
+	return func(g *gen) (mq.Packet, *packets.ControlPacket) {
+		var a interface {
+			mq.Packet
+			SetPacketID(uint16)
+			SetReasonCode(mq.ReasonCode)
+			SetReasonString(string)
+			AddUserProp(...string)
+		}
+		var up *mq.UserProperties
+		switch typ {
+		case packets.PUBACK:
+			p := mq.NewPubAck()
+			a, up = p, &p.UserProperties
+		case packets.PUBREC:
+			p := mq.NewPubRec()
+			a, up = p, &p.UserProperties
+		case packets.PUBREL:
+			p := mq.NewPubRel()
+			a, up = p, &p.UserProperties
+		case packets.PUBCOMP:
+			p := mq.NewPubComp()
+			a, up = p, &p.UserProperties
+		}
+		b := packets.NewControlPacket(typ)
+		props := &packets.Properties{}
+
+		id := g.u16() | 1
+		a.SetPacketID(id)
+		code := g.reasonCode(typ << 4)
+		a.SetReasonCode(code)
+		v := g.str(0)
+		a.SetReasonString(v)
+		props.ReasonString = v
+		props.User = g.users(up)
+
+		switch c := b.Content.(type) {
+		case *packets.Puback:
+			c.PacketID, c.ReasonCode, c.Properties = id, byte(code), props
+		case *packets.Pubrec:
+			c.PacketID, c.ReasonCode, c.Properties = id, byte(code), props
+		case *packets.Pubrel:
+			c.PacketID, c.ReasonCode, c.Properties = id, byte(code), props
+		case *packets.Pubcomp:
+			c.PacketID, c.ReasonCode, c.Properties = id, byte(code), props
+		}
+		return a, b
+	}
+}
+
+func interopSubscribe(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a := mq.NewSubscribe()
+	b := packets.NewControlPacket(packets.SUBSCRIBE)
+	c := b.Content.(*packets.Subscribe)
+	c.Properties = &packets.Properties{}
+
+	id := g.u16() | 1
+	a.SetPacketID(id)
+	c.PacketID = id
+
+	if g.flag() {
+		sid := int(g.u16()) + 1
+		a.SetSubscriptionID(sid)
+		c.Properties.SubscriptionIdentifier = &sid
+	}
+	c.Properties.User = g.users(&a.UserProperties)
+
+	// paho keeps subscriptions in a map, more than one would be
+	// written in random order
+	filter := g.str(1)
+	opts := packets.SubOptions{
+		QoS: byte(g.Intn(3)),
+	}
+	a.AddFilters(mq.NewTopicFilter(filter, mq.Opt(opts.Pack())))
+	c.Subscriptions = map[string]packets.SubOptions{filter: opts}
+	return a, b
+}
+
+func interopSubAck(typ byte) func(*gen) (mq.Packet, *packets.ControlPacket) {
+	return func(g *gen) (mq.Packet, *packets.ControlPacket) {
+		var a interface {
+			mq.Packet
+			SetPacketID(uint16)
+			SetReasonString(string)
+			AddReasonCode(mq.ReasonCode)
+		}
+		var up *mq.UserProperties
+		if typ == packets.SUBACK {
+			p := mq.NewSubAck()
+			a, up = p, &p.UserProperties
+		} else {
+			p := mq.NewUnsubAck()
+			a, up = p, &p.UserProperties
+		}
+		b := packets.NewControlPacket(typ)
+		props := &packets.Properties{}
+
+		id := g.u16() | 1
+		a.SetPacketID(id)
+		v := g.str(0)
+		a.SetReasonString(v)
+		props.ReasonString = v
+		props.User = g.users(up)
+
+		reasons := make([]byte, 1+g.Intn(3))
+		for i := range reasons {
+			code := g.reasonCode(typ << 4)
+			a.AddReasonCode(code)
+			reasons[i] = byte(code)
+		}
+
+		switch c := b.Content.(type) {
+		case *packets.Suback:
+			c.PacketID, c.Reasons, c.Properties = id, reasons, props
+		case *packets.Unsuback:
+			c.PacketID, c.Reasons, c.Properties = id, reasons, props
+		}
+		return a, b
+	}
+}
+
+func interopUnsubscribe(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a := mq.NewUnsubscribe()
+	b := packets.NewControlPacket(packets.UNSUBSCRIBE)
+	c := b.Content.(*packets.Unsubscribe)
+	c.Properties = &packets.Properties{}
+
+	id := g.u16() | 1
+	a.SetPacketID(id)
+	c.PacketID = id
+	c.Properties.User = g.users(&a.UserProperties)
+
+	for i := 0; i < 1+g.Intn(3); i++ {
+		v := g.str(1)
+		a.AddFilter(v)
+		c.Topics = append(c.Topics, v)
+	}
+	return a, b
+}
+
+func interopDisconnect(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a := mq.NewDisconnect()
+	b := packets.NewControlPacket(packets.DISCONNECT)
+	c := b.Content.(*packets.Disconnect)
+	c.Properties = &packets.Properties{}
+
+	code := g.reasonCode(mq.DISCONNECT)
+	a.SetReasonCode(code)
+	c.ReasonCode = byte(code)
+	c.Properties.User = g.users(&a.UserProperties)
+	return a, b
+}
+
+func interopAuth(g *gen) (mq.Packet, *packets.ControlPacket) {
+	a := mq.NewAuth()
+	b := packets.NewControlPacket(packets.AUTH)
+	// paho sets reserved flags of AUTH to 1, the specification
+	// says 0
+	b.Flags = 0
+	c := b.Content.(*packets.Auth)
+	c.Properties = &packets.Properties{}
+	props := c.Properties
+
+	code := g.reasonCode(mq.AUTH)
+	a.SetReasonCode(code)
+	c.ReasonCode = byte(code)
+
+	v := g.str(1)
+	a.SetAuthMethod(v)
+	props.AuthMethod = v
+	if g.flag() {
+		data := g.bytes()
+		a.SetAuthData(data)
+		props.AuthData = data
+	}
+	v = g.str(0)
+	a.SetReasonString(v)
+	props.ReasonString = v
+	props.User = g.users(&a.UserProperties)
+	return a, b
+}
+
+// ----------------------------------------
+
+// gen generates random values. Zero values are used as absent
+// properties, as mq does not write them.
+type gen struct {
+	*rand.Rand
+}
+
+func (g *gen) flag() bool { return g.Intn(2) == 1 }
+
+func (g *gen) u16() uint16 {
+	if g.Intn(3) == 0 {
+		return 0
+	}
+	return uint16(g.Intn(1 << 16))
+}
+
+func (g *gen) u32() uint32 {
+	if g.Intn(3) == 0 {
+		return 0
+	}
+	return g.Uint32()
+}
+
+// str returns a random string with at least min characters.
+func (g *gen) str(min int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz/+#-_0123456789 åäö"
+	r := []rune(chars)
+	v := make([]rune, min+g.Intn(12))
+	for i := range v {
+		v[i] = r[g.Intn(len(r))]
+	}
+	return string(v)
+}
+
+func (g *gen) bytes() []byte {
+	v := make([]byte, 1+g.Intn(12))
+	g.Read(v)
+	return v
+}
+
+func (g *gen) reasonCode(packetType byte) mq.ReasonCode {
+	for {
+		if c := mq.ReasonCode(g.Intn(256)); c.ValidFor(packetType) {
+			return c
+		}
+	}
+}
+
+// users adds random user properties to p and returns the same for
+// paho.
+func (g *gen) users(p *mq.UserProperties) []packets.User {
+	var users []packets.User
+	for i := 0; i < g.Intn(3); i++ {
+		k, v := g.str(1), g.str(0)
+		p.AddUserProp(k, v)
+		users = append(users, packets.User{Key: k, Value: v})
+	}
+	return users
+}
+
+func ptr[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return &v
+}
+
+// capPtr returns the value of a capability flag, which is written
+// also when false.
+func capPtr(on bool) *byte {
+	var v byte
+	if on {
+		v = 1
+	}
+	return &v
+}
+
+func flagPtr(on bool) *byte {
+	if !on {
+		return nil
+	}
+	v := byte(1)
+	return &v
+}
@@ -0,0 +1,769 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ParseJSON returns the packet encoded by MarshalJSON of any packet
+// type. The packet type is given by the Type field, e.g.
+//
+//	{"Type":"PUBLISH","QoS":1,"PacketID":9,"TopicName":"a/b"}
+//
+// Field names are the same as in Dump and binary data is base64
+// encoded. Omitted fields get their zero value or the default set
+// by the packets New func.
+func ParseJSON(data []byte) (Packet, error) {
+	var v struct{ Type string }
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("ParseJSON: %w", err)
+	}
+	for fixed, name := range typeNames {
+		if name != v.Type {
+			continue
+		}
+		p := newPacket(bits(fixed))
+		if err := p.(json.Unmarshaler).UnmarshalJSON(data); err != nil {
+			return nil, fmt.Errorf("ParseJSON: %w", err)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("ParseJSON: %w %q", ErrPacketType, v.Type)
+}
+
+// checkType returns an error if the type name is set and does not
+// match the type of fixed.
+func checkType(name string, fixed bits) error {
+	exp := typeNames[byte(fixed)&0b1111_0000]
+	if name != "" && name != exp {
+		return fmt.Errorf("%w %q, expected %s", ErrPacketType, name, exp)
+	}
+	return nil
+}
+
+var ErrPacketType = fmt.Errorf("unknown packet type")
+
+// ----------------------------------------
+
+type connectJSON struct {
+	Type                  string
+	AuthData              []byte  `json:",omitempty"`
+	AuthMethod            string  `json:",omitempty"`
+	CleanStart            bool    `json:",omitempty"`
+	ClientID              string  `json:",omitempty"`
+	KeepAlive             uint16  `json:",omitempty"`
+	MaxPacketSize         uint32  `json:",omitempty"`
+	Password              *[]byte `json:",omitempty"` // set if PasswordFlag
+	ProtocolName          string
+	ProtocolVersion       uint8
+	ReceiveMax            uint16     `json:",omitempty"`
+	RequestProblemInfo    bool       `json:",omitempty"`
+	RequestResponseInfo   bool       `json:",omitempty"`
+	SessionExpiryInterval uint32     `json:",omitempty"`
+	TopicAliasMax         uint16     `json:",omitempty"`
+	Username              string     `json:",omitempty"`
+	Will                  *Publish   `json:",omitempty"`
+	WillDelayInterval     uint32     `json:",omitempty"`
+	UserProperties        []UserProp `json:",omitempty"`
+}
+
+func (p *Connect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(connectJSON{
+		Type:                  typeNames[CONNECT],
+		AuthData:              p.AuthData(),
+		AuthMethod:            p.AuthMethod(),
+		CleanStart:            p.CleanStart(),
+		ClientID:              p.ClientID(),
+		KeepAlive:             p.KeepAlive(),
+		MaxPacketSize:         p.MaxPacketSize(),
+		Password:              p.passwordJSON(),
+		ProtocolName:          p.ProtocolName(),
+		ProtocolVersion:       p.ProtocolVersion(),
+		ReceiveMax:            p.ReceiveMax(),
+		RequestProblemInfo:    p.RequestProblemInfo(),
+		RequestResponseInfo:   p.RequestResponseInfo(),
+		SessionExpiryInterval: p.SessionExpiryInterval(),
+		TopicAliasMax:         p.TopicAliasMax(),
+		Username:              p.Username(),
+		Will:                  p.Will(),
+		WillDelayInterval:     p.WillDelayInterval(),
+		UserProperties:        p.UserProperties,
+	})
+}
+
+// passwordJSON returns the password if PasswordFlag is set, also when
+// empty.
+func (p *Connect) passwordJSON() *[]byte {
+	if !p.HasFlag(PasswordFlag) {
+		return nil
+	}
+	v := append([]byte{}, p.Password()...)
+	return &v
+}
+
+func (p *Connect) UnmarshalJSON(data []byte) error {
+	c := NewConnect()
+	v := connectJSON{
+		ProtocolName:    c.ProtocolName(),
+		ProtocolVersion: c.ProtocolVersion(),
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetAuthData(v.AuthData)
+	c.SetAuthMethod(v.AuthMethod)
+	c.SetCleanStart(v.CleanStart)
+	c.SetClientID(v.ClientID)
+	c.SetKeepAlive(v.KeepAlive)
+	c.SetMaxPacketSize(v.MaxPacketSize)
+	if v.Password != nil {
+		c.SetPassword(append([]byte{}, *v.Password...))
+	}
+	c.SetProtocolName(v.ProtocolName)
+	c.SetProtocolVersion(v.ProtocolVersion)
+	c.SetReceiveMax(v.ReceiveMax)
+	c.SetRequestProblemInfo(v.RequestProblemInfo)
+	c.SetRequestResponseInfo(v.RequestResponseInfo)
+	c.SetSessionExpiryInterval(v.SessionExpiryInterval)
+	c.SetTopicAliasMax(v.TopicAliasMax)
+	c.SetUsername(v.Username)
+	if v.Will != nil {
+		c.SetWill(v.Will)
+	}
+	c.SetWillDelayInterval(v.WillDelayInterval)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type connAckJSON struct {
+	Type                    string
+	AssignedClientID        string     `json:",omitempty"`
+	AuthData                []byte     `json:",omitempty"`
+	AuthMethod              string     `json:",omitempty"`
+	MaxPacketSize           uint32     `json:",omitempty"`
+	MaxQoS                  *uint8     `json:",omitempty"`
+	ReasonCode              ReasonCode `json:",omitempty"`
+	ReasonString            string     `json:",omitempty"`
+	ReceiveMax              uint16     `json:",omitempty"`
+	ResponseInformation     string     `json:",omitempty"`
+	RetainAvailable         *bool      `json:",omitempty"`
+	ServerKeepAlive         uint16     `json:",omitempty"`
+	ServerReference         string     `json:",omitempty"`
+	SessionExpiryInterval   uint32     `json:",omitempty"`
+	SessionPresent          bool       `json:",omitempty"`
+	SharedSubAvailable      *bool      `json:",omitempty"`
+	SubIdentifiersAvailable *bool      `json:",omitempty"`
+	TopicAliasMax           uint16     `json:",omitempty"`
+	WildcardSubAvailable    *bool      `json:",omitempty"`
+	UserProperties          []UserProp `json:",omitempty"`
+}
+
+func (p *ConnAck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(connAckJSON{
+		Type:                    typeNames[CONNACK],
+		AssignedClientID:        p.AssignedClientID(),
+		AuthData:                p.AuthData(),
+		AuthMethod:              p.AuthMethod(),
+		MaxPacketSize:           p.MaxPacketSize(),
+		MaxQoS:                  present(p, hasMaxQoS, uint8(p.maxQoS)),
+		ReasonCode:              p.ReasonCode(),
+		ReasonString:            p.ReasonString(),
+		ReceiveMax:              p.ReceiveMax(),
+		ResponseInformation:     p.ResponseInformation(),
+		RetainAvailable:         present(p, hasRetainAvailable, bool(p.retainAvailable)),
+		ServerKeepAlive:         p.ServerKeepAlive(),
+		ServerReference:         p.ServerReference(),
+		SessionExpiryInterval:   p.SessionExpiryInterval(),
+		SessionPresent:          p.SessionPresent(),
+		SharedSubAvailable:      present(p, hasSharedSubAvailable, bool(p.sharedSubAvailable)),
+		SubIdentifiersAvailable: present(p, hasSubIDsAvailable, bool(p.subIdentifiersAvailable)),
+		TopicAliasMax:           p.TopicAliasMax(),
+		WildcardSubAvailable:    present(p, hasWildcardSubAvailable, bool(p.wildcardSubAvailable)),
+		UserProperties:          p.UserProperties,
+	})
+}
+
+// present returns a pointer to v if the capability is present in p,
+// nil otherwise.
+func present[T any](p *ConnAck, c capability, v T) *T {
+	if p.present&c == 0 {
+		return nil
+	}
+	return &v
+}
+
+func (p *ConnAck) UnmarshalJSON(data []byte) error {
+	var v connAckJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewConnAck()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetAssignedClientID(v.AssignedClientID)
+	c.SetAuthData(v.AuthData)
+	c.SetAuthMethod(v.AuthMethod)
+	c.SetMaxPacketSize(v.MaxPacketSize)
+	if v.MaxQoS != nil {
+		c.SetMaxQoS(*v.MaxQoS)
+	}
+	c.SetReasonCode(v.ReasonCode)
+	c.SetReasonString(v.ReasonString)
+	c.SetReceiveMax(v.ReceiveMax)
+	c.SetResponseInformation(v.ResponseInformation)
+	if v.RetainAvailable != nil {
+		c.SetRetainAvailable(*v.RetainAvailable)
+	}
+	c.SetServerKeepAlive(v.ServerKeepAlive)
+	c.SetServerReference(v.ServerReference)
+	c.SetSessionExpiryInterval(v.SessionExpiryInterval)
+	c.SetSessionPresent(v.SessionPresent)
+	if v.SharedSubAvailable != nil {
+		c.SetSharedSubAvailable(*v.SharedSubAvailable)
+	}
+	if v.SubIdentifiersAvailable != nil {
+		c.SetSubIdentifiersAvailable(*v.SubIdentifiersAvailable)
+	}
+	c.SetTopicAliasMax(v.TopicAliasMax)
+	if v.WildcardSubAvailable != nil {
+		c.SetWildcardSubAvailable(*v.WildcardSubAvailable)
+	}
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type publishJSON struct {
+	Type                  string
+	ContentType           string     `json:",omitempty"`
+	CorrelationData       []byte     `json:",omitempty"`
+	Duplicate             bool       `json:",omitempty"`
+	MessageExpiryInterval uint32     `json:",omitempty"`
+	PacketID              uint16     `json:",omitempty"`
+	Payload               []byte     `json:",omitempty"`
+	PayloadFormat         bool       `json:",omitempty"`
+	QoS                   uint8      `json:",omitempty"`
+	ResponseTopic         string     `json:",omitempty"`
+	Retain                bool       `json:",omitempty"`
+	SubscriptionIDs       []uint32   `json:",omitempty"`
+	TopicAlias            uint16     `json:",omitempty"`
+	TopicName             string     `json:",omitempty"`
+	UserProperties        []UserProp `json:",omitempty"`
+}
+
+func (p *Publish) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.jsonValue())
+}
+
+func (p *Publish) jsonValue() publishJSON {
+	return publishJSON{
+		Type:                  typeNames[PUBLISH],
+		ContentType:           p.ContentType(),
+		CorrelationData:       p.CorrelationData(),
+		Duplicate:             p.Duplicate(),
+		MessageExpiryInterval: p.MessageExpiryInterval(),
+		PacketID:              p.PacketID(),
+		Payload:               p.Payload(),
+		PayloadFormat:         p.PayloadFormat(),
+		QoS:                   p.QoS(),
+		ResponseTopic:         p.ResponseTopic(),
+		Retain:                p.Retain(),
+		SubscriptionIDs:       p.SubscriptionIDs(),
+		TopicAlias:            p.TopicAlias(),
+		TopicName:             p.TopicName(),
+		UserProperties:        p.UserProperties,
+	}
+}
+
+func (p *Publish) UnmarshalJSON(data []byte) error {
+	var v publishJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPublish()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetContentType(v.ContentType)
+	c.SetCorrelationData(v.CorrelationData)
+	c.SetDuplicate(v.Duplicate)
+	c.SetMessageExpiryInterval(v.MessageExpiryInterval)
+	c.SetPacketID(v.PacketID)
+	c.SetPayload(v.Payload)
+	c.SetPayloadFormat(v.PayloadFormat)
+	c.SetQoS(v.QoS)
+	c.SetResponseTopic(v.ResponseTopic)
+	c.SetRetain(v.Retain)
+	c.subscriptionIDs = v.SubscriptionIDs
+	c.SetTopicAlias(v.TopicAlias)
+	c.SetTopicName(v.TopicName)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type publishStreamJSON struct {
+	publishJSON
+	PayloadSize int64
+}
+
+// MarshalJSON encodes headers and properties followed by the
+// PayloadSize, the payload is not read.
+func (p *PublishStream) MarshalJSON() ([]byte, error) {
+	return json.Marshal(publishStreamJSON{
+		publishJSON: p.Publish.jsonValue(),
+		PayloadSize: p.size,
+	})
+}
+
+// UnmarshalJSON decodes a publish packet, the payload reads from the
+// decoded Payload. PayloadSize is ignored.
+func (p *PublishStream) UnmarshalJSON(data []byte) error {
+	var c Publish
+	if err := c.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	payload := c.Payload()
+	c.SetPayload(nil)
+	p.Publish = &c
+	p.SetPayload(bytes.NewReader(payload), int64(len(payload)))
+	return nil
+}
+
+// ----------------------------------------
+
+// pubRespJSON is used by PubAck, PubRec, PubRel and PubComp
+type pubRespJSON struct {
+	Type           string
+	PacketID       uint16     `json:",omitempty"`
+	ReasonCode     ReasonCode `json:",omitempty"`
+	ReasonString   string     `json:",omitempty"`
+	UserProperties []UserProp `json:",omitempty"`
+}
+
+func (p *PubAck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pubRespJSON{
+		Type:           typeNames[PUBACK],
+		PacketID:       p.PacketID(),
+		ReasonCode:     p.ReasonCode(),
+		ReasonString:   p.ReasonString(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *PubAck) UnmarshalJSON(data []byte) error {
+	var v pubRespJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPubAck()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	c.SetReasonCode(v.ReasonCode)
+	c.SetReasonString(v.ReasonString)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+func (p *PubRec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pubRespJSON{
+		Type:           typeNames[PUBREC],
+		PacketID:       p.PacketID(),
+		ReasonCode:     p.ReasonCode(),
+		ReasonString:   p.ReasonString(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *PubRec) UnmarshalJSON(data []byte) error {
+	var v pubRespJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPubRec()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	c.SetReasonCode(v.ReasonCode)
+	c.SetReasonString(v.ReasonString)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+func (p *PubRel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pubRespJSON{
+		Type:           typeNames[PUBREL],
+		PacketID:       p.PacketID(),
+		ReasonCode:     p.ReasonCode(),
+		ReasonString:   p.ReasonString(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *PubRel) UnmarshalJSON(data []byte) error {
+	var v pubRespJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPubRel()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	c.SetReasonCode(v.ReasonCode)
+	c.SetReasonString(v.ReasonString)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+func (p *PubComp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pubRespJSON{
+		Type:           typeNames[PUBCOMP],
+		PacketID:       p.PacketID(),
+		ReasonCode:     p.ReasonCode(),
+		ReasonString:   p.ReasonString(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *PubComp) UnmarshalJSON(data []byte) error {
+	var v pubRespJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPubComp()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	c.SetReasonCode(v.ReasonCode)
+	c.SetReasonString(v.ReasonString)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type subscribeJSON struct {
+	Type           string
+	PacketID       uint16            `json:",omitempty"`
+	SubscriptionID *uint32           `json:",omitempty"`
+	Filters        []topicFilterJSON `json:",omitempty"`
+	UserProperties []UserProp        `json:",omitempty"`
+}
+
+type topicFilterJSON struct {
+	Filter  string
+	Options Opt `json:",omitempty"`
+}
+
+func (p *Subscribe) MarshalJSON() ([]byte, error) {
+	filters := make([]topicFilterJSON, len(p.filters))
+	for i, f := range p.filters {
+		filters[i] = topicFilterJSON{
+			Filter:  f.Filter(),
+			Options: f.Options(),
+		}
+	}
+	v := subscribeJSON{
+		Type:           typeNames[SUBSCRIBE],
+		PacketID:       p.PacketID(),
+		Filters:        filters,
+		UserProperties: p.UserProperties,
+	}
+	if p.subscriptionID != nil {
+		id := uint32(*p.subscriptionID)
+		v.SubscriptionID = &id
+	}
+	return json.Marshal(v)
+}
+
+func (p *Subscribe) UnmarshalJSON(data []byte) error {
+	var v subscribeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewSubscribe()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	if v.SubscriptionID != nil {
+		c.SetSubscriptionID(int(*v.SubscriptionID))
+	}
+	for _, f := range v.Filters {
+		c.AddFilters(NewTopicFilter(f.Filter, f.Options))
+	}
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+// subAckJSON is used by SubAck and UnsubAck
+type subAckJSON struct {
+	Type           string
+	PacketID       uint16     `json:",omitempty"`
+	ReasonString   string     `json:",omitempty"`
+	ReasonCodes    []int      `json:",omitempty"` // []uint8 would be base64
+	UserProperties []UserProp `json:",omitempty"`
+}
+
+func (p *SubAck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(subAckJSON{
+		Type:           typeNames[SUBACK],
+		PacketID:       p.PacketID(),
+		ReasonString:   p.ReasonString(),
+		ReasonCodes:    reasonCodesJSON(p.ReasonCodes()),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *SubAck) UnmarshalJSON(data []byte) error {
+	var v subAckJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewSubAck()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	c.SetReasonString(v.ReasonString)
+	for _, code := range v.ReasonCodes {
+		c.AddReasonCode(ReasonCode(code))
+	}
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+func (p *UnsubAck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(subAckJSON{
+		Type:           typeNames[UNSUBACK],
+		PacketID:       p.PacketID(),
+		ReasonString:   p.ReasonString(),
+		ReasonCodes:    reasonCodesJSON(p.ReasonCodes()),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *UnsubAck) UnmarshalJSON(data []byte) error {
+	var v subAckJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewUnsubAck()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	c.SetReasonString(v.ReasonString)
+	for _, code := range v.ReasonCodes {
+		c.AddReasonCode(ReasonCode(code))
+	}
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+func reasonCodesJSON(codes []uint8) []int {
+	v := make([]int, len(codes))
+	for i, code := range codes {
+		v[i] = int(code)
+	}
+	return v
+}
+
+// ----------------------------------------
+
+type unsubscribeJSON struct {
+	Type           string
+	PacketID       uint16     `json:",omitempty"`
+	Filters        []string   `json:",omitempty"`
+	UserProperties []UserProp `json:",omitempty"`
+}
+
+func (p *Unsubscribe) MarshalJSON() ([]byte, error) {
+	return json.Marshal(unsubscribeJSON{
+		Type:           typeNames[UNSUBSCRIBE],
+		PacketID:       p.PacketID(),
+		Filters:        p.Filters(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *Unsubscribe) UnmarshalJSON(data []byte) error {
+	var v unsubscribeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewUnsubscribe()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetPacketID(v.PacketID)
+	for _, f := range v.Filters {
+		c.AddFilter(f)
+	}
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type disconnectJSON struct {
+	Type           string
+	ReasonCode     ReasonCode `json:",omitempty"`
+	UserProperties []UserProp `json:",omitempty"`
+}
+
+func (p *Disconnect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(disconnectJSON{
+		Type:           typeNames[DISCONNECT],
+		ReasonCode:     p.ReasonCode(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *Disconnect) UnmarshalJSON(data []byte) error {
+	var v disconnectJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewDisconnect()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetReasonCode(v.ReasonCode)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type authJSON struct {
+	Type           string
+	AuthData       []byte     `json:",omitempty"`
+	AuthMethod     string     `json:",omitempty"`
+	ReasonCode     ReasonCode `json:",omitempty"`
+	ReasonString   string     `json:",omitempty"`
+	UserProperties []UserProp `json:",omitempty"`
+}
+
+func (p *Auth) MarshalJSON() ([]byte, error) {
+	return json.Marshal(authJSON{
+		Type:           typeNames[AUTH],
+		AuthData:       p.AuthData(),
+		AuthMethod:     p.AuthMethod(),
+		ReasonCode:     p.ReasonCode(),
+		ReasonString:   p.ReasonString(),
+		UserProperties: p.UserProperties,
+	})
+}
+
+func (p *Auth) UnmarshalJSON(data []byte) error {
+	var v authJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewAuth()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	c.SetAuthData(v.AuthData)
+	c.SetAuthMethod(v.AuthMethod)
+	c.SetReasonCode(v.ReasonCode)
+	c.SetReasonString(v.ReasonString)
+	c.UserProperties = v.UserProperties
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type typeJSON struct {
+	Type string
+}
+
+func (p *PingReq) MarshalJSON() ([]byte, error) {
+	return json.Marshal(typeJSON{Type: typeNames[PINGREQ]})
+}
+
+func (p *PingReq) UnmarshalJSON(data []byte) error {
+	var v typeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPingReq()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	*p = *c
+	return nil
+}
+
+func (p *PingResp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(typeJSON{Type: typeNames[PINGRESP]})
+}
+
+func (p *PingResp) UnmarshalJSON(data []byte) error {
+	var v typeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c := NewPingResp()
+	if err := checkType(v.Type, c.fixed); err != nil {
+		return err
+	}
+	*p = *c
+	return nil
+}
+
+// ----------------------------------------
+
+type undefinedJSON struct {
+	Type string
+	Data []byte `json:",omitempty"`
+}
+
+func (p *Undefined) MarshalJSON() ([]byte, error) {
+	return json.Marshal(undefinedJSON{
+		Type: typeNames[UNDEFINED],
+		Data: p.Data(),
+	})
+}
+
+func (p *Undefined) UnmarshalJSON(data []byte) error {
+	var v undefinedJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkType(v.Type, 0); err != nil {
+		return err
+	}
+	*p = Undefined{data: v.Data}
+	return nil
+}
@@ -0,0 +1,163 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleParseJSON() {
+	p, _ := ParseJSON([]byte(`{
+  "Type": "PUBLISH",
+  "QoS": 1,
+  "PacketID": 9,
+  "TopicName": "a/b",
+  "Payload": "Z29waGVy",
+  "UserProperties": [["color", "red"]]
+}`))
+	fmt.Println(p)
+	data, _ := json.Marshal(p)
+	fmt.Println(string(data))
+	// output:
+	// PUBLISH --1- p9 a/b 29 bytes
+	// {"Type":"PUBLISH","PacketID":9,"Payload":"Z29waGVy","QoS":1,"TopicName":"a/b","UserProperties":[["color","red"]]}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	for _, p := range annotatedPackets() {
+		var exp bytes.Buffer
+		p.WriteTo(&exp)
+
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(p, err)
+		}
+		got, err := ParseJSON(data)
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", p, err, data)
+		}
+		var buf bytes.Buffer
+		got.WriteTo(&buf)
+		if !bytes.Equal(buf.Bytes(), exp.Bytes()) {
+			t.Errorf("%v: wire format differs after json\n%s\n%v\n%v",
+				p, data, exp.Bytes(), buf.Bytes(),
+			)
+		}
+	}
+}
+
+func TestMarshalJSON_emptyPassword(t *testing.T) {
+	c := NewConnect()
+	c.SetPassword([]byte{})
+	var wire bytes.Buffer
+	c.WriteTo(&wire)
+	in, _ := ReadPacket(&wire) // flag set, nothing after it
+
+	for _, p := range []*Connect{c, in.(*Connect)} {
+		data, _ := json.Marshal(p)
+		got, err := ParseJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.(*Connect).HasFlag(PasswordFlag) {
+			t.Errorf("password flag lost\n%s", data)
+		}
+	}
+	data, _ := json.Marshal(NewConnect())
+	if got, _ := ParseJSON(data); got.(*Connect).HasFlag(PasswordFlag) {
+		t.Errorf("password flag set\n%s", data)
+	}
+}
+
+func TestMarshalJSON_subscriptionID(t *testing.T) {
+	s := NewSubscribe()
+	s.AddFilters(NewTopicFilter("a/b", OptQoS1))
+	data, _ := json.Marshal(s)
+	if bytes.Contains(data, []byte("SubscriptionID")) {
+		t.Errorf("absent SubscriptionID encoded\n%s", data)
+	}
+	got, err := ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.(*Subscribe).SubscriptionID(); v != -1 {
+		t.Errorf("got SubscriptionID %v, expected -1", v)
+	}
+
+	s.SetSubscriptionID(7)
+	data, _ = json.Marshal(s)
+	got, _ = ParseJSON(data)
+	if v := got.(*Subscribe).SubscriptionID(); v != 7 {
+		t.Errorf("got SubscriptionID %v, expected 7\n%s", v, data)
+	}
+}
+
+func TestMarshalJSON_undefined(t *testing.T) {
+	in := &Undefined{data: []byte{1, 2}}
+	data, _ := json.Marshal(in)
+	got, err := ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.(*Undefined).Data(); !bytes.Equal(v, in.data) {
+		t.Error(v)
+	}
+}
+
+func TestParseJSON_defaults(t *testing.T) {
+	p, err := ParseJSON([]byte(`{"Type":"CONNECT","ClientID":"pink"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := p.(*Connect)
+	if c.ProtocolName() != "MQTT" || c.ProtocolVersion() != 5 {
+		t.Error(c)
+	}
+
+	p, err = ParseJSON([]byte(`{"Type":"SUBSCRIBE","PacketID":1,"Filters":[{"Filter":"a/#"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	if buf.Bytes()[0] != byte(NewSubscribe().fixed) {
+		t.Errorf("fixed header %08b", buf.Bytes()[0])
+	}
+}
+
+func TestParseJSON_errors(t *testing.T) {
+	cases := map[string]string{
+		"bad json": `{"Type":`,
+		"unknown":  `{"Type":"PUBLISHED"}`,
+		"missing":  `{}`,
+		"field":    `{"Type":"PUBLISH","QoS":"one"}`,
+	}
+	for name, data := range cases {
+		if _, err := ParseJSON([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := ParseJSON([]byte(cases["unknown"])); !errors.Is(err, ErrPacketType) {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalJSON_wrongType(t *testing.T) {
+	packets := []Packet{
+		&Auth{}, &ConnAck{}, &Connect{}, &Disconnect{}, &PingReq{},
+		&PingResp{}, &PubAck{}, &PubComp{}, &Publish{}, &PubRec{},
+		&PubRel{}, &SubAck{}, &Subscribe{}, &UnsubAck{},
+		&Unsubscribe{}, &Undefined{},
+	}
+	for _, p := range packets {
+		u := p.(json.Unmarshaler)
+		if err := u.UnmarshalJSON([]byte(`{"Type":"NOPE"}`)); !errors.Is(err, ErrPacketType) {
+			t.Errorf("%T: %v", p, err)
+		}
+		if err := u.UnmarshalJSON([]byte(`[]`)); err == nil {
+			t.Errorf("%T: expected error", p)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/gregoryv/mq"
+)
+
+// NewIdentity returns the identity of the given certificate.
+func NewIdentity(cert *x509.Certificate) *Identity {
+	id := &Identity{
+		Subject:     cert.Subject.String(),
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Emails:      cert.EmailAddresses,
+		Certificate: cert,
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id
+}
+
+// Identity holds the subject and subject alternative names of a
+// certificate.
+type Identity struct {
+	Subject    string // distinguished name, e.g. CN=pink,O=gopher
+	CommonName string
+
+	// subject alternative names
+	DNSNames []string
+	Emails   []string
+	URIs     []string
+
+	Certificate *x509.Certificate
+}
+
+func (id *Identity) String() string {
+	return id.Subject
+}
+
+// Hook modifies the connect packet using the peer identity. A
+// returned error stops the connect from being used.
+type Hook func(id *Identity, p *mq.Connect) error
+
+// ClientIDFromCommonName returns a hook setting the client ID to
+// the certificate common name. If override is false the client ID is
+// only set when empty. Returns ErrMissingName only if the name is
+// needed.
+func ClientIDFromCommonName(override bool) Hook {
+	return func(id *Identity, p *mq.Connect) error {
+		if !override && p.ClientID() != "" {
+			return nil
+		}
+		if id.CommonName == "" {
+			return fmt.Errorf("%w: common name", ErrMissingName)
+		}
+		p.SetClientID(id.CommonName)
+		return nil
+	}
+}
+
+// UsernameFromCommonName returns a hook setting the username to
+// the certificate common name. If override is false the username is
+// only set when empty. Returns ErrMissingName only if the name is
+// needed.
+func UsernameFromCommonName(override bool) Hook {
+	return func(id *Identity, p *mq.Connect) error {
+		if !override && p.Username() != "" {
+			return nil
+		}
+		if id.CommonName == "" {
+			return fmt.Errorf("%w: common name", ErrMissingName)
+		}
+		p.SetUsername(id.CommonName)
+		return nil
+	}
+}
+
+// UsernameFromEmail returns a hook setting the username to the
+// first email subject alternative name. If override is false the
+// username is only set when empty. Returns ErrMissingName only if
+// the email is needed.
+func UsernameFromEmail(override bool) Hook {
+	return func(id *Identity, p *mq.Connect) error {
+		if !override && p.Username() != "" {
+			return nil
+		}
+		if len(id.Emails) == 0 {
+			return fmt.Errorf("%w: email", ErrMissingName)
+		}
+		p.SetUsername(id.Emails[0])
+		return nil
+	}
+}
+
+// ErrMissingName is returned by hooks when the certificate lacks the
+// name they use, wrapped with the kind of name.
+var ErrMissingName = fmt.Errorf("missing name in certificate")
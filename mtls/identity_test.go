@@ -0,0 +1,84 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+func TestNewIdentity(t *testing.T) {
+	u, _ := url.Parse("spiffe://example.com/pink")
+	id := NewIdentity(&x509.Certificate{
+		Subject:        pkix.Name{CommonName: "pink", Organization: []string{"gopher"}},
+		DNSNames:       []string{"pink.example.com"},
+		EmailAddresses: []string{"pink@example.com"},
+		URIs:           []*url.URL{u},
+	})
+	if v := id.String(); v != "CN=pink,O=gopher" {
+		t.Error(v)
+	}
+	if id.CommonName != "pink" {
+		t.Error(id.CommonName)
+	}
+	if len(id.URIs) != 1 || id.URIs[0] != u.String() {
+		t.Error(id.URIs)
+	}
+}
+
+func TestHook(t *testing.T) {
+	pink := &Identity{CommonName: "pink", Emails: []string{"pink@example.com"}}
+	cases := []struct {
+		hook     Hook
+		id       *Identity
+		clientID string
+		username string
+		expID    string
+		expUser  string
+		expErr   bool
+	}{
+		{hook: ClientIDFromCommonName(false), id: pink, expID: "pink"},
+		{hook: ClientIDFromCommonName(false), id: pink, clientID: "x", expID: "x"},
+		{hook: ClientIDFromCommonName(true), id: pink, clientID: "x", expID: "pink"},
+		{hook: ClientIDFromCommonName(true), id: &Identity{}, expErr: true},
+		{hook: ClientIDFromCommonName(false), id: &Identity{}, expErr: true},
+		{hook: ClientIDFromCommonName(false), id: &Identity{}, clientID: "x", expID: "x"},
+
+		{hook: UsernameFromCommonName(false), id: pink, expUser: "pink"},
+		{hook: UsernameFromCommonName(false), id: pink, username: "x", expUser: "x"},
+		{hook: UsernameFromCommonName(true), id: pink, username: "x", expUser: "pink"},
+		{hook: UsernameFromCommonName(true), id: &Identity{}, expErr: true},
+		{hook: UsernameFromCommonName(false), id: &Identity{}, expErr: true},
+		{hook: UsernameFromCommonName(false), id: &Identity{}, username: "x", expUser: "x"},
+
+		{hook: UsernameFromEmail(false), id: pink, expUser: "pink@example.com"},
+		{hook: UsernameFromEmail(false), id: pink, username: "x", expUser: "x"},
+		{hook: UsernameFromEmail(true), id: pink, username: "x", expUser: "pink@example.com"},
+		{hook: UsernameFromEmail(true), id: &Identity{}, expErr: true},
+		{hook: UsernameFromEmail(false), id: &Identity{}, expErr: true},
+		{hook: UsernameFromEmail(false), id: &Identity{}, username: "x", expUser: "x"},
+	}
+	for i, c := range cases {
+		p := mq.NewConnect()
+		p.SetClientID(c.clientID)
+		p.SetUsername(c.username)
+		err := c.hook(c.id, p)
+		if c.expErr {
+			if err == nil {
+				t.Errorf("case %v: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %v: %v", i, err)
+		}
+		if v := p.ClientID(); v != c.expID {
+			t.Errorf("case %v: ClientID %q, expected %q", i, v, c.expID)
+		}
+		if v := p.Username(); v != c.expUser {
+			t.Errorf("case %v: Username %q, expected %q", i, v, c.expUser)
+		}
+	}
+}
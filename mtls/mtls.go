@@ -0,0 +1,125 @@
+/*
+Package mtls provides TLS and mutual TLS connection helpers where
+the client certificate identifies the client.
+
+Use Listen on the server side and Dial on the client side. Once a
+connection is established, the identity of the peer certificate is
+available and can be used to fill in fields of the first Connect
+packet before any authentication takes place.
+*/
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/gregoryv/mq"
+)
+
+// ServerConfig returns a configuration requiring clients to present
+// a certificate signed by one of the given authorities.
+func ServerConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ClientConfig returns a configuration presenting the given
+// certificate and trusting servers signed by rootCAs.
+func ClientConfig(cert tls.Certificate, rootCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// Listen returns a listener accepting TLS connections.
+func Listen(network, addr string, config *tls.Config) (*Listener, error) {
+	l, err := tls.Listen(network, addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("mtls.Listen: %w", err)
+	}
+	return &Listener{Listener: l}, nil
+}
+
+type Listener struct {
+	net.Listener
+}
+
+// Accept returns the next connection as a *Conn. The handshake is
+// not done until the first read, write or call to Conn.Identity.
+// Connections other than TLS, e.g. from a plain inner listener, are
+// closed and skipped.
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if tc, ok := c.(*tls.Conn); ok {
+			return &Conn{Conn: tc}, nil
+		}
+		c.Close()
+	}
+}
+
+// Dial connects to the given address and completes the handshake.
+func Dial(network, addr string, config *tls.Config) (*Conn, error) {
+	c, err := tls.Dial(network, addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("mtls.Dial: %w", err)
+	}
+	return &Conn{Conn: c}, nil
+}
+
+// Conn is a TLS connection exposing the peer identity.
+type Conn struct {
+	*tls.Conn
+}
+
+// Identity returns the identity of the peer certificate. The
+// handshake is done if not already completed.
+func (c *Conn) Identity() (*Identity, error) {
+	if err := c.Handshake(); err != nil {
+		return nil, fmt.Errorf("Identity: %w", err)
+	}
+	certs := c.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, ErrNoCertificate
+	}
+	return NewIdentity(certs[0]), nil
+}
+
+// ReadConnect reads the first packet which must be a connect. The
+// hooks are called in order with the peer identity, before
+// returning the packet.
+func (c *Conn) ReadConnect(hooks ...Hook) (*mq.Connect, error) {
+	id, err := c.Identity()
+	if err != nil {
+		return nil, fmt.Errorf("ReadConnect: %w", err)
+	}
+	p, err := mq.ReadPacket(c)
+	if err != nil {
+		return nil, fmt.Errorf("ReadConnect: %w", err)
+	}
+	connect, ok := p.(*mq.Connect)
+	if !ok {
+		return nil, fmt.Errorf("ReadConnect: unexpected %v", p)
+	}
+	for _, hook := range hooks {
+		if err := hook(id, connect); err != nil {
+			return nil, fmt.Errorf("ReadConnect: %w", err)
+		}
+	}
+	return connect, nil
+}
+
+// ErrNoCertificate is returned by Conn.Identity when the peer
+// presented no certificate.
+var ErrNoCertificate = fmt.Errorf("no peer certificate")
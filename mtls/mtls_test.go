@@ -0,0 +1,217 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+func TestListen(t *testing.T) {
+	ca := newCA(t)
+	serverCert := ca.issue(t, "localhost", func(c *x509.Certificate) {
+		c.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		c.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	})
+	clientCert := ca.issue(t, "pink", func(c *x509.Certificate) {
+		c.EmailAddresses = []string{"pink@example.com"}
+		c.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	})
+
+	ln, err := Listen("tcp", "127.0.0.1:0", ServerConfig(serverCert, ca.pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	result := make(chan *mq.Connect, 1)
+	go func() {
+		defer close(result)
+		c, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close()
+		p, err := c.(*Conn).ReadConnect(
+			ClientIDFromCommonName(false),
+			UsernameFromEmail(true),
+		)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		result <- p
+	}()
+
+	conn, err := Dial("tcp", ln.Addr().String(), ClientConfig(clientCert, ca.pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	id, err := conn.Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.CommonName != "localhost" {
+		t.Error("server identity", id)
+	}
+
+	p := mq.NewConnect()
+	p.SetUsername("someone")
+	p.WriteTo(conn)
+
+	got := <-result
+	if got == nil {
+		t.FailNow()
+	}
+	if v := got.ClientID(); v != "pink" {
+		t.Errorf("ClientID %q", v)
+	}
+	if v := got.Username(); v != "pink@example.com" {
+		t.Errorf("Username %q", v)
+	}
+}
+
+func TestListen_withoutClientCert(t *testing.T) {
+	ca := newCA(t)
+	serverCert := ca.issue(t, "localhost", func(c *x509.Certificate) {
+		c.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		c.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	})
+	ln, err := Listen("tcp", "127.0.0.1:0", ServerConfig(serverCert, ca.pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, err := c.(*Conn).Identity(); err == nil {
+			t.Error("expected error")
+		}
+	}()
+
+	conn, err := Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: ca.pool})
+	if err == nil {
+		// TLS 1.3 reports client certificate failures on first read
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil {
+		t.Error("expected error")
+	}
+	<-done
+}
+
+func TestListen_badAddr(t *testing.T) {
+	if _, err := Listen("tcp", "-", &tls.Config{}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// ----------------------------------------
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newCA(t *testing.T) *testCA {
+	t.Helper()
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, cn string, modify func(*x509.Certificate)) tls.Certificate {
+	t.Helper()
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"gopher"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	modify(tmpl)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestListener_notTLS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Listener{Listener: ln}
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		accepted <- err
+	}()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Error("expected conn closed, got", err)
+	}
+
+	// still accepting
+	l.Close()
+	if err := <-accepted; !errors.Is(err, net.ErrClosed) {
+		t.Error("expected net.ErrClosed, got", err)
+	}
+}
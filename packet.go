@@ -7,7 +7,9 @@ https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html
 package mq
 
 import (
+	"bytes"
 	"encoding"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -23,12 +25,51 @@ func ReadPacket(r io.Reader) (ControlPacket, error) {
 	return fh.ReadRemaining(r)
 }
 
+// ReadWellFormed reads one packet like ReadPacket and returns a
+// Malformed error if it is not well formed. The malformed packet is
+// returned together with the error.
+func ReadWellFormed(r io.Reader) (ControlPacket, error) {
+	p, err := ReadPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := p.(HasWellFormed); ok {
+		if err := v.WellFormed(); err != nil {
+			return p, fmt.Errorf("ReadWellFormed: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// FixedHeaderLen returns the width n of the fixed header at the start
+// of buf, including the remaining length, and the remaining length.
+// The packet is n+remainingLen bytes. n is 0 if buf is too short to
+// tell. Returns ErrRemainingLength if the remaining length does not
+// end within four bytes, see 1.5.5 Variable Byte Integer.
+func FixedHeaderLen(buf []byte) (n, remainingLen int, err error) {
+	multiplier := 1
+	for i := 1; i < len(buf); i++ {
+		remainingLen += int(buf[i]&127) * multiplier
+		if buf[i]&128 == 0 {
+			return i + 1, remainingLen, nil
+		}
+		if i == 4 {
+			return 0, 0, ErrRemainingLength
+		}
+		multiplier *= 128
+	}
+	return 0, 0, nil
+}
+
+// ErrRemainingLength is returned for a remaining length encoded in
+// more than four bytes.
+var ErrRemainingLength = fmt.Errorf("malformed remaining length")
+
 // Dump writes all packet fields to the given writer, including empty
-// value ones.
+// value ones. Credentials are redacted, use a Dumper for other
+// options.
 func Dump(w io.Writer, p Packet) {
-	if p, ok := p.(interface{ dump(io.Writer) }); ok {
-		p.dump(w)
-	}
+	NewDumper().Dump(w, p)
 }
 
 // Packet and ControlPacket can be used interchangebly.
@@ -82,73 +123,96 @@ func (f *fixedHeader) ReadFrom(r io.Reader) (int64, error) {
 // ReadRemaining reads the reamining data and converts to a control
 // packet.
 func (f *fixedHeader) ReadRemaining(r io.Reader) (ControlPacket, error) {
-	var p ControlPacket
-	switch byte(f.fixed) & 0b1111_0000 {
+	p := newPacket(f.fixed)
+	// packets may arrive in pieces, e.g. over websockets
+	data, err := readFull(r, int(f.remainingLen))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%s ReadRemaining: %w",
+			firstByte(f.fixed).String(), err,
+		)
+	}
+
+	if err := p.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf(
+			"%s %v UnmarshalBinary: %w",
+			firstByte(f.fixed).String(), f.remainingLen, err,
+		)
+	}
+	return p, nil
+}
+
+// readFull reads n bytes from r. Large packets are read in chunks so
+// that a remaining length, without the data, does not allocate
+// memory for the whole packet.
+func readFull(r io.Reader, n int) ([]byte, error) {
+	const chunk = 64 << 10
+	if n <= chunk {
+		data := make([]byte, n)
+		_, err := io.ReadFull(r, data)
+		return data, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(chunk)
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newPacket returns an empty packet of the type given by the first
+// byte.
+func newPacket(fixed bits) ControlPacket {
+	switch byte(fixed) & 0b1111_0000 {
 
 	case PUBLISH:
-		p = &Publish{fixed: f.fixed}
+		return &Publish{fixed: fixed}
 
 	case PUBREL:
-		p = &PubRel{fixed: f.fixed}
+		return &PubRel{fixed: fixed}
 
 	case PUBCOMP:
-		p = &PubComp{fixed: f.fixed}
+		return &PubComp{fixed: fixed}
 
 	case PUBREC:
-		p = &PubRec{fixed: f.fixed}
+		return &PubRec{fixed: fixed}
 
 	case PUBACK:
-		p = &PubAck{fixed: f.fixed}
+		return &PubAck{fixed: fixed}
 
 	case CONNECT:
-		p = &Connect{fixed: f.fixed}
+		return &Connect{fixed: fixed}
 
 	case CONNACK:
-		p = &ConnAck{fixed: f.fixed}
+		return &ConnAck{fixed: fixed}
 
 	case SUBSCRIBE:
-		p = &Subscribe{fixed: f.fixed}
+		return &Subscribe{fixed: fixed}
 
 	case UNSUBSCRIBE:
-		p = &Unsubscribe{fixed: f.fixed}
+		return &Unsubscribe{fixed: fixed}
 
 	case SUBACK:
-		p = &SubAck{fixed: f.fixed}
+		return &SubAck{fixed: fixed}
 
 	case UNSUBACK:
-		p = &UnsubAck{fixed: f.fixed}
+		return &UnsubAck{fixed: fixed}
 
 	case PINGREQ:
-		p = &PingReq{fixed: f.fixed}
+		return &PingReq{fixed: fixed}
 
 	case PINGRESP:
-		p = &PingResp{fixed: f.fixed}
+		return &PingResp{fixed: fixed}
 
 	case DISCONNECT:
-		p = &Disconnect{fixed: f.fixed}
+		return &Disconnect{fixed: fixed}
 
 	case AUTH:
-		p = &Auth{fixed: f.fixed}
-
-	default:
-		p = &Undefined{}
-	}
-	if f.remainingLen == 0 {
-		return p, nil
-	}
-	data := make([]byte, int(f.remainingLen))
-	if _, err := r.Read(data); err != nil {
-		return nil, fmt.Errorf(
-			"%s ReadRemaining: %w",
-			firstByte(f.fixed).String(), err,
-		)
-	}
+		return &Auth{fixed: fixed}
 
-	if err := p.UnmarshalBinary(data); err != nil {
-		return nil, fmt.Errorf(
-			"%s %v UnmarshalBinary: %w",
-			firstByte(f.fixed).String(), f.remainingLen, err,
-		)
 	}
-	return p, nil
+	return &Undefined{}
 }
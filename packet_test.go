@@ -38,6 +38,27 @@ func TestDump(t *testing.T) {
 	}
 }
 
+func TestFixedHeaderLen(t *testing.T) {
+	cases := []struct {
+		buf             []byte
+		n, remainingLen int
+		err             error
+	}{
+		{[]byte{0xc0, 0x00}, 2, 0, nil},
+		{[]byte{0x30, 0x80, 0x01, 0xff}, 3, 128, nil},
+		{[]byte{0x30, 0xff, 0xff, 0xff, 0x7f}, 5, 268_435_455, nil},
+		{[]byte{0x30}, 0, 0, nil},
+		{[]byte{0x30, 0xff, 0xff}, 0, 0, nil},
+		{[]byte{0x30, 0xff, 0xff, 0xff, 0xff}, 0, 0, ErrRemainingLength},
+	}
+	for _, c := range cases {
+		n, remainingLen, err := FixedHeaderLen(c.buf)
+		if n != c.n || remainingLen != c.remainingLen || err != c.err {
+			t.Errorf("% x: got %v, %v, %v", c.buf, n, remainingLen, err)
+		}
+	}
+}
+
 func TestReadPacket_broken(t *testing.T) {
 	var r brokenRW
 	if _, err := ReadPacket(&r); err == nil {
@@ -116,3 +137,142 @@ func testControlPacket(t *testing.T, in ControlPacket) {
 		t.Error("empty .String")
 	}
 }
+
+func TestWellFormed(t *testing.T) {
+	for _, p := range annotatedPackets() {
+		v, ok := p.(HasWellFormed)
+		if !ok {
+			t.Errorf("%T missing WellFormed", p)
+			continue
+		}
+		if err := v.WellFormed(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	connect := func(flags byte) *Connect {
+		p := NewConnect()
+		p.flags = bits(flags)
+		return p
+	}
+	authData := NewConnect()
+	authData.SetAuthData([]byte("x"))
+	present := NewConnAck()
+	present.SetSessionPresent(true)
+	present.SetReasonCode(NotAuthorized)
+	reserved := NewConnAck()
+	reserved.flags = 0b10
+	dup := Pub(0, "a/b", "")
+	dup.SetDuplicate(true)
+	suback := NewSubAck()
+	suback.AddReasonCode(NoSubscriptionExisted)
+	unsuback := NewUnsubAck()
+	unsuback.AddReasonCode(GrantedQoS1)
+	unsubscribe := NewUnsubscribe()
+	unsubscribe.SetPacketID(1)
+	unsubFixed := NewUnsubscribe()
+	unsubFixed.SetPacketID(1)
+	unsubFixed.AddFilter("a/b")
+	unsubFixed.fixed = bits(UNSUBSCRIBE)
+	pubrel := NewPubRel()
+	pubrel.SetPacketID(1)
+	pubrel.fixed = bits(PUBREL)
+	auth := NewAuth()
+	auth.SetReasonCode(NotAuthorized)
+	method := NewAuth()
+	method.SetReasonCode(ContinueAuth)
+	banned := NewPubAck()
+	banned.SetPacketID(1)
+	banned.SetReasonCode(Banned)
+	connack := NewConnAck()
+	connack.SetReasonCode(ContinueAuth)
+	disconnect := NewDisconnect()
+	disconnect.SetReasonCode(GrantedQoS1)
+
+	malformed := []HasWellFormed{
+		connect(Reserved),
+		connect(WillFlag | WillQoS1 | WillQoS2),
+		connect(WillQoS1),
+		connect(WillRetain),
+		authData,
+		present,
+		reserved,
+		dup,
+		NewSubAck(),
+		suback,
+		NewUnsubAck(),
+		unsuback,
+		NewUnsubscribe(),
+		unsubscribe,
+		unsubFixed,
+		NewPubRel(),
+		pubrel,
+		NewPubAck(),
+		NewPubRec(),
+		NewPubComp(),
+		auth,
+		method,
+		banned,
+		connack,
+		disconnect,
+		&Disconnect{fixed: bits(DISCONNECT | 1)},
+		&PingReq{fixed: bits(PINGREQ | 1)},
+		&PingResp{fixed: bits(PINGRESP | 1)},
+		&Subscribe{fixed: bits(SUBSCRIBE)},
+		&Undefined{},
+	}
+	for _, p := range malformed {
+		if err := p.WellFormed(); err == nil {
+			t.Errorf("%v: expected Malformed", p)
+		}
+	}
+}
+
+func TestReadWellFormed(t *testing.T) {
+	var buf bytes.Buffer
+	Pub(1, "a/b", "gopher").WriteTo(&buf)
+	if _, err := ReadWellFormed(&buf); err == nil {
+		t.Error("expected error on missing packet ID")
+	}
+	p := Pub(1, "a/b", "gopher")
+	p.SetPacketID(1)
+	p.WriteTo(&buf)
+	if _, err := ReadWellFormed(&buf); err != nil {
+		t.Error(err)
+	}
+	if _, err := ReadWellFormed(&buf); err == nil {
+		t.Error("expected error on EOF")
+	}
+}
+
+func FuzzReadPacket(f *testing.F) {
+	for _, p := range annotatedPackets() {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ReadPacket(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		roundtrip(t, p)
+	})
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	for _, p := range annotatedPackets() {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		var fh fixedHeader
+		fh.ReadFrom(&buf)
+		f.Add(byte(fh.fixed), buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, fixed byte, data []byte) {
+		p := newPacket(bits(fixed))
+		if err := p.UnmarshalBinary(data); err != nil {
+			return
+		}
+		roundtrip(t, p)
+	})
+}
@@ -0,0 +1,278 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// frame is one captured link layer frame
+type frame struct {
+	time     time.Time
+	linkType uint32
+	data     []byte
+}
+
+type frameReader interface {
+	// next returns io.EOF when there are no more frames
+	next() (*frame, error)
+}
+
+// newFrameReader returns a reader for classic pcap or pcapng
+// formatted data.
+func newFrameReader(r io.Reader) (frameReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+	if binary.BigEndian.Uint32(magic) == blockSectionHeader {
+		return &ngReader{r: br}, nil
+	}
+	return newClassicReader(br)
+}
+
+// ----------------------------------------
+
+// Classic pcap file format
+// https://www.ietf.org/archive/id/draft-gharris-opsawg-pcap-01.html
+const (
+	magicMicro = 0xa1b2c3d4
+	magicNano  = 0xa1b23c4d
+)
+
+// maxSnapLen limits memory allocated for one record, as in libpcap
+// MAXIMUM_SNAPLEN.
+const maxSnapLen = 262144
+
+func newClassicReader(r io.Reader) (*classicReader, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
+	}
+	c := &classicReader{r: r}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header) {
+		case magicMicro:
+			c.order = order
+		case magicNano:
+			c.order = order
+			c.nano = true
+		}
+	}
+	if c.order == nil {
+		return nil, fmt.Errorf("%w: magic %x", ErrFormat, header[:4])
+	}
+	c.snapLen = c.order.Uint32(header[16:])
+	if c.snapLen == 0 || c.snapLen > maxSnapLen {
+		c.snapLen = maxSnapLen
+	}
+	c.linkType = c.order.Uint32(header[20:]) & 0x0fff_ffff
+	return c, nil
+}
+
+type classicReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	snapLen  uint32 // max record length
+	linkType uint32
+}
+
+func (c *classicReader) next() (*frame, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated record", ErrFormat)
+		}
+		return nil, err
+	}
+	sec := int64(c.order.Uint32(header[0:]))
+	frac := int64(c.order.Uint32(header[4:]))
+	if !c.nano {
+		frac *= 1000
+	}
+	caplen := c.order.Uint32(header[8:])
+	if caplen > c.snapLen {
+		return nil, fmt.Errorf("%w: record length %v exceeds snaplen %v",
+			ErrFormat, caplen, c.snapLen,
+		)
+	}
+	data := make([]byte, caplen)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, fmt.Errorf("%w: truncated record", ErrFormat)
+	}
+	return &frame{
+		time:     time.Unix(sec, frac),
+		linkType: c.linkType,
+		data:     data,
+	}, nil
+}
+
+// ----------------------------------------
+
+// pcapng file format
+// https://www.ietf.org/archive/id/draft-tuexen-opsawg-pcapng-05.html
+const (
+	blockSectionHeader   = 0x0a0d0d0a
+	blockInterface       = 0x00000001
+	blockSimplePacket    = 0x00000003
+	blockEnhancedPacket  = 0x00000006
+	byteOrderMagic       = 0x1a2b3c4d
+	optionEnd            = 0
+	optionInterfaceTsRes = 9
+
+	// maxBlockSize limits memory allocated for one block
+	maxBlockSize = 16 << 20
+)
+
+type ngReader struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	ifaces []ngInterface
+}
+
+type ngInterface struct {
+	linkType uint32
+	tsresol  byte // if_tsresol option value
+}
+
+func (n *ngReader) next() (*frame, error) {
+	for {
+		typ, body, err := n.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case blockInterface:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("%w: short interface block", ErrFormat)
+			}
+			n.ifaces = append(n.ifaces, ngInterface{
+				linkType: uint32(n.order.Uint16(body)),
+				tsresol:  n.tsresol(body[8:]),
+			})
+
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("%w: short packet block", ErrFormat)
+			}
+			id := int(n.order.Uint32(body))
+			if id >= len(n.ifaces) {
+				return nil, fmt.Errorf("%w: unknown interface %v", ErrFormat, id)
+			}
+			iface := n.ifaces[id]
+			ts := uint64(n.order.Uint32(body[4:]))<<32 | uint64(n.order.Uint32(body[8:]))
+			caplen := int(n.order.Uint32(body[12:]))
+			if 20+caplen > len(body) {
+				return nil, fmt.Errorf("%w: packet exceeds block", ErrFormat)
+			}
+			return &frame{
+				time:     iface.time(ts),
+				linkType: iface.linkType,
+				data:     body[20 : 20+caplen],
+			}, nil
+
+		case blockSimplePacket:
+			if len(n.ifaces) == 0 || len(body) < 4 {
+				return nil, fmt.Errorf("%w: simple packet block", ErrFormat)
+			}
+			size := int(n.order.Uint32(body))
+			if 4+size > len(body) {
+				size = len(body) - 4 // snapped, includes padding
+			}
+			return &frame{
+				linkType: n.ifaces[0].linkType,
+				data:     body[4 : 4+size],
+			}, nil
+		}
+		// skip other blocks
+	}
+}
+
+// readBlock returns type and body of the next block, excluding the
+// trailing length.
+func (n *ngReader) readBlock() (uint32, []byte, error) {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(n.r, head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("%w: truncated block", ErrFormat)
+		}
+		return 0, nil, err
+	}
+	if binary.BigEndian.Uint32(head) == blockSectionHeader {
+		// byte order is given by the first field in the body
+		bom := make([]byte, 4)
+		if _, err := io.ReadFull(n.r, bom); err != nil {
+			return 0, nil, fmt.Errorf("%w: truncated block", ErrFormat)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(bom) == byteOrderMagic:
+			n.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(bom) == byteOrderMagic:
+			n.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("%w: byte order %x", ErrFormat, bom)
+		}
+		n.ifaces = nil // interfaces are per section
+		size := int(n.order.Uint32(head[4:]))
+		if size < 16 || size > maxBlockSize {
+			return 0, nil, fmt.Errorf("%w: block size %v", ErrFormat, size)
+		}
+		rest := make([]byte, size-12)
+		if _, err := io.ReadFull(n.r, rest); err != nil {
+			return 0, nil, fmt.Errorf("%w: truncated block", ErrFormat)
+		}
+		return blockSectionHeader, rest[:len(rest)-4], nil
+	}
+	if n.order == nil {
+		return 0, nil, fmt.Errorf("%w: missing section header", ErrFormat)
+	}
+	size := int(n.order.Uint32(head[4:]))
+	if size < 12 || size%4 != 0 || size > maxBlockSize {
+		return 0, nil, fmt.Errorf("%w: block size %v", ErrFormat, size)
+	}
+	body := make([]byte, size-8)
+	if _, err := io.ReadFull(n.r, body); err != nil {
+		return 0, nil, fmt.Errorf("%w: truncated block", ErrFormat)
+	}
+	return n.order.Uint32(head), body[:len(body)-4], nil
+}
+
+// tsresol returns the if_tsresol option value or 6, the default
+// microsecond resolution.
+func (n *ngReader) tsresol(options []byte) byte {
+	for len(options) >= 4 {
+		code := n.order.Uint16(options)
+		size := int(n.order.Uint16(options[2:]))
+		if code == optionEnd || 4+size > len(options) {
+			break
+		}
+		if code == optionInterfaceTsRes && size == 1 {
+			return options[4]
+		}
+		options = options[4+(size+3)/4*4:]
+	}
+	return 6
+}
+
+// time converts a timestamp in interface resolution units.
+func (i ngInterface) time(ts uint64) time.Time {
+	v := uint(i.tsresol & 0x7f)
+	if i.tsresol&0x80 != 0 { // negative power of 2
+		sec := ts >> v
+		frac := (ts & (1<<v - 1)) * 1e9 >> v
+		return time.Unix(int64(sec), int64(frac))
+	}
+	unit := uint64(1)
+	for ; v > 0; v-- {
+		unit *= 10
+	}
+	sec := ts / unit
+	frac := (ts % unit) * 1e9 / unit
+	return time.Unix(int64(sec), int64(frac))
+}
+
+var ErrFormat = fmt.Errorf("bad capture format")
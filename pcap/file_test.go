@@ -0,0 +1,116 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_classicReader_bigEndianNano(t *testing.T) {
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	binary.Write(&buf, be, []uint32{magicNano, 0x0002_0004, 0, 0, 65535, linkRaw})
+	binary.Write(&buf, be, []uint32{10, 999, 2, 2})
+	buf.Write([]byte{0x45, 0})
+
+	r, err := newFrameReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.time.Equal(time.Unix(10, 999)) || f.linkType != linkRaw || len(f.data) != 2 {
+		t.Errorf("%+v", f)
+	}
+	if _, err := r.next(); err != io.EOF {
+		t.Error("expected io.EOF, got", err)
+	}
+}
+
+func Test_classicReader_snapLen(t *testing.T) {
+	le := binary.LittleEndian
+	// snaplen 0 means unknown
+	for snapLen, caplen := range map[uint32]uint32{64: 65, 0: maxSnapLen + 1} {
+		var buf bytes.Buffer
+		binary.Write(&buf, le, []uint32{magicMicro, 0x0004_0002, 0, 0, snapLen, linkRaw})
+		binary.Write(&buf, le, []uint32{10, 0, caplen, 2})
+
+		r, err := newFrameReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.next(); !errors.Is(err, ErrFormat) {
+			t.Errorf("snaplen %v: expected ErrFormat, got %v", snapLen, err)
+		}
+	}
+}
+
+func Test_ngReader(t *testing.T) {
+	le := binary.LittleEndian
+	block := func(buf *bytes.Buffer, typ uint32, body ...uint32) {
+		size := uint32(12 + 4*len(body))
+		binary.Write(buf, le, []uint32{typ, size})
+		binary.Write(buf, le, body)
+		binary.Write(buf, le, size)
+	}
+	var buf bytes.Buffer
+	block(&buf, blockSectionHeader, byteOrderMagic, 1, 0xffffffff, 0xffffffff)
+	block(&buf, blockInterface, linkRaw, 65535)
+	block(&buf, blockSimplePacket, 2, 0x45)
+
+	r, err := newFrameReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.linkType != linkRaw || !bytes.Equal(f.data, []byte{0x45, 0}) {
+		t.Errorf("%+v", f)
+	}
+	if _, err := r.next(); err != io.EOF {
+		t.Error("expected io.EOF, got", err)
+	}
+
+	// block larger than allowed
+	buf.Reset()
+	block(&buf, blockSectionHeader, byteOrderMagic, 1, 0xffffffff, 0xffffffff)
+	binary.Write(&buf, le, []uint32{blockEnhancedPacket, maxBlockSize + 4})
+	r, _ = newFrameReader(&buf)
+	if _, err := r.next(); !errors.Is(err, ErrFormat) {
+		t.Error("expected ErrFormat, got", err)
+	}
+
+	// packets before section header
+	buf.Reset()
+	block(&buf, blockInterface, linkRaw, 65535)
+	r = &ngReader{r: &buf}
+	if _, err := r.next(); !errors.Is(err, ErrFormat) {
+		t.Error("expected ErrFormat, got", err)
+	}
+}
+
+func Test_ngInterface_time(t *testing.T) {
+	cases := []struct {
+		tsresol byte
+		ts      uint64
+		exp     time.Time
+	}{
+		{6, 1_500_000, time.Unix(1, 500_000_000)},
+		{9, 1_000_000_001, time.Unix(1, 1)},
+		{0, 3, time.Unix(3, 0)},
+		{0x80 | 10, 1024 + 512, time.Unix(1, 500_000_000)},
+	}
+	for _, c := range cases {
+		i := ngInterface{tsresol: c.tsresol}
+		if got := i.time(c.ts); !got.Equal(c.exp) {
+			t.Errorf("tsresol %x: got %v, expected %v", c.tsresol, got, c.exp)
+		}
+	}
+}
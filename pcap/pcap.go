@@ -0,0 +1,125 @@
+/*
+Package pcap decodes mqtt control packets from network captures.
+
+Both classic pcap and pcapng files, as written by e.g. tcpdump, are
+supported. TCP streams to and from the mqtt port are reassembled per
+direction, handling segmentation, retransmits and out of order
+segments, and decoded using mq.ReadPacket.
+*/
+package pcap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+// NewDecoder returns a decoder for traffic on port 1883.
+func NewDecoder() *Decoder {
+	return &Decoder{Ports: []uint16{1883}}
+}
+
+type Decoder struct {
+	// Ports on which the server listens
+	Ports []uint16
+}
+
+// Decode reads a capture and calls handle for each decoded packet
+// in capture order.
+func (d *Decoder) Decode(r io.Reader, handle func(*Event)) error {
+	frames, err := newFrameReader(r)
+	if err != nil {
+		return fmt.Errorf("Decode: %w", err)
+	}
+	streams := make(map[flow]*stream)
+	for {
+		f, err := frames.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Decode: %w", err)
+		}
+		seg := parseSegment(f)
+		if seg == nil || !d.isMQTT(seg) {
+			continue
+		}
+		key := flow{seg.src, seg.dst}
+		s, found := streams[key]
+		if !found {
+			s = &stream{}
+			streams[key] = s
+		}
+		s.add(seg)
+		for {
+			e := nextEvent(s)
+			if e == nil {
+				break
+			}
+			e.Time = f.time
+			e.Src, e.Dst = seg.src, seg.dst
+			handle(e)
+		}
+	}
+}
+
+func (d *Decoder) isMQTT(s *segment) bool {
+	for _, port := range d.Ports {
+		if s.src.Port() == port || s.dst.Port() == port {
+			return true
+		}
+	}
+	return false
+}
+
+type flow struct {
+	src, dst netip.AddrPort
+}
+
+// nextEvent returns an event for the first complete packet in the
+// stream buffer or nil if more data is needed.
+func nextEvent(s *stream) *Event {
+	n, remainingLen, err := mq.FixedHeaderLen(s.buf)
+	if err != nil {
+		// framing is lost, skip everything buffered
+		e := &Event{Raw: s.buf, Err: err}
+		s.buf = nil
+		return e
+	}
+	size := n + remainingLen
+	if n == 0 || size > len(s.buf) {
+		return nil
+	}
+	raw := s.buf[:size:size]
+	s.buf = s.buf[size:]
+	p, err := mq.ReadPacket(bytes.NewReader(raw))
+	return &Event{Packet: p, Raw: raw, Err: err}
+}
+
+// Event is one decoded packet.
+type Event struct {
+	Time time.Time
+	Src  netip.AddrPort
+	Dst  netip.AddrPort
+
+	Packet mq.Packet // nil if Err is set
+	Raw    []byte    // packet in wire format
+	Err    error
+}
+
+// String returns a timeline entry, e.g.
+//
+//	15:04:05.000000 10.0.0.1:50000 > 10.0.0.2:1883 PINGREQ ---- 2 bytes
+func (e *Event) String() string {
+	v := fmt.Sprint(e.Packet)
+	if e.Err != nil {
+		v = fmt.Sprintf("error: %v (%v bytes)", e.Err, len(e.Raw))
+	}
+	return fmt.Sprintf("%s %v > %v %s",
+		e.Time.Format("15:04:05.000000"), e.Src, e.Dst, v,
+	)
+}
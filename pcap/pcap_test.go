@@ -0,0 +1,330 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	var (
+		client = netip.MustParseAddrPort("10.0.0.1:50000")
+		server = netip.MustParseAddrPort("10.0.0.2:1883")
+		cap    = newCapture(linkEthernet)
+		in     = cap.conn(client, server)
+		out    = cap.conn(server, client)
+	)
+	connect := mq.NewConnect()
+	connect.SetClientID("pink")
+	big := mq.Pub(1, "a/b", strings.Repeat("x", 200))
+	big.SetPacketID(1)
+	ack := mq.NewPubAck()
+	ack.SetPacketID(1)
+
+	in.syn()
+	out.syn()
+	in.write(connect)
+	out.write(mq.NewConnAck())
+	// segmented, out of order and retransmitted
+	off := in.reserve(big)
+	in.sendAt(off, 50)
+	in.sendAt(off+100, 50)
+	in.sendAt(off+50, 50)
+	in.sendAt(off+50, 50)
+	in.sendAt(off+150, 50)
+	// partial retransmit
+	in.sendAt(off+120, 40)
+	in.sendAt(off+200, 50)
+	in.write(mq.NewPingReq())
+	out.write(ack)
+	out.write(mq.NewPingResp())
+
+	exp := []string{
+		connect.String(),
+		mq.NewConnAck().String(),
+		big.String(),
+		mq.NewPingReq().String(),
+		ack.String(),
+		mq.NewPingResp().String(),
+	}
+	got := decodeAll(t, NewDecoder(), cap.classic())
+	if len(got) != len(exp) {
+		t.Fatalf("got %v events, expected %v\n%v", len(got), len(exp), got)
+	}
+	for i, e := range got {
+		if e.Err != nil {
+			t.Fatal(e)
+		}
+		if v := e.Packet.String(); v != exp[i] {
+			t.Errorf("%v. got %q, expected %q", i, v, exp[i])
+		}
+	}
+	if got[0].Src != client || got[1].Src != server {
+		t.Error("wrong direction", got[0], got[1])
+	}
+	if v := got[0].String(); !strings.Contains(v, "10.0.0.1:50000 > 10.0.0.2:1883 CONNECT") {
+		t.Error(v)
+	}
+}
+
+func TestDecoder_Decode_pcapng(t *testing.T) {
+	var (
+		client = netip.MustParseAddrPort("[fd00::1]:50000")
+		server = netip.MustParseAddrPort("[fd00::2]:8883")
+		cap    = newCapture(linkEthernet)
+		in     = cap.conn(client, server)
+	)
+	in.write(mq.NewPingReq())
+	in.write(mq.NewDisconnect())
+
+	d := NewDecoder()
+	if got := decodeAll(t, d, cap.pcapng()); len(got) != 0 {
+		t.Error("decoded other port", got)
+	}
+
+	d.Ports = []uint16{8883}
+	got := decodeAll(t, d, cap.pcapng())
+	if len(got) != 2 {
+		t.Fatal(got)
+	}
+	if v := got[1].Packet.String(); !strings.HasPrefix(v, "DISCONNECT") {
+		t.Error(v)
+	}
+	if !got[0].Time.Equal(cap.start) {
+		t.Errorf("time %v, expected %v", got[0].Time, cap.start)
+	}
+}
+
+func TestDecoder_Decode_linkTypes(t *testing.T) {
+	client := netip.MustParseAddrPort("127.0.0.1:50000")
+	server := netip.MustParseAddrPort("127.0.0.1:1883")
+	for _, link := range []uint32{linkNull, linkRaw, linkLinuxSLL} {
+		cap := newCapture(link)
+		cap.conn(client, server).write(mq.NewPingReq())
+		got := decodeAll(t, NewDecoder(), cap.classic())
+		if len(got) != 1 {
+			t.Errorf("link type %v: %v", link, got)
+		}
+	}
+}
+
+func TestDecoder_Decode_malformed(t *testing.T) {
+	client := netip.MustParseAddrPort("10.0.0.1:50000")
+	server := netip.MustParseAddrPort("10.0.0.2:1883")
+	cap := newCapture(linkEthernet)
+	in := cap.conn(client, server)
+	in.send([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01})
+
+	got := decodeAll(t, NewDecoder(), cap.classic())
+	if len(got) != 1 || !errors.Is(got[0].Err, mq.ErrRemainingLength) {
+		t.Fatal(got)
+	}
+	if v := got[0].String(); !strings.Contains(v, "error") {
+		t.Error(v)
+	}
+}
+
+func TestDecoder_Decode_badFormat(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":     {},
+		"magic":     bytes.Repeat([]byte{1}, 24),
+		"truncated": append(newCapture(linkEthernet).classic(), 1, 2, 3),
+	}
+	for name, data := range cases {
+		err := NewDecoder().Decode(bytes.NewReader(data), func(*Event) {})
+		if !errors.Is(err, ErrFormat) {
+			t.Errorf("%s: expected ErrFormat, got %v", name, err)
+		}
+	}
+}
+
+func decodeAll(t *testing.T, d *Decoder, data []byte) []*Event {
+	t.Helper()
+	var events []*Event
+	err := d.Decode(bytes.NewReader(data), func(e *Event) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// ----------------------------------------
+
+// capture builds frames of TCP segments which can be written in
+// classic or pcapng format.
+type capture struct {
+	linkType uint32
+	start    time.Time
+	frames   [][]byte
+}
+
+func newCapture(linkType uint32) *capture {
+	return &capture{
+		linkType: linkType,
+		start:    time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC),
+	}
+}
+
+func (c *capture) conn(src, dst netip.AddrPort) *tcpConn {
+	return &tcpConn{c: c, src: src, dst: dst, seq: 1000}
+}
+
+func (c *capture) time(i int) time.Time {
+	return c.start.Add(time.Duration(i) * time.Millisecond)
+}
+
+func (c *capture) classic() []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, []uint32{magicMicro, 0x0004_0002, 0, 0, 65535, c.linkType})
+	for i, f := range c.frames {
+		ts := c.time(i)
+		binary.Write(&buf, le, []uint32{
+			uint32(ts.Unix()), uint32(ts.Nanosecond() / 1000),
+			uint32(len(f)), uint32(len(f)),
+		})
+		buf.Write(f)
+	}
+	return buf.Bytes()
+}
+
+func (c *capture) pcapng() []byte {
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		size := uint32(12 + len(body))
+		binary.Write(&buf, be, []uint32{typ, size})
+		buf.Write(body)
+		binary.Write(&buf, be, size)
+	}
+	var shb bytes.Buffer
+	binary.Write(&shb, be, []uint32{byteOrderMagic, 0x0001_0000, 0xffffffff, 0xffffffff})
+	block(blockSectionHeader, shb.Bytes())
+
+	// unknown block types are skipped
+	block(0x0bad, []byte{1, 2, 3, 4})
+
+	var idb bytes.Buffer
+	binary.Write(&idb, be, []uint16{uint16(c.linkType), 0})
+	binary.Write(&idb, be, uint32(65535))
+	binary.Write(&idb, be, []uint16{optionInterfaceTsRes, 1})
+	idb.Write([]byte{9, 0, 0, 0}) // nanoseconds
+	binary.Write(&idb, be, []uint16{optionEnd, 0})
+	block(blockInterface, idb.Bytes())
+
+	for i, f := range c.frames {
+		ts := uint64(c.time(i).UnixNano())
+		var epb bytes.Buffer
+		binary.Write(&epb, be, []uint32{
+			0, uint32(ts >> 32), uint32(ts), uint32(len(f)), uint32(len(f)),
+		})
+		epb.Write(f)
+		block(blockEnhancedPacket, epb.Bytes())
+	}
+	return buf.Bytes()
+}
+
+type tcpConn struct {
+	c        *capture
+	src, dst netip.AddrPort
+	seq      uint32
+	sent     []byte // all data sent after syn
+}
+
+func (t *tcpConn) syn() {
+	t.c.frames = append(t.c.frames, t.frame(t.seq, 0x02, nil))
+	t.seq++
+}
+
+// write packet in one segment
+func (t *tcpConn) write(p mq.Packet) {
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	t.send(buf.Bytes())
+}
+
+// send data in one segment
+func (t *tcpConn) send(data []byte) {
+	off := len(t.sent)
+	t.sent = append(t.sent, data...)
+	t.sendAt(off, len(data))
+}
+
+// reserve adds the packet to the sent data without sending any
+// segments. Returns the offset of the packet for use with sendAt.
+func (t *tcpConn) reserve(p mq.Packet) int {
+	off := len(t.sent)
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	t.sent = append(t.sent, buf.Bytes()...)
+	return off
+}
+
+// sendAt sends at most size bytes of sent data starting at offset
+// in one segment. Sending the same offset twice is a retransmit.
+func (t *tcpConn) sendAt(offset, size int) {
+	if offset+size > len(t.sent) {
+		size = len(t.sent) - offset
+	}
+	data := t.sent[offset : offset+size]
+	t.c.frames = append(t.c.frames, t.frame(t.seq+uint32(offset), 0x18, data))
+}
+
+func (t *tcpConn) frame(seq uint32, flags byte, data []byte) []byte {
+	be := binary.BigEndian
+	tcp := make([]byte, 20, 20+len(data))
+	be.PutUint16(tcp[0:], t.src.Port())
+	be.PutUint16(tcp[2:], t.dst.Port())
+	be.PutUint32(tcp[4:], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, data...)
+
+	var ip []byte
+	var ethertype uint16
+	if t.src.Addr().Is4() {
+		ethertype = 0x0800
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		be.PutUint16(ip[2:], uint16(20+len(tcp)))
+		ip[9] = 6
+		src, dst := t.src.Addr().As4(), t.dst.Addr().As4()
+		copy(ip[12:], src[:])
+		copy(ip[16:], dst[:])
+	} else {
+		ethertype = 0x86dd
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		be.PutUint16(ip[4:], uint16(len(tcp)))
+		ip[6] = 6
+		src, dst := t.src.Addr().As16(), t.dst.Addr().As16()
+		copy(ip[8:], src[:])
+		copy(ip[24:], dst[:])
+	}
+	ip = append(ip, tcp...)
+
+	switch t.c.linkType {
+	case linkNull:
+		return append([]byte{2, 0, 0, 0}, ip...)
+	case linkRaw:
+		return ip
+	case linkLinuxSLL:
+		sll := make([]byte, 16)
+		be.PutUint16(sll[14:], ethertype)
+		return append(sll, ip...)
+	}
+	eth := make([]byte, 14)
+	be.PutUint16(eth[12:], ethertype)
+	return append(eth, ip...)
+}
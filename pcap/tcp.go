@@ -0,0 +1,183 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// Link types, see https://www.tcpdump.org/linktypes.html
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+)
+
+// segment is the relevant part of one TCP segment
+type segment struct {
+	src, dst netip.AddrPort
+	seq      uint32
+	syn      bool
+	data     []byte
+}
+
+// parseSegment returns nil if the frame does not contain a TCP
+// segment.
+func parseSegment(f *frame) *segment {
+	data := f.data
+	var ethertype uint16
+	switch f.linkType {
+	case linkNull:
+		if len(data) < 4 {
+			return nil
+		}
+		// address family in host byte order, IPv4 is 2 everywhere
+		// while IPv6 differs between systems
+		if data[0] == 2 || data[3] == 2 {
+			ethertype = 0x0800
+		} else {
+			ethertype = 0x86dd
+		}
+		data = data[4:]
+
+	case linkEthernet:
+		if len(data) < 14 {
+			return nil
+		}
+		ethertype = binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for ethertype == 0x8100 && len(data) >= 4 { // VLAN tags
+			ethertype = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+
+	case linkRaw:
+		if len(data) == 0 {
+			return nil
+		}
+		switch data[0] >> 4 {
+		case 4:
+			ethertype = 0x0800
+		case 6:
+			ethertype = 0x86dd
+		}
+
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return nil
+		}
+		ethertype = binary.BigEndian.Uint16(data[14:])
+		data = data[16:]
+
+	default:
+		return nil
+	}
+
+	var (
+		s       segment
+		srcAddr netip.Addr
+		dstAddr netip.Addr
+	)
+	switch ethertype {
+	case 0x0800:
+		if len(data) < 20 || data[9] != 6 { // TCP
+			return nil
+		}
+		ihl := int(data[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(data[2:]))
+		if ihl < 20 || total < ihl || total > len(data) {
+			return nil
+		}
+		srcAddr = netip.AddrFrom4([4]byte(data[12:16]))
+		dstAddr = netip.AddrFrom4([4]byte(data[16:20]))
+		data = data[ihl:total]
+
+	case 0x86dd:
+		if len(data) < 40 || data[6] != 6 { // no extension headers
+			return nil
+		}
+		payload := int(binary.BigEndian.Uint16(data[4:]))
+		if 40+payload > len(data) {
+			return nil
+		}
+		srcAddr = netip.AddrFrom16([16]byte(data[8:24]))
+		dstAddr = netip.AddrFrom16([16]byte(data[24:40]))
+		data = data[40 : 40+payload]
+
+	default:
+		return nil
+	}
+
+	if len(data) < 20 {
+		return nil
+	}
+	offset := int(data[12]>>4) * 4
+	if offset < 20 || offset > len(data) {
+		return nil
+	}
+	s.src = netip.AddrPortFrom(srcAddr, binary.BigEndian.Uint16(data[0:]))
+	s.dst = netip.AddrPortFrom(dstAddr, binary.BigEndian.Uint16(data[2:]))
+	s.seq = binary.BigEndian.Uint32(data[4:])
+	s.syn = data[13]&0x02 != 0
+	s.data = data[offset:]
+	return &s
+}
+
+// ----------------------------------------
+
+// stream reassembles one direction of a TCP connection.
+type stream struct {
+	started bool
+	next    uint32            // next expected sequence number
+	pending map[uint32][]byte // out of order segments
+	buf     []byte            // in order data not yet consumed
+}
+
+// add segment data to the stream. Retransmitted data is dropped and
+// segments arriving early are kept until the gap is filled.
+func (s *stream) add(seg *segment) {
+	if seg.syn {
+		s.started = true
+		s.next = seg.seq + 1
+		s.pending = nil
+		s.buf = nil
+		return
+	}
+	if len(seg.data) == 0 {
+		return
+	}
+	if !s.started {
+		// capture started mid connection
+		s.started = true
+		s.next = seg.seq
+	}
+	if s.pending == nil {
+		s.pending = make(map[uint32][]byte)
+	}
+	if old, found := s.pending[seg.seq]; !found || len(old) < len(seg.data) {
+		s.pending[seg.seq] = seg.data
+	}
+	s.drain()
+}
+
+// drain moves pending segments that overlap the next expected
+// sequence number into buf.
+func (s *stream) drain() {
+	for again := true; again; {
+		again = false
+		for seq, data := range s.pending {
+			ahead := int32(seq - s.next)
+			if ahead > 0 {
+				continue // gap
+			}
+			delete(s.pending, seq)
+			if -int(ahead) >= len(data) {
+				continue // retransmit
+			}
+			data = data[-ahead:]
+			s.buf = append(s.buf, data...)
+			s.next += uint32(len(data))
+			again = true
+		}
+	}
+}
@@ -13,6 +13,12 @@ type PingReq struct {
 	fixed bits
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *PingReq) WellFormed() *Malformed {
+	return wellFormedFixed(p, p.fixed, 0)
+}
+
 func (p *PingReq) String() string {
 	return fmt.Sprintf("%s %v bytes",
 		firstByte(p.fixed).String(),
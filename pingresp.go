@@ -13,6 +13,12 @@ type PingResp struct {
 	fixed bits
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *PingResp) WellFormed() *Malformed {
+	return wellFormedFixed(p, p.fixed, 0)
+}
+
 func (p *PingResp) String() string {
 	return fmt.Sprintf("%s %v bytes",
 		firstByte(p.fixed).String(),
@@ -21,6 +21,18 @@ type PubAck struct {
 	UserProperties
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *PubAck) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	if p.packetID == 0 {
+		return newMalformed(p, "packet ID", "empty")
+	}
+	return wellFormedReason(p, PUBACK)
+}
+
 func (p *PubAck) String() string {
 	return withReason(p, fmt.Sprintf("%s p%v %v bytes",
 		firstByte(p.fixed).String(),
@@ -29,7 +41,7 @@ func (p *PubAck) String() string {
 	))
 }
 
-func (p *PubAck) dump(w io.Writer) {
+func (p *PubAck) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
 	fmt.Fprintf(w, "ReasonString: %v\n", p.ReasonString())
 	fmt.Fprintf(w, "ReasonCode: %v\n", p.ReasonCode())
@@ -67,9 +79,12 @@ func (p *PubAck) fill(b []byte, i int) int {
 func (p *PubAck) variableHeader(b []byte, i int) int {
 	n := i
 	i += p.packetID.fill(b, i)
-	i += p.reasonCode.fillOpt(b, i)
-
+	// reason code may be omitted on success without properties
 	propl := vbint(p.properties(_LEN, 0))
+	if p.reasonCode == 0 && propl == 0 {
+		return i - n
+	}
+	i += p.reasonCode.fill(b, i)
 	if propl > 0 {
 		i += propl.fill(b, i)   // Properties len
 		i += p.properties(b, i) // Properties
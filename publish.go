@@ -3,6 +3,7 @@ package mq
 import (
 	"fmt"
 	"io"
+	"net"
 )
 
 // Pub is a convenience method for creating a publish packet.
@@ -38,6 +39,11 @@ type Publish struct {
 }
 
 func (p *Publish) String() string {
+	return p.describe(p.width())
+}
+
+// describe returns the String of p with the given size in bytes.
+func (p *Publish) describe(size int) string {
 	topic := string(p.topicName)
 	if v := uint16(p.topicAlias); v > 0 {
 		topic = fmt.Sprintf("topic:%v", v)
@@ -53,17 +59,21 @@ func (p *Publish) String() string {
 			}
 			return " " + string(p.correlationData)
 		}(),
-		p.width(),
+		size,
 	))
 }
 
-func (p *Publish) dump(w io.Writer) {
+func (p *Publish) dump(w io.Writer, d *Dumper) {
+	p.dumpWith(w, d.payload(p))
+}
+
+func (p *Publish) dumpWith(w io.Writer, payload string) {
 	fmt.Fprintf(w, "ContentType: %v\n", p.ContentType())
 	fmt.Fprintf(w, "CorrelationData: %v\n", p.CorrelationData())
 	fmt.Fprintf(w, "Duplicate: %v\n", p.Duplicate())
 	fmt.Fprintf(w, "MessageExpiryInterval: %v\n", p.MessageExpiryInterval())
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
-	fmt.Fprintf(w, "Payload: %v\n", p.Payload())
+	fmt.Fprintf(w, "Payload: %v\n", payload)
 	fmt.Fprintf(w, "PayloadFormat: %v\n", p.PayloadFormat())
 	fmt.Fprintf(w, "QoS: %v\n", p.QoS())
 	fmt.Fprintf(w, "ResponseTopic: %v\n", p.ResponseTopic())
@@ -88,6 +98,10 @@ func (p *Publish) WellFormed() *Malformed {
 		}
 	case 3:
 		return newMalformed(p, "QoS", "invalid")
+	default:
+		if p.Duplicate() {
+			return newMalformed(p, "DUP", "set for QoS 0")
+		}
 	}
 
 	return nil
@@ -174,7 +188,38 @@ func (p *Publish) Payload() []byte     { return []byte(p.payload) }
 // end settings
 // ----------------------------------------
 
+// WriteTo writes the packet to w with one call to w.Write. Payloads of
+// vectorMin bytes or more written to a *net.TCPConn or *net.UnixConn
+// are not copied, the header and payload are written as net.Buffers
+// using writev. Other writers, e.g. a tls.Conn or websocket, would
+// get one Write per buffer and are given a single copy instead.
 func (p *Publish) WriteTo(w io.Writer) (int64, error) {
+	switch w.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		if len(p.payload) >= vectorMin {
+			return p.writeVectored(w)
+		}
+	}
+	return p.writeOnce(w)
+}
+
+// writeVectored writes the header and payload as net.Buffers without
+// copying the payload.
+func (p *Publish) writeVectored(w io.Writer) (int64, error) {
+	header := make([]byte, p.fillHeader(_LEN, 0, len(p.payload)))
+	p.fillHeader(header, 0, len(p.payload))
+	bufs := net.Buffers{header, p.payload}
+	return bufs.WriteTo(w)
+}
+
+// vectorMin is the payload size from which Publish.WriteTo avoids
+// copying the payload on connections supporting writev. Below it one
+// write of a copy is cheaper.
+const vectorMin = 4 << 10
+
+// writeOnce writes the packet, including a copy of the payload, with
+// one call to w.Write.
+func (p *Publish) writeOnce(w io.Writer) (int64, error) {
 	b := make([]byte, p.fill(_LEN, 0))
 	p.fill(b, 0)
 	n, err := w.Write(b)
@@ -186,20 +231,24 @@ func (p *Publish) width() int {
 }
 
 func (p *Publish) fill(b []byte, i int) int {
-	remainingLen := vbint(p.variableHeader(_LEN, 0))
-
+	i += p.fillHeader(b, i, len(p.payload))
 	if len(p.payload) > 0 {
-		remainingLen += vbint(p.payload.fill(_LEN, 0))
+		i += p.payload.fill(b, i) // payload
 	}
 
+	return i
+}
+
+// fillHeader fills everything before a payload of the given size.
+func (p *Publish) fillHeader(b []byte, i, payloadLen int) int {
+	n := i
+	remainingLen := vbint(p.variableHeader(_LEN, 0) + payloadLen)
+
 	i += p.fixed.fill(b, i)      // firstByte header
 	i += remainingLen.fill(b, i) // remaining length
 	i += p.variableHeader(b, i)  // variable header
-	if len(p.payload) > 0 {
-		i += p.payload.fill(b, i) // payload
-	}
 
-	return i
+	return i - n
 }
 func (p *Publish) variableHeader(b []byte, i int) int {
 	n := i
@@ -3,7 +3,9 @@ package mq
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"reflect"
 	"testing"
 
@@ -103,6 +105,86 @@ func TestComparePublish(t *testing.T) {
 	compare(t, our, their)
 }
 
+func TestPublish_WriteTo_vectored(t *testing.T) {
+	payload := bytes.Repeat([]byte("gopher"), vectorMin)
+	p := Pub(1, "a/b", "")
+	p.SetPacketID(1)
+	p.SetPayload(payload)
+
+	var w writeRecorder
+	p.writeVectored(&w)
+	if len(w) != 2 || &w[1][0] != &payload[0] {
+		t.Error("payload was copied")
+	}
+
+	var once bytes.Buffer
+	p.writeOnce(&once)
+	if got := bytes.Join(w, nil); !bytes.Equal(got, once.Bytes()) {
+		t.Error("differs from writeOnce")
+	}
+
+	// only connections supporting writev get more than one write
+	w = nil
+	if n, err := p.WriteTo(&w); err != nil || n != int64(once.Len()) {
+		t.Errorf("wrote %v bytes, %v expected %v", n, err, once.Len())
+	}
+	if len(w) != 1 {
+		t.Errorf("%v writes to %T", len(w), &w)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		p.WriteTo(c)
+		c.Close()
+	}()
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, _ := io.ReadAll(c)
+	if !bytes.Equal(got, once.Bytes()) {
+		t.Error("differs over tcp")
+	}
+}
+
+// writeRecorder keeps each slice given to Write.
+type writeRecorder [][]byte
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	*w = append(*w, p)
+	return len(p), nil
+}
+
+func BenchmarkPublish_large(b *testing.B) {
+	p := Pub(1, "firmware/v2", "")
+	p.SetPacketID(1)
+	p.SetPayload(make([]byte, 1<<20))
+
+	b.Run("vectored", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(p.width()))
+		for i := 0; i < b.N; i++ {
+			p.writeVectored(ioutil.Discard)
+		}
+	})
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(p.width()))
+		for i := 0; i < b.N; i++ {
+			p.writeOnce(ioutil.Discard)
+		}
+	})
+}
+
 func BenchmarkPublish(b *testing.B) {
 	b.Run("our", func(b *testing.B) {
 		var buf bytes.Buffer
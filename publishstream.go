@@ -0,0 +1,230 @@
+package mq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewPublishStream returns an empty publish packet with a streamed
+// payload.
+func NewPublishStream() *PublishStream {
+	return &PublishStream{
+		Publish: NewPublish(),
+	}
+}
+
+// PublishStream is a publish packet whose payload is read from an
+// io.Reader when written and exposed as one when read with
+// ReadStream. Headers and properties are kept in the embedded
+// Publish, its payload is not used. Methods of Publish which use the
+// payload are redefined for the stream. Use it for payloads that
+// should not be loaded into memory, e.g. firmware images.
+type PublishStream struct {
+	*Publish
+
+	payload io.Reader
+	size    int64
+}
+
+// SetPayload sets the payload to size bytes read from r once the
+// packet is written.
+func (p *PublishStream) SetPayload(r io.Reader, size int64) {
+	p.payload = r
+	p.size = size
+}
+
+// Payload returns the payload reader. For packets read with
+// ReadStream it must be read to the end before reading the next
+// packet from the same source. It returns io.ErrUnexpectedEOF if the
+// source ends before PayloadSize bytes are read.
+func (p *PublishStream) Payload() io.Reader { return p.payload }
+
+// PayloadSize returns the number of payload bytes.
+func (p *PublishStream) PayloadSize() int64 { return p.size }
+
+func (p *PublishStream) String() string {
+	return p.describe(p.width())
+}
+
+func (p *PublishStream) dump(w io.Writer, d *Dumper) {
+	p.dumpWith(w, fmt.Sprintf("%v bytes", p.size))
+}
+
+// WriteTo writes the headers and properties followed by the payload
+// copied from the payload reader.
+func (p *PublishStream) WriteTo(w io.Writer) (int64, error) {
+	remainingLen := int64(p.Publish.variableHeader(_LEN, 0)) + p.size
+	if remainingLen > maxRemainingLen {
+		return 0, fmt.Errorf("PublishStream.WriteTo: %w", ErrPacketTooLarge)
+	}
+	b := make([]byte, p.headerWidth())
+	p.fillHeader(b, 0, int(p.size))
+
+	n, err := w.Write(b)
+	if err != nil {
+		return int64(n), err
+	}
+	if p.size == 0 {
+		return int64(n), nil
+	}
+	if p.payload == nil {
+		return int64(n), fmt.Errorf("PublishStream.WriteTo: missing payload")
+	}
+	m, err := io.CopyN(w, p.payload, p.size)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return int64(n) + m, err
+}
+
+// UnmarshalBinary unmarshals data, the payload reads from the
+// remaining data.
+func (p *PublishStream) UnmarshalBinary(data []byte) error {
+	if p.Publish == nil {
+		p.Publish = NewPublish()
+	}
+	if err := p.Publish.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	payload := p.Publish.Payload()
+	p.Publish.SetPayload(nil)
+	p.SetPayload(bytes.NewReader(payload), int64(len(payload)))
+	return nil
+}
+
+// Clone returns a copy of p sharing the payload reader, i.e. only one
+// of them can be written.
+func (p *PublishStream) Clone() *PublishStream {
+	return &PublishStream{
+		Publish: p.Publish.Clone(),
+		payload: p.payload,
+		size:    p.size,
+	}
+}
+
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification or if PayloadSize differs from the unread length
+// of the payload, for readers with a Len method, e.g. bytes.Reader.
+func (p *PublishStream) WellFormed() *Malformed {
+	if err := p.Publish.WellFormed(); err != nil {
+		err.SetPacket(p)
+		return err
+	}
+	if p.size > 0 && p.payload == nil {
+		return newMalformed(p, "payload", "missing")
+	}
+	if r, ok := p.payload.(interface{ Len() int }); ok && int64(r.Len()) != p.size {
+		return newMalformed(p, "payload", fmt.Sprintf(
+			"size %v, reader has %v bytes", p.size, r.Len(),
+		))
+	}
+	return nil
+}
+
+func (p *PublishStream) width() int {
+	return p.headerWidth() + int(p.size)
+}
+
+// headerWidth returns the number of bytes written before the
+// payload.
+func (p *PublishStream) headerWidth() int {
+	return p.fillHeader(_LEN, 0, int(p.size))
+}
+
+// ErrPacketTooLarge is returned when the remaining length exceeds the
+// maximum of a variable byte integer.
+var ErrPacketTooLarge = fmt.Errorf("packet too large")
+
+const maxRemainingLen = 268_435_455
+
+// ----------------------------------------
+
+// ReadStream reads one packet from the reader like ReadPacket, except
+// that publish packets are returned as *PublishStream. Headers and
+// properties are read, the payload is left in r to be read with
+// PublishStream.Payload.
+func ReadStream(r io.Reader) (ControlPacket, error) {
+	var fh fixedHeader
+	if _, err := fh.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("ReadStream: %w", err)
+	}
+	if byte(fh.fixed)&0b1111_0000 != PUBLISH {
+		return fh.ReadRemaining(r)
+	}
+
+	p := &PublishStream{Publish: &Publish{fixed: fh.fixed}}
+	lr := &io.LimitedReader{R: r, N: int64(fh.remainingLen)}
+	header, err := readPublishHeader(lr, p.QoS())
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%s %v ReadStream: %w",
+			firstByte(fh.fixed).String(), fh.remainingLen, err,
+		)
+	}
+	if err := p.Publish.UnmarshalBinary(header); err != nil {
+		return nil, fmt.Errorf(
+			"%s %v UnmarshalBinary: %w",
+			firstByte(fh.fixed).String(), fh.remainingLen, err,
+		)
+	}
+	p.SetPayload(&payloadReader{lr}, lr.N)
+	return p, nil
+}
+
+// readPublishHeader reads the publish variable header, i.e. topic
+// name, packet ID and properties, from r.
+func readPublishHeader(r *io.LimitedReader, qos uint8) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, shortHeader(err)
+	}
+	n := int(header[0])<<8 | int(header[1])
+	if qos == 1 || qos == 2 {
+		n += 2 // packet ID
+	}
+	if int64(n) > r.N {
+		return nil, io.ErrUnexpectedEOF
+	}
+	header = append(header, make([]byte, n)...)
+	if _, err := io.ReadFull(r, header[2:]); err != nil {
+		return nil, shortHeader(err)
+	}
+
+	var propLen vbint
+	if _, err := propLen.ReadFrom(r); err != nil {
+		return nil, shortHeader(err)
+	}
+	if int64(propLen) > r.N {
+		return nil, io.ErrUnexpectedEOF
+	}
+	i := len(header)
+	header = append(header, make([]byte, propLen.width()+int(propLen))...)
+	i += propLen.fill(header, i)
+	if _, err := io.ReadFull(r, header[i:]); err != nil {
+		return nil, shortHeader(err)
+	}
+	return header, nil
+}
+
+func shortHeader(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// payloadReader returns io.ErrUnexpectedEOF if the underlying reader
+// ends before the limit is reached.
+type payloadReader struct {
+	*io.LimitedReader
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	n, err := r.LimitedReader.Read(p)
+	if errors.Is(err, io.EOF) && r.N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,245 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func ExamplePublishStream() {
+	p := NewPublishStream()
+	p.SetQoS(1)
+	p.SetPacketID(7)
+	p.SetTopicName("firmware/v2")
+	p.SetContentType("application/octet-stream")
+	p.SetPayload(strings.NewReader("...image..."), 11)
+
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+
+	in, _ := ReadStream(&buf)
+	fmt.Println(in)
+	io.Copy(os.Stdout, in.(*PublishStream).Payload())
+	// output:
+	// PUBLISH --1- p7 firmware/v2 56 bytes
+	// ...image...
+}
+
+func TestPublishStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("gopher"), 100_000)
+	p := NewPublishStream()
+	p.SetQoS(2)
+	p.SetPacketID(3)
+	p.SetTopicName("a/b")
+	p.SetCorrelationData([]byte("corr"))
+	p.AddUserProp("color", "red")
+	p.SetPayload(bytes.NewReader(payload), int64(len(payload)))
+
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) || n != int64(p.width()) {
+		t.Errorf("wrote %v bytes, buffer %v, width %v", n, buf.Len(), p.width())
+	}
+	data := buf.Bytes()
+
+	// same wire format as Publish
+	pub, err := ReadPacket(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pub.(*Publish).Payload(), payload) {
+		t.Error("ReadPacket payload differs")
+	}
+
+	// followed by another packet
+	r := bytes.NewReader(append(data, 0b1100_0000, 0)) // PINGREQ
+	in, err := ReadStream(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := in.(*PublishStream)
+	if s.PacketID() != 3 || s.TopicName() != "a/b" || s.PayloadSize() != int64(len(payload)) {
+		t.Error("unexpected", s)
+	}
+	got, err := io.ReadAll(s.Payload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("ReadStream payload differs")
+	}
+	if next, err := ReadStream(r); err != nil {
+		t.Error(err)
+	} else if _, ok := next.(*PingReq); !ok {
+		t.Error("expected PINGREQ, got", next)
+	}
+
+	var out bytes.Buffer
+	Dump(&out, s)
+	if v := out.String(); !strings.Contains(v, "Payload: 600000 bytes") {
+		t.Error(v)
+	}
+}
+
+func TestPublishStream_UnmarshalBinary(t *testing.T) {
+	in := Pub(1, "a/b", "gopher")
+	in.SetPacketID(1)
+	var buf bytes.Buffer
+	in.WriteTo(&buf)
+
+	var fh fixedHeader
+	fh.ReadFrom(&buf)
+	p := &PublishStream{Publish: &Publish{fixed: fh.fixed}}
+	if err := p.UnmarshalBinary(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(p.Payload()); string(got) != "gopher" {
+		t.Error("payload", string(got))
+	}
+}
+
+func TestPublishStream_WriteTo(t *testing.T) {
+	p := NewPublishStream()
+	p.SetTopicName("a/b")
+
+	p.SetPayload(strings.NewReader("short"), 10)
+	if _, err := p.WriteTo(io.Discard); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+
+	p.SetPayload(nil, 10)
+	if _, err := p.WriteTo(io.Discard); err == nil {
+		t.Error("expected error on missing payload")
+	}
+
+	p.SetPayload(nil, maxRemainingLen)
+	if _, err := p.WriteTo(io.Discard); !errors.Is(err, ErrPacketTooLarge) {
+		t.Error("expected ErrPacketTooLarge, got", err)
+	}
+
+	// constant memory
+	const size = 64 << 20
+	p.SetPayload(io.LimitReader(zeros{}, size), size)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := p.WriteTo(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if v := after.TotalAlloc - before.TotalAlloc; v > 1<<20 {
+		t.Errorf("allocated %v bytes writing %v", v, size)
+	}
+}
+
+func TestPublishStream_overrides(t *testing.T) {
+	p := NewPublishStream()
+	p.SetQoS(1)
+	p.SetPacketID(2)
+	p.SetTopicName("a/b")
+	p.SetPayload(strings.NewReader("gopher"), 6)
+
+	c := p.Clone()
+	c.SetTopicName("c/d")
+	if p.TopicName() != "a/b" || c.PayloadSize() != 6 || c.Payload() != p.Payload() {
+		t.Error("Clone", c)
+	}
+	if _, ok := clonePacket(p).(*PublishStream); !ok {
+		t.Error("clonePacket lost stream")
+	}
+
+	if err := p.WellFormed(); err != nil {
+		t.Error(err)
+	}
+	p.SetPayload(strings.NewReader("short"), 6)
+	if err := p.WellFormed(); err == nil {
+		t.Error("expected Malformed on size mismatch")
+	}
+	p.SetPayload(nil, 6)
+	if err := p.WellFormed(); err == nil {
+		t.Error("expected Malformed on missing payload")
+	}
+
+	p.SetPayload(strings.NewReader("gopher"), 6)
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := string(data); !strings.Contains(v, `"PayloadSize":6`) {
+		t.Error(v)
+	}
+	in := Pub(1, "a/b", "gopher")
+	data, _ = json.Marshal(in)
+	var got PublishStream
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := io.ReadAll(got.Payload())
+	if got.TopicName() != "a/b" || got.PayloadSize() != 6 || string(payload) != "gopher" {
+		t.Error("UnmarshalJSON", got.String(), string(payload))
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	// other packets are read in full
+	var buf bytes.Buffer
+	NewPingReq().WriteTo(&buf)
+	if p, err := ReadStream(&buf); err != nil {
+		t.Error(err)
+	} else if _, ok := p.(*PingReq); !ok {
+		t.Error("expected PINGREQ, got", p)
+	}
+
+	if _, err := ReadStream(&buf); !errors.Is(err, io.EOF) {
+		t.Error("expected io.EOF, got", err)
+	}
+
+	p := Pub(1, "a/b", "gopher")
+	p.SetPacketID(1)
+	p.SetContentType("text/plain")
+	buf.Reset()
+	p.WriteTo(&buf)
+	data := buf.Bytes()
+
+	// truncated header
+	for _, n := range []int{3, 6, 8, 12} {
+		_, err := ReadStream(bytes.NewReader(data[:n]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%v bytes: expected io.ErrUnexpectedEOF, got %v", n, err)
+		}
+	}
+
+	// truncated payload
+	s, err := ReadStream(bytes.NewReader(data[:len(data)-2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(s.(*PublishStream).Payload())
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+
+	// remaining length shorter than the header
+	short := append([]byte{}, data...)
+	short[1] = 4
+	if _, err := ReadStream(bytes.NewReader(short)); err == nil {
+		t.Error("expected error on short remaining length")
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
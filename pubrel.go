@@ -23,6 +23,18 @@ type PubRel struct {
 	UserProperties
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *PubRel) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0b0010); err != nil {
+		return err
+	}
+	if p.packetID == 0 {
+		return newMalformed(p, "packet ID", "empty")
+	}
+	return wellFormedReason(p, PUBREL)
+}
+
 func (p *PubRel) String() string {
 	return withReason(p, fmt.Sprintf("%s p%v %v bytes",
 		firstByte(p.fixed).String(),
@@ -31,7 +43,7 @@ func (p *PubRel) String() string {
 	))
 }
 
-func (p *PubRel) dump(w io.Writer) {
+func (p *PubRel) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
 	fmt.Fprintf(w, "ReasonString: %v\n", p.ReasonString())
 	fmt.Fprintf(w, "ReasonCode: %v\n", p.ReasonCode())
@@ -69,9 +81,12 @@ func (p *PubRel) fill(b []byte, i int) int {
 func (p *PubRel) variableHeader(b []byte, i int) int {
 	n := i
 	i += p.packetID.fill(b, i)
-	i += p.reasonCode.fillOpt(b, i)
-
+	// reason code may be omitted on success without properties
 	propl := vbint(p.properties(_LEN, 0))
+	if p.reasonCode == 0 && propl == 0 {
+		return i - n
+	}
+	i += p.reasonCode.fill(b, i)
 	if propl > 0 {
 		i += propl.fill(b, i)   // Properties len
 		i += p.properties(b, i) // Properties
@@ -52,3 +52,127 @@ const (
 	SubscriptionIdentifiersNotSupported ReasonCode = 0xA1 // SubAck, Disconnect
 	WildcardSubscriptionsNotSupported   ReasonCode = 0xA2 // SubAck, Disconnect
 )
+
+// IsError returns true for reason codes indicating failure, i.e.
+// values of 0x80 or greater.
+func (c ReasonCode) IsError() bool { return c >= 0x80 }
+
+// ValidFor returns true if the reason code may be used in packets of
+// the given type, e.g. PUBACK.
+func (c ReasonCode) ValidFor(packetType byte) bool {
+	return reasonInfo[c].packets&packetMask(packetType) != 0
+}
+
+// NameFor returns the name of the reason code in packets of the
+// given type. It differs from String only for 0x00 which is named
+// GrantedQoS0 in SUBACK and NormalDisconnect in DISCONNECT.
+func (c ReasonCode) NameFor(packetType byte) string {
+	if c == 0 {
+		switch packetType & 0b1111_0000 {
+		case SUBACK:
+			return "GrantedQoS0"
+		case DISCONNECT:
+			return "NormalDisconnect"
+		}
+	}
+	return c.String()
+}
+
+// Description returns the reason code name as given in the
+// specification, e.g. "Packet Identifier in use". Empty for unknown
+// reason codes.
+func (c ReasonCode) Description() string {
+	return reasonInfo[c].description
+}
+
+// DescriptionFor returns the description of the reason code in
+// packets of the given type, see NameFor.
+func (c ReasonCode) DescriptionFor(packetType byte) string {
+	if c == 0 {
+		switch packetType & 0b1111_0000 {
+		case SUBACK:
+			return "Granted QoS 0"
+		case DISCONNECT:
+			return "Normal disconnection"
+		}
+	}
+	return c.Description()
+}
+
+func packetMask(types ...byte) uint16 {
+	var v uint16
+	for _, t := range types {
+		v |= 1 << (t >> 4)
+	}
+	return v
+}
+
+// reasonInfo is table 2-6 Reason Codes of the specification.
+var reasonInfo = map[ReasonCode]struct {
+	description string
+	packets     uint16 // see packetMask
+}{
+	Success: {"Success", packetMask(
+		CONNACK, PUBACK, PUBREC, PUBREL, PUBCOMP, UNSUBACK, AUTH,
+		DISCONNECT, SUBACK,
+	)},
+	GrantedQoS1:           {"Granted QoS 1", packetMask(SUBACK)},
+	GrantedQoS2:           {"Granted QoS 2", packetMask(SUBACK)},
+	DisconnectWithWill:    {"Disconnect with Will Message", packetMask(DISCONNECT)},
+	NoMatchingSubscribers: {"No matching subscribers", packetMask(PUBACK, PUBREC)},
+	NoSubscriptionExisted: {"No subscription existed", packetMask(UNSUBACK)},
+	ContinueAuth:          {"Continue authentication", packetMask(AUTH)},
+	ReAuthenticate:        {"Re-authenticate", packetMask(AUTH)},
+
+	UnspecifiedError: {"Unspecified error", packetMask(
+		CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT,
+	)},
+	MalformedPacket: {"Malformed Packet", packetMask(CONNACK, DISCONNECT)},
+	ProtocolError:   {"Protocol Error", packetMask(CONNACK, DISCONNECT)},
+	ImplementationSpecificError: {"Implementation specific error", packetMask(
+		CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT,
+	)},
+	UnsupportedProtocolVersion: {"Unsupported Protocol Version", packetMask(CONNACK)},
+	ClientIdentifierNotValid:   {"Client Identifier not valid", packetMask(CONNACK)},
+	BadUserNameOrPassword:      {"Bad User Name or Password", packetMask(CONNACK)},
+	NotAuthorized: {"Not authorized", packetMask(
+		CONNACK, PUBACK, PUBREC, SUBACK, UNSUBACK, DISCONNECT,
+	)},
+	ServerUnavailable:       {"Server unavailable", packetMask(CONNACK)},
+	ServerBusy:              {"Server busy", packetMask(CONNACK, DISCONNECT)},
+	Banned:                  {"Banned", packetMask(CONNACK)},
+	ServerShuttingDown:      {"Server shutting down", packetMask(DISCONNECT)},
+	BadAuthenticationMethod: {"Bad authentication method", packetMask(CONNACK, DISCONNECT)},
+	KeepAliveTimeout:        {"Keep Alive timeout", packetMask(DISCONNECT)},
+	SessionTakenOver:        {"Session taken over", packetMask(DISCONNECT)},
+	TopicFilterInvalid: {"Topic Filter invalid", packetMask(
+		SUBACK, UNSUBACK, DISCONNECT,
+	)},
+	TopicNameInvalid: {"Topic Name invalid", packetMask(
+		CONNACK, PUBACK, PUBREC, DISCONNECT,
+	)},
+	PacketIdentifierInUse: {"Packet Identifier in use", packetMask(
+		PUBACK, PUBREC, SUBACK, UNSUBACK,
+	)},
+	PacketIdentifierNotFound: {"Packet Identifier not found", packetMask(PUBREL, PUBCOMP)},
+	ReceiveMaximumExceeded:   {"Receive Maximum exceeded", packetMask(DISCONNECT)},
+	TopicAliasInvalid:        {"Topic Alias invalid", packetMask(DISCONNECT)},
+	PacketTooLarge:           {"Packet too large", packetMask(CONNACK, DISCONNECT)},
+	MessageRateToHigh:        {"Message rate too high", packetMask(DISCONNECT)},
+	QuotaExceeded: {"Quota exceeded", packetMask(
+		CONNACK, PUBACK, PUBREC, SUBACK, DISCONNECT,
+	)},
+	AdministrativeAction: {"Administrative action", packetMask(DISCONNECT)},
+	PayloadFormatInvalid: {"Payload format invalid", packetMask(
+		CONNACK, PUBACK, PUBREC, DISCONNECT,
+	)},
+	RetainNotSupported:                  {"Retain not supported", packetMask(CONNACK, DISCONNECT)},
+	QoSNotSupported:                     {"QoS not supported", packetMask(CONNACK, DISCONNECT)},
+	UseAnotherServer:                    {"Use another server", packetMask(CONNACK, DISCONNECT)},
+	ServerMoved:                         {"Server moved", packetMask(CONNACK, DISCONNECT)},
+	SharedSubscriptionsNotSupported:     {"Shared Subscriptions not supported", packetMask(SUBACK, DISCONNECT)},
+	ConnectionRateExceeded:              {"Connection rate exceeded", packetMask(CONNACK, DISCONNECT)},
+	MaximumConnectTime:                  {"Maximum connect time", packetMask(DISCONNECT)},
+	SubscriptionIdentifiersNotSupported: {"Subscription Identifiers not supported", packetMask(SUBACK, DISCONNECT)},
+	WildcardSubscriptionsNotSupported:   {"Wildcard Subscriptions not supported", packetMask(SUBACK, DISCONNECT)},
+}
@@ -0,0 +1,83 @@
+package mq
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ExampleReasonCode_NameFor() {
+	fmt.Println(Success.NameFor(SUBACK))
+	fmt.Println(Success.NameFor(UNSUBACK))
+	fmt.Println(Success.NameFor(DISCONNECT))
+	// output:
+	// GrantedQoS0
+	// Success
+	// NormalDisconnect
+}
+
+func TestReasonCode_ValidFor(t *testing.T) {
+	valid := []struct {
+		c ReasonCode
+		t byte
+	}{
+		{Success, AUTH},
+		{GrantedQoS0, SUBACK},
+		{NormalDisconnect, DISCONNECT},
+		{Banned, CONNACK},
+		{PacketIdentifierNotFound, PUBREL},
+		{NoSubscriptionExisted, UNSUBACK},
+		{WildcardSubscriptionsNotSupported, DISCONNECT},
+	}
+	for _, v := range valid {
+		if !v.c.ValidFor(v.t) {
+			t.Errorf("%v should be valid for %s", v.c, typeNames[v.t])
+		}
+	}
+	invalid := []struct {
+		c ReasonCode
+		t byte
+	}{
+		{Banned, PUBACK},
+		{GrantedQoS1, UNSUBACK},
+		{ContinueAuth, CONNACK},
+		{Success, PUBLISH},
+		{ReasonCode(0x03), SUBACK},
+	}
+	for _, v := range invalid {
+		if v.c.ValidFor(v.t) {
+			t.Errorf("%v should not be valid for %s", v.c, typeNames[v.t])
+		}
+	}
+}
+
+func TestReasonCode_IsError(t *testing.T) {
+	if Success.IsError() || ReAuthenticate.IsError() {
+		t.Error("success codes are not errors")
+	}
+	if !UnspecifiedError.IsError() || !WildcardSubscriptionsNotSupported.IsError() {
+		t.Error("failure codes are errors")
+	}
+}
+
+func TestReasonCode_Description(t *testing.T) {
+	// every named reason code has a description
+	for i := 0; i < 256; i++ {
+		c := ReasonCode(i)
+		named := c.String() != fmt.Sprintf("ReasonCode(%v)", i)
+		if got := c.Description(); named != (got != "") {
+			t.Errorf("%v: description %q", c, got)
+		}
+	}
+	cases := map[string]string{
+		PacketIdentifierInUse.Description():  "Packet Identifier in use",
+		Success.DescriptionFor(SUBACK):       "Granted QoS 0",
+		Success.DescriptionFor(DISCONNECT):   "Normal disconnection",
+		Success.DescriptionFor(PUBACK):       "Success",
+		NotAuthorized.DescriptionFor(SUBACK): "Not authorized",
+	}
+	for got, exp := range cases {
+		if got != exp {
+			t.Errorf("got %q, expected %q", got, exp)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Package record captures mqtt traffic of one connection to a file and
+replays it.
+
+A Recorder wraps a connection and writes each packet read and written,
+in wire format, with the time and direction. The file starts with a
+header followed by one record per packet
+
+	header: "MQREC" version(1) start(int64 unix nanoseconds)
+	record: uvarint(microseconds since previous<<1 | direction) packet
+
+A Replayer reproduces the session, with the same timing, against a new
+connection.
+*/
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+// NewRecorder returns a recorder of packets read from and written to
+// rw. Records are written to w.
+func NewRecorder(rw io.ReadWriter, w io.Writer) *Recorder {
+	return &Recorder{
+		rw:  rw,
+		w:   w,
+		now: time.Now,
+	}
+}
+
+// Recorder records packets passing through it. Recording errors do
+// not affect the traffic, they are returned by Err.
+type Recorder struct {
+	rw io.ReadWriter
+
+	mu       sync.Mutex
+	w        io.Writer
+	now      func() time.Time
+	last     time.Time // of previous record, zero before header
+	received splitter
+	sent     splitter
+	err      error
+}
+
+// Read reads from the wrapped connection and records complete
+// packets as Received.
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.rw.Read(p)
+	if n > 0 {
+		r.record(Received, &r.received, p[:n])
+	}
+	return n, err
+}
+
+// Write writes to the wrapped connection and records complete packets
+// as Sent.
+func (r *Recorder) Write(p []byte) (int, error) {
+	n, err := r.rw.Write(p)
+	if n > 0 {
+		r.record(Sent, &r.sent, p[:n])
+	}
+	return n, err
+}
+
+// Err returns the first error writing records or splitting the
+// traffic into packets.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(dir Direction, s *splitter, p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	err := s.add(p, func(packet []byte) error {
+		return r.write(dir, packet)
+	})
+	if err != nil {
+		r.err = fmt.Errorf("record: %w", err)
+	}
+}
+
+func (r *Recorder) write(dir Direction, packet []byte) error {
+	now := r.now()
+	var buf bytes.Buffer
+	if r.last.IsZero() {
+		buf.WriteString(magic)
+		buf.WriteByte(version)
+		binary.Write(&buf, binary.BigEndian, now.UnixNano())
+		r.last = now
+	}
+	delta := uint64(now.Sub(r.last) / time.Microsecond)
+	buf.Write(binary.AppendUvarint(nil, delta<<1|uint64(dir)))
+	buf.Write(packet)
+	r.last = r.last.Add(time.Duration(delta) * time.Microsecond)
+
+	_, err := r.w.Write(buf.Bytes())
+	return err
+}
+
+const (
+	magic   = "MQREC"
+	version = 1
+)
+
+// ----------------------------------------
+
+// splitter splits a byte stream into packets.
+type splitter struct {
+	buf []byte
+}
+
+// add appends p and calls emit for each complete packet.
+func (s *splitter) add(p []byte, emit func([]byte) error) error {
+	s.buf = append(s.buf, p...)
+	for {
+		n, remainingLen, err := mq.FixedHeaderLen(s.buf)
+		if err != nil {
+			return err
+		}
+		size := n + remainingLen
+		if n == 0 || len(s.buf) < size {
+			return nil
+		}
+		if err := emit(s.buf[:size]); err != nil {
+			return err
+		}
+		s.buf = s.buf[size:]
+	}
+}
+
+// ----------------------------------------
+
+// NewReader returns a reader of records written by a Recorder.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Reader reads records of a recording.
+type Reader struct {
+	r    *bufio.Reader
+	last time.Time // zero before header
+}
+
+// Next returns the next record, io.EOF when there are no more.
+func (r *Reader) Next() (*Record, error) {
+	if r.last.IsZero() {
+		if err := r.header(); err != nil {
+			return nil, err
+		}
+	}
+	v, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := r.packet()
+	if err != nil {
+		return nil, fmt.Errorf("Next: %w", unexpected(err))
+	}
+	r.last = r.last.Add(time.Duration(v>>1) * time.Microsecond)
+	return &Record{
+		Time: r.last,
+		Dir:  Direction(v & 1),
+		Raw:  raw,
+	}, nil
+}
+
+// packet reads one packet in wire format without decoding it, as
+// recorded packets may be malformed.
+func (r *Reader) packet() ([]byte, error) {
+	head := make([]byte, 0, 5)
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		head = append(head, b)
+		n, remainingLen, err := mq.FixedHeaderLen(head)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			raw := make([]byte, n+remainingLen)
+			copy(raw, head)
+			_, err := io.ReadFull(r.r, raw[len(head):])
+			return raw, err
+		}
+	}
+}
+
+func (r *Reader) header() error {
+	head := make([]byte, len(magic)+1+8)
+	if _, err := io.ReadFull(r.r, head); err != nil {
+		return err // io.EOF if nothing was recorded
+	}
+	if string(head[:len(magic)]) != magic {
+		return ErrFormat
+	}
+	if v := head[len(magic)]; v != version {
+		return fmt.Errorf("%w: version %v", ErrFormat, v)
+	}
+	start := int64(binary.BigEndian.Uint64(head[len(magic)+1:]))
+	r.last = time.Unix(0, start)
+	return nil
+}
+
+// unexpected returns io.ErrUnexpectedEOF for io.EOF within a record.
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ----------------------------------------
+
+// Record is one recorded packet.
+type Record struct {
+	Time time.Time
+	Dir  Direction
+	Raw  []byte // packet in wire format
+}
+
+// Packet returns the decoded packet.
+func (r *Record) Packet() (mq.Packet, error) {
+	return mq.ReadPacket(bytes.NewReader(r.Raw))
+}
+
+// String returns a timeline entry, e.g.
+//
+//	15:04:05.000000 > PINGREQ ---- 2 bytes
+func (r *Record) String() string {
+	p, err := r.Packet()
+	v := fmt.Sprint(p)
+	if err != nil {
+		v = fmt.Sprintf("error: %v (%v bytes)", err, len(r.Raw))
+	}
+	return fmt.Sprintf("%s %v %s", r.Time.Format("15:04:05.000000"), r.Dir, v)
+}
+
+// Direction of a recorded packet, as seen by the recorded side.
+type Direction byte
+
+const (
+	Received Direction = iota
+	Sent
+)
+
+// String returns < for Received and > for Sent, as in package script.
+func (d Direction) String() string {
+	if d == Sent {
+		return ">"
+	}
+	return "<"
+}
+
+var ErrFormat = fmt.Errorf("not a recording")
@@ -0,0 +1,204 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+func Example() {
+	client, server := net.Pipe()
+	go func() {
+		mq.ReadPacket(server)
+		mq.NewConnAck().WriteTo(server)
+		server.Close()
+	}()
+
+	var recording bytes.Buffer
+	conn := NewRecorder(client, &recording)
+	mq.NewConnect().WriteTo(conn)
+	mq.ReadPacket(conn)
+
+	r := NewReader(&recording)
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			break
+		}
+		os.Stdout.WriteString(rec.String()[16:] + "\n")
+	}
+	// output:
+	// > CONNECT ---- -------- MQTT5  0s 15 bytes
+	// < CONNACK ---- --------  5 bytes
+}
+
+func TestRecorder(t *testing.T) {
+	connect := wire(mq.NewConnect())
+	ack := wire(mq.NewConnAck())
+	ping := wire(mq.NewPingReq())
+
+	conn := &fakeConn{in: bytes.NewReader(ack)}
+	var recording bytes.Buffer
+	rec := NewRecorder(conn, &recording)
+	rec.now = clock(time.Unix(100, 0), 10*time.Millisecond)
+
+	// split packet and several packets in one write
+	rec.Write(connect[:3])
+	rec.Write(connect[3:])
+	io.ReadAll(oneByteReader{rec})
+	rec.Write(append(ping, ping...))
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(conn.out.Bytes(), append(connect, append(ping, ping...)...)) {
+		t.Error("traffic modified")
+	}
+
+	exp := []Record{
+		{time.Unix(100, 0), Sent, connect},
+		{time.Unix(100, 0).Add(10 * time.Millisecond), Received, ack},
+		{time.Unix(100, 0).Add(20 * time.Millisecond), Sent, ping},
+		{time.Unix(100, 0).Add(30 * time.Millisecond), Sent, ping},
+	}
+	r := NewReader(&recording)
+	for i, e := range exp {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if !got.Time.Equal(e.Time) || got.Dir != e.Dir || !bytes.Equal(got.Raw, e.Raw) {
+			t.Errorf("%v: got %v, expected %v", i, got, &e)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Error("expected io.EOF, got", err)
+	}
+}
+
+func TestRecorder_malformed(t *testing.T) {
+	// remaining length over four bytes
+	bad := []byte{0xc0, 0xff, 0xff, 0xff, 0xff, 0x01}
+	conn := &fakeConn{in: bytes.NewReader(bad)}
+	var recording bytes.Buffer
+	rec := NewRecorder(conn, &recording)
+	got, _ := io.ReadAll(rec)
+	if !bytes.Equal(got, bad) {
+		t.Error("traffic modified")
+	}
+	if err := rec.Err(); !errors.Is(err, mq.ErrRemainingLength) {
+		t.Error("expected ErrRemainingLength, got", err)
+	}
+}
+
+func TestRecorder_recordsMalformedPayload(t *testing.T) {
+	// PUBLISH with truncated topic name
+	bad := []byte{0x30, 0x01, 0x00}
+	conn := &fakeConn{in: bytes.NewReader(bad)}
+	var recording bytes.Buffer
+	io.ReadAll(NewRecorder(conn, &recording))
+
+	rec, err := NewReader(&recording).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rec.Raw, bad) {
+		t.Errorf("got %v", rec.Raw)
+	}
+	if _, err := rec.Packet(); err == nil {
+		t.Error("expected decode error")
+	}
+	if got := rec.String(); !strings.Contains(got, "error") {
+		t.Error(got)
+	}
+}
+
+func TestRecorder_writeError(t *testing.T) {
+	conn := &fakeConn{in: bytes.NewReader(nil)}
+	rec := NewRecorder(conn, errWriter{})
+	if _, err := mq.NewPingReq().WriteTo(rec); err != nil {
+		t.Fatal("traffic affected by recording error", err)
+	}
+	if rec.Err() == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestReader(t *testing.T) {
+	var recording bytes.Buffer
+	rec := NewRecorder(&fakeConn{in: bytes.NewReader(nil)}, &recording)
+	mq.NewPingReq().WriteTo(rec)
+	valid := recording.Bytes()
+
+	cases := []struct {
+		name string
+		data []byte
+		exp  error
+	}{
+		{"empty", nil, io.EOF},
+		{"short header", valid[:4], io.ErrUnexpectedEOF},
+		{"magic", append([]byte("MQXXX"), valid[5:]...), ErrFormat},
+		{"version", append(append([]byte("MQREC"), 9), valid[6:]...), ErrFormat},
+		{"truncated packet", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(c.data)).Next()
+			if !errors.Is(err, c.exp) {
+				t.Errorf("expected %v, got %v", c.exp, err)
+			}
+		})
+	}
+}
+
+func TestDirection_String(t *testing.T) {
+	if Received.String() != "<" || Sent.String() != ">" {
+		t.Error(Received, Sent)
+	}
+}
+
+// ----------------------------------------
+
+func wire(p mq.Packet) []byte {
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// clock returns a func returning start and then advancing by step for
+// each call.
+func clock(start time.Time, step time.Duration) func() time.Time {
+	next := start
+	return func() time.Time {
+		v := next
+		next = next.Add(step)
+		return v
+	}
+}
+
+type fakeConn struct {
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (c *fakeConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *fakeConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
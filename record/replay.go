@@ -0,0 +1,88 @@
+package record
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+// NewReplayer returns a replayer of the recording read from r,
+// sending the packets recorded as Sent.
+func NewReplayer(r io.Reader) *Replayer {
+	return &Replayer{
+		records: NewReader(r),
+		send:    Sent,
+		now:     time.Now,
+		sleep:   time.Sleep,
+	}
+}
+
+// Replayer reproduces a recorded session against a new connection.
+type Replayer struct {
+	records *Reader
+	send    Direction
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// SetSend sets the direction of records to send, default Sent. Use
+// Received to play the other side of the recorded session.
+func (p *Replayer) SetSend(v Direction) { p.send = v }
+func (p *Replayer) Send() Direction     { return p.send }
+
+// Replay writes the recorded packets to rw, in wire format as
+// recorded, at the same time relative to the first record. For each
+// record in the other direction one packet is read from rw and
+// compared by type with the recorded one. Use deadlines on rw to
+// avoid waiting forever for packets that never arrive.
+func (p *Replayer) Replay(rw io.ReadWriter) error {
+	var first, start time.Time
+	for {
+		r, err := p.records.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Replay: %w", err)
+		}
+		if first.IsZero() {
+			first, start = r.Time, p.now()
+		}
+
+		if r.Dir != p.send {
+			if err := p.expect(rw, r); err != nil {
+				return fmt.Errorf("Replay: %w", err)
+			}
+			continue
+		}
+		at := start.Add(r.Time.Sub(first))
+		if d := at.Sub(p.now()); d > 0 {
+			p.sleep(d)
+		}
+		if _, err := rw.Write(r.Raw); err != nil {
+			return fmt.Errorf("Replay: %w", err)
+		}
+	}
+}
+
+// expect reads one packet from rw and compares its type with the
+// recorded one.
+func (p *Replayer) expect(rw io.Reader, r *Record) error {
+	got, err := mq.ReadPacket(rw)
+	if err != nil {
+		return err
+	}
+	exp, err := r.Packet()
+	if err != nil {
+		return fmt.Errorf("%w: got %v, expected %v", ErrMismatch, got, r)
+	}
+	if fmt.Sprintf("%T", got) != fmt.Sprintf("%T", exp) {
+		return fmt.Errorf("%w: got %v, expected %v", ErrMismatch, got, exp)
+	}
+	return nil
+}
+
+var ErrMismatch = fmt.Errorf("mismatch")
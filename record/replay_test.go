@@ -0,0 +1,116 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gregoryv/mq"
+)
+
+func TestReplayer(t *testing.T) {
+	recording := session(t)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		mq.ReadPacket(server)
+		mq.NewConnAck().WriteTo(server)
+		mq.ReadPacket(server)
+	}()
+
+	p := NewReplayer(bytes.NewReader(recording))
+	if p.Send() != Sent {
+		t.Error("default send", p.Send())
+	}
+	var slept []time.Duration
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+	p.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+	if err := p.Replay(client); err != nil {
+		t.Fatal(err)
+	}
+	// connect at 0, ping at 20ms
+	if len(slept) != 1 || slept[0] != 20*time.Millisecond {
+		t.Error("slept", slept)
+	}
+}
+
+func TestReplayer_timing(t *testing.T) {
+	recording := session(t)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		mq.ReadPacket(server)
+		mq.NewConnAck().WriteTo(server)
+		mq.ReadPacket(server)
+	}()
+	start := time.Now()
+	if err := NewReplayer(bytes.NewReader(recording)).Replay(client); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond || d > time.Second {
+		t.Error("replay took", d)
+	}
+}
+
+func TestReplayer_received(t *testing.T) {
+	recording := session(t)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		mq.NewConnect().WriteTo(server)
+		mq.ReadPacket(server)
+		mq.NewPingReq().WriteTo(server)
+	}()
+	p := NewReplayer(bytes.NewReader(recording))
+	p.SetSend(Received)
+	p.sleep = func(time.Duration) {}
+	if err := p.Replay(client); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayer_mismatch(t *testing.T) {
+	recording := session(t)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		mq.ReadPacket(server)
+		mq.NewDisconnect().WriteTo(server)
+	}()
+	p := NewReplayer(bytes.NewReader(recording))
+	p.sleep = func(time.Duration) {}
+	if err := p.Replay(client); !errors.Is(err, ErrMismatch) {
+		t.Error("expected ErrMismatch, got", err)
+	}
+}
+
+// session returns a recording of a client connecting and sending a
+// ping 20ms after the connect.
+func session(t *testing.T) []byte {
+	t.Helper()
+	conn := &fakeConn{in: bytes.NewReader(wire(mq.NewConnAck()))}
+	var recording bytes.Buffer
+	rec := NewRecorder(conn, &recording)
+	rec.now = clock(time.Unix(100, 0), 10*time.Millisecond)
+	mq.NewConnect().WriteTo(rec)
+	mq.ReadPacket(rec)
+	mq.NewPingReq().WriteTo(rec)
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return recording.Bytes()
+}
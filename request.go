@@ -0,0 +1,232 @@
+package mq
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewRequester returns a requester with a timeout of 30s. The
+// response topic is set with SetResponseTopic or derived from the
+// ConnAck using Connected, see 4.10 Request / Response
+// https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_Toc3901252
+func NewRequester() *Requester {
+	var id [8]byte
+	rand.Read(id[:])
+	return &Requester{
+		id:      id,
+		timeout: 30 * time.Second,
+		pending: make(map[string]*Call),
+	}
+}
+
+// Requester correlates requests with responses on one connection.
+// It does not send or receive packets, use Request to prepare
+// outgoing requests and Handle for incoming publish packets.
+type Requester struct {
+	id [8]byte // prefix of correlation data
+
+	mu            sync.Mutex
+	responseTopic string
+	timeout       time.Duration
+	seq           uint64
+	pending       map[string]*Call
+}
+
+// SetResponseTopic sets the topic responses are sent to.
+func (r *Requester) SetResponseTopic(v string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responseTopic = v
+}
+
+func (r *Requester) ResponseTopic() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.responseTopic
+}
+
+// SetTimeout sets the time to wait for a response, 0 means no
+// timeout.
+func (r *Requester) SetTimeout(v time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = v
+}
+
+func (r *Requester) Timeout() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timeout
+}
+
+// Connected sets the response topic to the ResponseInformation of
+// p followed by a unique level for this requester. The connect
+// packet must have RequestResponseInfo set for the server to include
+// it. Returns ErrNoResponseInformation if missing.
+func (r *Requester) Connected(p *ConnAck) error {
+	info := p.ResponseInformation()
+	if info == "" {
+		return fmt.Errorf("Connected: %w", ErrNoResponseInformation)
+	}
+	r.SetResponseTopic(
+		strings.TrimSuffix(info, "/") + "/" + hex.EncodeToString(r.id[:4]),
+	)
+	return nil
+}
+
+// Subscribe returns a subscribe packet for the response topic, the
+// packet ID must be set by the caller.
+func (r *Requester) Subscribe(opt Opt) *Subscribe {
+	s := NewSubscribe()
+	s.AddFilters(NewTopicFilter(r.ResponseTopic(), opt))
+	return s
+}
+
+// Request sets the response topic and a unique correlation data of
+// p. The returned call completes when the response is handled or the
+// timeout expires.
+func (r *Requester) Request(p *Publish) (*Call, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.responseTopic == "" {
+		return nil, fmt.Errorf("Request: %w", ErrNoResponseTopic)
+	}
+	r.seq++
+	cd := binary.BigEndian.AppendUint64(r.id[:], r.seq)
+	p.SetResponseTopic(r.responseTopic)
+	p.SetCorrelationData(cd)
+
+	c := &Call{
+		requester: r,
+		key:       string(cd),
+		done:      make(chan struct{}),
+	}
+	r.pending[c.key] = c
+	if r.timeout > 0 {
+		c.timer = time.AfterFunc(r.timeout, func() {
+			r.complete(c.key, nil, ErrTimeout)
+		})
+	}
+	return c, nil
+}
+
+// Handle completes the call of a response. Returns false if p is
+// not a response to a pending request, i.e. the packet should be
+// handled elsewhere.
+func (r *Requester) Handle(p Packet) bool {
+	pub, ok := p.(*Publish)
+	if !ok || pub.TopicName() != r.ResponseTopic() {
+		return false
+	}
+	return r.complete(string(pub.CorrelationData()), pub, nil)
+}
+
+// Pending returns the number of requests waiting for a response.
+func (r *Requester) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+// CancelAll completes all pending calls with err, e.g. when the
+// connection is lost.
+func (r *Requester) CancelAll(err error) {
+	r.mu.Lock()
+	keys := make([]string, 0, len(r.pending))
+	for k := range r.pending {
+		keys = append(keys, k)
+	}
+	r.mu.Unlock()
+	for _, k := range keys {
+		r.complete(k, nil, err)
+	}
+}
+
+func (r *Requester) complete(key string, p *Publish, err error) bool {
+	r.mu.Lock()
+	c, found := r.pending[key]
+	delete(r.pending, key)
+	r.mu.Unlock()
+	if !found {
+		return false
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.response, c.err = p, err
+	close(c.done)
+	return true
+}
+
+// ----------------------------------------
+
+// Call is one pending request.
+type Call struct {
+	requester *Requester
+	key       string // correlation data
+	timer     *time.Timer
+
+	done     chan struct{}
+	response *Publish
+	err      error
+}
+
+// Done returns a channel closed when the call completes.
+func (c *Call) Done() <-chan struct{} { return c.done }
+
+// Response waits for the call to complete and returns the response
+// or ErrTimeout.
+func (c *Call) Response() (*Publish, error) {
+	<-c.done
+	return c.response, c.err
+}
+
+// Cancel completes the call with ErrCanceled unless already
+// completed.
+func (c *Call) Cancel() {
+	c.requester.complete(c.key, nil, ErrCanceled)
+}
+
+// ----------------------------------------
+
+// NewResponder returns a responder of QoS 0 replies.
+func NewResponder() *Responder {
+	return &Responder{}
+}
+
+// Responder builds replies to requests.
+type Responder struct {
+	qos uint8
+}
+
+// SetQoS sets the QoS of replies. Packet ID of replies with QoS above
+// 0 must be set by the caller.
+func (r *Responder) SetQoS(v uint8) { r.qos = v }
+func (r *Responder) QoS() uint8     { return r.qos }
+
+// Reply returns a publish to the response topic of req with the
+// correlation data of req. Returns ErrNoResponseTopic if req is not
+// a request.
+func (r *Responder) Reply(req *Publish, payload []byte) (*Publish, error) {
+	if req.ResponseTopic() == "" {
+		return nil, fmt.Errorf("Reply: %w", ErrNoResponseTopic)
+	}
+	p := NewPublish()
+	p.SetQoS(r.qos)
+	p.SetTopicName(req.ResponseTopic())
+	p.SetCorrelationData(req.CorrelationData())
+	p.SetPayload(payload)
+	return p, nil
+}
+
+var (
+	ErrNoResponseInformation = fmt.Errorf("no response information")
+	ErrNoResponseTopic       = fmt.Errorf("no response topic")
+	ErrTimeout               = fmt.Errorf("timeout")
+	ErrCanceled              = fmt.Errorf("canceled")
+)
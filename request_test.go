@@ -0,0 +1,185 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleRequester() {
+	// client side
+	c := NewConnect()
+	c.SetRequestResponseInfo(true)
+
+	// server includes response information in the ack
+	ack := NewConnAck()
+	ack.SetResponseInformation("responses/pink")
+
+	requester := NewRequester()
+	requester.Connected(ack)
+
+	req := Pub(0, "service/time", "now?")
+	call, _ := requester.Request(req)
+
+	// service side
+	reply, _ := NewResponder().Reply(req, []byte("12:00"))
+
+	// client side
+	requester.Handle(reply)
+	resp, _ := call.Response()
+	fmt.Println(string(resp.Payload()))
+	// output:
+	// 12:00
+}
+
+func TestRequester(t *testing.T) {
+	r := NewRequester()
+	eq(t, r.SetResponseTopic, r.ResponseTopic, "a/b")
+	eq(t, r.SetTimeout, r.Timeout, time.Second)
+
+	r.SetTimeout(0)
+	p1, p2 := Pub(0, "x", "1"), Pub(0, "x", "2")
+	c1, _ := r.Request(p1)
+	c2, _ := r.Request(p2)
+	if string(p1.CorrelationData()) == string(p2.CorrelationData()) {
+		t.Fatal("same correlation data")
+	}
+	if p1.ResponseTopic() != "a/b" {
+		t.Error("response topic", p1.ResponseTopic())
+	}
+	if r.Pending() != 2 {
+		t.Error("pending", r.Pending())
+	}
+
+	// responses in reverse order and over the wire
+	rsp := NewResponder()
+	reply2, _ := rsp.Reply(p2, []byte("two"))
+	reply1, _ := rsp.Reply(p1, []byte("one"))
+	if !r.Handle(roundtrip(t, reply2)) || !r.Handle(roundtrip(t, reply1)) {
+		t.Fatal("unhandled response")
+	}
+	for call, exp := range map[*Call]string{c1: "one", c2: "two"} {
+		got, err := call.Response()
+		if err != nil || string(got.Payload()) != exp {
+			t.Errorf("got %v, %v expected %s", got, err, exp)
+		}
+	}
+	if r.Pending() != 0 {
+		t.Error("pending", r.Pending())
+	}
+	// duplicate response
+	if r.Handle(reply1) {
+		t.Error("handled duplicate")
+	}
+}
+
+func TestRequester_Handle(t *testing.T) {
+	r := NewRequester()
+	r.SetResponseTopic("a/b")
+	req := Pub(0, "x", "")
+	r.Request(req)
+
+	other := Pub(0, "c/d", "")
+	other.SetCorrelationData(req.CorrelationData())
+	unknown := Pub(0, "a/b", "")
+	unknown.SetCorrelationData([]byte("unknown"))
+
+	for _, p := range []Packet{NewPingResp(), other, unknown, Pub(0, "a/b", "")} {
+		if r.Handle(p) {
+			t.Error("handled", p)
+		}
+	}
+}
+
+func TestRequester_timeout(t *testing.T) {
+	r := NewRequester()
+	r.SetResponseTopic("a/b")
+	r.SetTimeout(time.Millisecond)
+	req := Pub(0, "x", "")
+	call, _ := r.Request(req)
+	select {
+	case <-call.Done():
+	case <-time.After(time.Second):
+		t.Fatal("no timeout")
+	}
+	if _, err := call.Response(); !errors.Is(err, ErrTimeout) {
+		t.Error("expected ErrTimeout, got", err)
+	}
+	reply, _ := NewResponder().Reply(req, nil)
+	if r.Handle(reply) {
+		t.Error("handled late response")
+	}
+}
+
+func TestRequester_Cancel(t *testing.T) {
+	r := NewRequester()
+	r.SetResponseTopic("a/b")
+	c1, _ := r.Request(Pub(0, "x", ""))
+	c2, _ := r.Request(Pub(0, "x", ""))
+	c3, _ := r.Request(Pub(0, "x", ""))
+
+	c1.Cancel()
+	if _, err := c1.Response(); !errors.Is(err, ErrCanceled) {
+		t.Error("expected ErrCanceled, got", err)
+	}
+	c1.Cancel() // no effect
+
+	lost := fmt.Errorf("connection lost")
+	r.CancelAll(lost)
+	for _, c := range []*Call{c2, c3} {
+		if _, err := c.Response(); !errors.Is(err, lost) {
+			t.Error("expected lost, got", err)
+		}
+	}
+}
+
+func TestRequester_Connected(t *testing.T) {
+	r := NewRequester()
+	if _, err := r.Request(Pub(0, "x", "")); !errors.Is(err, ErrNoResponseTopic) {
+		t.Error("expected ErrNoResponseTopic, got", err)
+	}
+	if err := r.Connected(NewConnAck()); !errors.Is(err, ErrNoResponseInformation) {
+		t.Error("expected ErrNoResponseInformation, got", err)
+	}
+
+	ack := NewConnAck()
+	ack.SetResponseInformation("responses/")
+	if err := r.Connected(ack); err != nil {
+		t.Fatal(err)
+	}
+	topic := r.ResponseTopic()
+	if !strings.HasPrefix(topic, "responses/") || strings.Contains(topic, "//") {
+		t.Error("response topic", topic)
+	}
+	other := NewRequester()
+	other.Connected(ack)
+	if other.ResponseTopic() == topic {
+		t.Error("same response topic for two requesters")
+	}
+
+	s := r.Subscribe(OptQoS1)
+	if f := s.Filters(); len(f) != 1 || f[0].Filter() != topic || f[0].Options() != OptQoS1 {
+		t.Error(s)
+	}
+}
+
+func TestResponder(t *testing.T) {
+	r := NewResponder()
+	eq(t, r.SetQoS, r.QoS, 1)
+
+	if _, err := r.Reply(Pub(0, "x", ""), nil); !errors.Is(err, ErrNoResponseTopic) {
+		t.Error("expected ErrNoResponseTopic, got", err)
+	}
+	req := Pub(0, "x", "")
+	req.SetResponseTopic("a/b")
+	req.SetCorrelationData([]byte("1"))
+	p, err := r.Reply(req, []byte("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TopicName() != "a/b" || string(p.CorrelationData()) != "1" || p.QoS() != 1 {
+		t.Error(p)
+	}
+}
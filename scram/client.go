@@ -0,0 +1,111 @@
+package scram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewClient returns the client side of one SCRAM-SHA-256 exchange.
+func NewClient(username, password string) *Client {
+	return &Client{
+		username:      username,
+		password:      password,
+		nonce:         newNonce,
+		minIterations: MinIterations,
+	}
+}
+
+// Client implements mq.Authenticator.
+type Client struct {
+	username string
+	password string
+
+	// nonce returns a new client nonce, replaced in tests
+	nonce         func() string
+	minIterations int
+
+	step            int
+	clientFirstBare string
+	serverSignature []byte
+}
+
+// AuthMethod returns SCRAM-SHA-256.
+func (c *Client) AuthMethod() string { return Method }
+
+// Step returns the client-first message when data is nil, the
+// client-final message given the server-first message and verifies
+// the server-final message.
+func (c *Client) Step(data []byte) ([]byte, bool, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		return c.clientFirst(), false, nil
+	case 2:
+		next, err := c.clientFinal(string(data))
+		return next, false, err
+	case 3:
+		return nil, true, c.verify(string(data))
+	}
+	return nil, false, ErrStep
+}
+
+func (c *Client) clientFirst() []byte {
+	c.clientFirstBare = "n=" + escape(c.username) + ",r=" + c.nonce()
+	return []byte(gs2Header + c.clientFirstBare)
+}
+
+func (c *Client) clientFinal(serverFirst string) ([]byte, error) {
+	attr, err := parse(serverFirst)
+	if err != nil {
+		return nil, err
+	}
+	clientNonce := c.clientFirstBare[strings.Index(c.clientFirstBare, ",r=")+3:]
+	nonce := attr['r']
+	if !strings.HasPrefix(nonce, clientNonce) || nonce == clientNonce {
+		return nil, ErrNonce
+	}
+	salt, err := base64.StdEncoding.DecodeString(attr['s'])
+	if err != nil {
+		return nil, fmt.Errorf("%w: salt", ErrMessage)
+	}
+	iterations, err := strconv.Atoi(attr['i'])
+	if err != nil {
+		return nil, fmt.Errorf("%w: iterations", ErrMessage)
+	}
+	if iterations < c.minIterations {
+		return nil, fmt.Errorf("%w: %v", ErrIterations, iterations)
+	}
+
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(gs2Header)) +
+		",r=" + nonce
+	authMessage := c.clientFirstBare + "," + serverFirst + "," + withoutProof
+
+	salted := saltPassword([]byte(c.password), salt, iterations)
+	clientKey := hmacSum(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := xor(clientKey, hmacSum(storedKey[:], authMessage))
+	c.serverSignature = hmacSum(hmacSum(salted, "Server Key"), authMessage)
+
+	return []byte(
+		withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof),
+	), nil
+}
+
+func (c *Client) verify(serverFinal string) error {
+	attr, err := parse(serverFinal)
+	if err != nil {
+		return err
+	}
+	if e, found := attr['e']; found {
+		return fmt.Errorf("%w: %s", ErrSignature, e)
+	}
+	v, err := base64.StdEncoding.DecodeString(attr['v'])
+	if err != nil || !hmac.Equal(v, c.serverSignature) {
+		return ErrSignature
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package scram
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test vectors from RFC 7677
+const (
+	rfcClientNonce = "rOprNGfwEbeRWgbNEkqO"
+	rfcServerNonce = "%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0"
+	rfcSalt        = "W22ZaJ0SNY7soEsUEjb6gQ=="
+
+	rfcClientFirst = "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"
+	rfcServerFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+		"s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	rfcClientFinal = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+		"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	rfcServerFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
+func TestClient(t *testing.T) {
+	c := newRFCClient()
+	if v := c.AuthMethod(); v != "SCRAM-SHA-256" {
+		t.Error(v)
+	}
+	steps := []struct {
+		in, exp string
+		done    bool
+	}{
+		{"", rfcClientFirst, false},
+		{rfcServerFirst, rfcClientFinal, false},
+		{rfcServerFinal, "", true},
+	}
+	for i, s := range steps {
+		var in []byte
+		if s.in != "" {
+			in = []byte(s.in)
+		}
+		got, done, err := c.Step(in)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if string(got) != s.exp {
+			t.Errorf("step %v\ngot %q\nexp %q", i, got, s.exp)
+		}
+		if done != s.done {
+			t.Errorf("step %v done %v", i, done)
+		}
+	}
+	if _, _, err := c.Step(nil); !errors.Is(err, ErrStep) {
+		t.Error("expected ErrStep, got", err)
+	}
+}
+
+func TestClient_badServerFirst(t *testing.T) {
+	cases := map[string]error{
+		"r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096":         ErrNonce,
+		"r=" + rfcClientNonce + ",s=" + rfcSalt + ",i=4096": ErrNonce,
+		"r=" + rfcClientNonce + "x,s=%%,i=4096":             ErrMessage,
+		"r=" + rfcClientNonce + "x,s=" + rfcSalt + ",i=x":   ErrMessage,
+		"r=" + rfcClientNonce + "x,s=" + rfcSalt + ",i=1":   ErrIterations,
+		"garbage": ErrMessage,
+	}
+	for msg, exp := range cases {
+		c := newRFCClient()
+		c.Step(nil)
+		if _, _, err := c.Step([]byte(msg)); !errors.Is(err, exp) {
+			t.Errorf("%q: got %v, expected %v", msg, err, exp)
+		}
+	}
+}
+
+func TestClient_badServerFinal(t *testing.T) {
+	cases := []string{
+		"v=7rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		"e=other-error",
+		"v=%%",
+		"garbage",
+	}
+	for _, msg := range cases {
+		c := newRFCClient()
+		c.Step(nil)
+		c.Step([]byte(rfcServerFirst))
+		if _, _, err := c.Step([]byte(msg)); err == nil {
+			t.Errorf("%q: expected error", msg)
+		}
+	}
+}
+
+func newRFCClient() *Client {
+	c := NewClient("user", "pencil")
+	c.nonce = func() string { return rfcClientNonce }
+	return c
+}
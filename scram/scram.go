@@ -0,0 +1,171 @@
+/*
+Package scram implements the SCRAM-SHA-256 authentication method,
+RFC 7677, for use with mq.ClientAuth and mq.ServerAuth.
+
+The client-first, server-first, client-final and server-final
+messages are carried in Connect.AuthData, Auth.AuthData and
+ConnAck.AuthData. The password is never sent, the server only needs
+to store a salted hash of it, see NewCredentials.
+
+Channel binding is not supported and usernames are not normalized
+using SASLprep.
+*/
+package scram
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Method is the authentication method name used in Connect,
+// ConnAck and Auth packets.
+const Method = "SCRAM-SHA-256"
+
+// MinIterations is the minimum iteration count recommended by RFC
+// 7677.
+const MinIterations = 4096
+
+// gs2 header without channel binding and authzid
+const gs2Header = "n,,"
+
+// NewCredentials returns credentials for the given password. Only
+// the returned value needs to be stored by the server.
+func NewCredentials(password string, salt []byte, iterations int) *Credentials {
+	salted := saltPassword([]byte(password), salt, iterations)
+	clientKey := hmacSum(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	return &Credentials{
+		Salt:       salt,
+		Iterations: iterations,
+		StoredKey:  storedKey[:],
+		ServerKey:  hmacSum(salted, "Server Key"),
+	}
+}
+
+// Credentials are the salted hashes stored on the server for one
+// user.
+type Credentials struct {
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// CredentialStore provides credentials by username.
+type CredentialStore interface {
+	// Credentials returns ErrUnknownUser if the user is not found.
+	Credentials(username string) (*Credentials, error)
+}
+
+// NewMemStore returns an empty in memory credential store.
+func NewMemStore() *MemStore {
+	return &MemStore{users: make(map[string]*Credentials)}
+}
+
+// MemStore is an in memory credential store safe for concurrent use.
+type MemStore struct {
+	mu    sync.RWMutex
+	users map[string]*Credentials
+}
+
+// Add credentials for the given username, replacing existing ones.
+func (s *MemStore) Add(username string, c *Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[username] = c
+}
+
+// Credentials returns credentials for the given username.
+func (s *MemStore) Credentials(username string) (*Credentials, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, found := s.users[username]
+	if !found {
+		return nil, fmt.Errorf("%w %q", ErrUnknownUser, username)
+	}
+	return c, nil
+}
+
+// ----------------------------------------
+
+// saltPassword implements Hi from RFC 5802, which is PBKDF2 with
+// HMAC-SHA-256 and a single block output.
+func saltPassword(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	binary.Write(mac, binary.BigEndian, uint32(1))
+	u := mac.Sum(nil)
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func hmacSum(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+func xor(a, b []byte) []byte {
+	res := make([]byte, len(a))
+	for i := range a {
+		res[i] = a[i] ^ b[i]
+	}
+	return res
+}
+
+// newNonce returns a random printable nonce.
+func newNonce() string {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// parse splits a message into attribute values, e.g. r=abc,s=xyz.
+func parse(msg string) (map[byte]string, error) {
+	attr := make(map[byte]string)
+	for _, part := range strings.Split(msg, ",") {
+		if len(part) < 2 || part[1] != '=' {
+			return nil, fmt.Errorf("%w: %q", ErrMessage, msg)
+		}
+		attr[part[0]] = part[2:]
+	}
+	return attr, nil
+}
+
+// escape encodes a username as saslname
+func escape(v string) string {
+	v = strings.ReplaceAll(v, "=", "=3D")
+	return strings.ReplaceAll(v, ",", "=2C")
+}
+
+func unescape(v string) string {
+	v = strings.ReplaceAll(v, "=2C", ",")
+	return strings.ReplaceAll(v, "=3D", "=")
+}
+
+var (
+	ErrUnknownUser = fmt.Errorf("unknown user")
+	ErrMessage     = fmt.Errorf("malformed message")
+	ErrNonce       = fmt.Errorf("nonce mismatch")
+	ErrProof       = fmt.Errorf("invalid proof")
+	ErrSignature   = fmt.Errorf("invalid server signature")
+	ErrIterations  = fmt.Errorf("too few iterations")
+	ErrStep        = fmt.Errorf("unexpected step")
+)
@@ -0,0 +1,83 @@
+package scram
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+func TestScram(t *testing.T) {
+	store := NewMemStore()
+	store.Add("john,doe=", NewCredentials("secret", []byte("salt"), MinIterations))
+
+	for _, password := range []string{"secret", "wrong"} {
+		client := mq.NewClientAuth(func() mq.Authenticator {
+			return NewClient("john,doe=", password)
+		})
+		server := mq.NewServerAuth(func() mq.Authenticator {
+			return NewServer(store)
+		})
+
+		p := mq.NewConnect()
+		if err := client.Start(p); err != nil {
+			t.Fatal(err)
+		}
+		if v := p.AuthMethod(); v != Method {
+			t.Error("AuthMethod", v)
+		}
+		var (
+			next mq.Packet = p
+			err  error
+		)
+		for i := 0; next != nil && err == nil; i++ {
+			if i%2 == 0 {
+				next, err = server.Handle(roundtrip(t, next))
+			} else {
+				next, err = client.Handle(roundtrip(t, next))
+			}
+		}
+		switch password {
+		case "secret":
+			if err != nil || !server.Connected() {
+				t.Error("expected success, got", err)
+			}
+		default:
+			if !errors.Is(err, ErrProof) {
+				t.Error("expected ErrProof, got", err)
+			}
+		}
+	}
+}
+
+func TestMemStore(t *testing.T) {
+	s := NewMemStore()
+	if _, err := s.Credentials("x"); !errors.Is(err, ErrUnknownUser) {
+		t.Error("expected ErrUnknownUser, got", err)
+	}
+	c := NewCredentials("pencil", []byte("salt"), 1)
+	s.Add("x", c)
+	if got, _ := s.Credentials("x"); got != c {
+		t.Error("got", got)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, v := range []string{"plain", "a,b", "a=b", "=2C,=3D"} {
+		if got := unescape(escape(v)); got != v {
+			t.Errorf("%q became %q", v, got)
+		}
+	}
+}
+
+func roundtrip(t *testing.T, p mq.Packet) mq.Packet {
+	t.Helper()
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	got, err := mq.ReadPacket(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
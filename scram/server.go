@@ -0,0 +1,153 @@
+package scram
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewServer returns the server side of one SCRAM-SHA-256 exchange
+// using the given store to lookup credentials.
+func NewServer(store CredentialStore) *Server {
+	return &Server{
+		store: store,
+		nonce: newNonce,
+	}
+}
+
+// Server implements mq.Authenticator.
+type Server struct {
+	store CredentialStore
+
+	// nonce returns a new server nonce, replaced in tests
+	nonce func() string
+
+	step            int
+	authenticated   bool
+	username        string
+	credentials     *Credentials
+	clientFirstBare string
+	serverFirst     string
+	combinedNonce   string
+}
+
+// AuthMethod returns SCRAM-SHA-256.
+func (s *Server) AuthMethod() string { return Method }
+
+// Username returns the authenticated username once the exchange is
+// done.
+func (s *Server) Username() string {
+	if !s.authenticated {
+		return ""
+	}
+	return s.username
+}
+
+// Step returns the server-first message given the client-first
+// message and the server-final message given the client-final
+// message.
+func (s *Server) Step(data []byte) ([]byte, bool, error) {
+	s.step++
+	switch s.step {
+	case 1:
+		next, err := s.serverFirstMessage(string(data))
+		return next, false, err
+	case 2:
+		next, err := s.serverFinal(string(data))
+		return next, err == nil, err
+	}
+	return nil, false, ErrStep
+}
+
+func (s *Server) serverFirstMessage(clientFirst string) ([]byte, error) {
+	if !strings.HasPrefix(clientFirst, gs2Header) {
+		return nil, fmt.Errorf("%w: unsupported gs2 header", ErrMessage)
+	}
+	s.clientFirstBare = clientFirst[len(gs2Header):]
+	attr, err := parse(s.clientFirstBare)
+	if err != nil {
+		return nil, err
+	}
+	username, clientNonce := unescape(attr['n']), attr['r']
+	if username == "" || clientNonce == "" {
+		return nil, fmt.Errorf("%w: %q", ErrMessage, clientFirst)
+	}
+	s.credentials, err = s.store.Credentials(username)
+	if errors.Is(err, ErrUnknownUser) {
+		// continue so the client cannot tell unknown users from
+		// wrong passwords, the proof fails with ErrProof
+		s.credentials, err = fakeCredentials(username), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.username = username
+	s.combinedNonce = clientNonce + s.nonce()
+	s.serverFirst = "r=" + s.combinedNonce +
+		",s=" + base64.StdEncoding.EncodeToString(s.credentials.Salt) +
+		",i=" + strconv.Itoa(s.credentials.Iterations)
+	return []byte(s.serverFirst), nil
+}
+
+// fakeCredentials returns credentials of an unknown user which no
+// proof matches. The salt is derived from the username, so it is the
+// same in each exchange like the salt of a known user.
+func fakeCredentials(username string) *Credentials {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &Credentials{
+		Salt:       hmacSum(fakeSaltKey, username)[:16],
+		Iterations: MinIterations,
+		StoredKey:  key,
+		ServerKey:  key,
+	}
+}
+
+// fakeSaltKey is the key of salts of unknown users.
+var fakeSaltKey = func() []byte {
+	b := make([]byte, sha256.Size)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+func (s *Server) serverFinal(clientFinal string) ([]byte, error) {
+	i := strings.LastIndex(clientFinal, ",p=")
+	if i == -1 {
+		return nil, fmt.Errorf("%w: missing proof", ErrMessage)
+	}
+	withoutProof := clientFinal[:i]
+	attr, err := parse(clientFinal)
+	if err != nil {
+		return nil, err
+	}
+	if attr['c'] != base64.StdEncoding.EncodeToString([]byte(gs2Header)) {
+		return nil, fmt.Errorf("%w: channel binding", ErrMessage)
+	}
+	if attr['r'] != s.combinedNonce {
+		return nil, ErrNonce
+	}
+	proof, err := base64.StdEncoding.DecodeString(attr['p'])
+	if err != nil || len(proof) != sha256.Size {
+		return nil, ErrProof
+	}
+
+	authMessage := s.clientFirstBare + "," + s.serverFirst + "," + withoutProof
+	c := s.credentials
+	clientKey := xor(proof, hmacSum(c.StoredKey, authMessage))
+	storedKey := sha256.Sum256(clientKey)
+	if !hmac.Equal(storedKey[:], c.StoredKey) {
+		return nil, ErrProof
+	}
+	s.authenticated = true
+	signature := hmacSum(c.ServerKey, authMessage)
+	return []byte("v=" + base64.StdEncoding.EncodeToString(signature)), nil
+}
@@ -0,0 +1,110 @@
+package scram
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := newRFCServer()
+	if v := s.AuthMethod(); v != "SCRAM-SHA-256" {
+		t.Error(v)
+	}
+	got, done, err := s.Step([]byte(rfcClientFirst))
+	if err != nil || done || string(got) != rfcServerFirst {
+		t.Fatalf("server-first %q %v %v", got, done, err)
+	}
+	if v := s.Username(); v != "" {
+		t.Error("username before done", v)
+	}
+	got, done, err = s.Step([]byte(rfcClientFinal))
+	if err != nil || !done || string(got) != rfcServerFinal {
+		t.Fatalf("server-final %q %v %v", got, done, err)
+	}
+	if v := s.Username(); v != "user" {
+		t.Error("username", v)
+	}
+	if _, _, err := s.Step(nil); !errors.Is(err, ErrStep) {
+		t.Error("expected ErrStep, got", err)
+	}
+}
+
+func TestServer_badClientFirst(t *testing.T) {
+	cases := map[string]error{
+		"y,,n=user,r=abc": ErrMessage,
+		"n,,garbage":      ErrMessage,
+		"n,,n=,r=abc":     ErrMessage,
+	}
+	for msg, exp := range cases {
+		s := newRFCServer()
+		if _, _, err := s.Step([]byte(msg)); !errors.Is(err, exp) {
+			t.Errorf("%q: got %v, expected %v", msg, err, exp)
+		}
+	}
+}
+
+func TestServer_unknownUser(t *testing.T) {
+	first := func() string {
+		s := newRFCServer()
+		got, done, err := s.Step([]byte("n,,n=other,r=abc"))
+		if err != nil || done {
+			t.Fatalf("server-first %q %v %v", got, done, err)
+		}
+		return string(got)
+	}
+	serverFirst := first()
+	salt := serverFirst[strings.Index(serverFirst, ",s="):]
+	if !strings.HasSuffix(salt, ",i=4096") {
+		t.Error(serverFirst)
+	}
+	if v := first(); !strings.HasSuffix(v, salt) {
+		t.Errorf("salt changed\n%s\n%s", v, serverFirst)
+	}
+
+	// same error as a wrong password
+	s := newRFCServer()
+	s.Step([]byte("n,,n=other,r=abc"))
+	proof := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	msg := "c=biws,r=abc" + rfcServerNonce + ",p=" + proof
+	if _, _, err := s.Step([]byte(msg)); !errors.Is(err, ErrProof) {
+		t.Error("expected ErrProof, got", err)
+	}
+	if v := s.Username(); v != "" {
+		t.Error("username after failure", v)
+	}
+}
+
+func TestServer_badClientFinal(t *testing.T) {
+	i := strings.Index(rfcClientFinal, ",p=")
+	proof := rfcClientFinal[i+3:]
+	wrongProof := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	cases := map[string]error{
+		"c=biws,r=x":                ErrMessage,
+		"c=biws,garbage,p=" + proof: ErrMessage,
+		"c=eSws,r=" + rfcClientNonce + rfcServerNonce + ",p=" + proof:      ErrMessage,
+		"c=biws,r=" + rfcClientNonce + ",p=" + proof:                       ErrNonce,
+		"c=biws,r=" + rfcClientNonce + rfcServerNonce + ",p=%%":            ErrProof,
+		"c=biws,r=" + rfcClientNonce + rfcServerNonce + ",p=" + wrongProof: ErrProof,
+	}
+	for msg, exp := range cases {
+		s := newRFCServer()
+		s.Step([]byte(rfcClientFirst))
+		if _, _, err := s.Step([]byte(msg)); !errors.Is(err, exp) {
+			t.Errorf("%q: got %v, expected %v", msg, err, exp)
+		}
+		if v := s.Username(); v != "" {
+			t.Error("username after failure", v)
+		}
+	}
+}
+
+func newRFCServer() *Server {
+	salt, _ := base64.StdEncoding.DecodeString(rfcSalt)
+	store := NewMemStore()
+	store.Add("user", NewCredentials("pencil", salt, 4096))
+	s := NewServer(store)
+	s.nonce = func() string { return rfcServerNonce }
+	return s
+}
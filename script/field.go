@@ -0,0 +1,183 @@
+package script
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gregoryv/mq"
+)
+
+// newField returns the field of packet p named by key.
+func newField(p mq.Packet, key string) (*field, error) {
+	key = strings.ToLower(key)
+	if v, found := aliases[key]; found {
+		key = v
+	}
+	v := reflect.ValueOf(p)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		for _, prefix := range []string{"Set", "Add"} {
+			name, found := strings.CutPrefix(m.Name, prefix)
+			if !found {
+				continue
+			}
+			// e.g. filter for AddFilters
+			lower := strings.ToLower(name)
+			if lower == key || lower == key+"s" {
+				return &field{
+					name:   name,
+					setter: v.Method(i),
+				}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w %q in %T", ErrUnknownField, key, p)
+}
+
+var aliases = map[string]string{
+	"reason": "reasoncode",
+	"topic":  "topicname",
+}
+
+type field struct {
+	name   string // e.g. ClientID
+	setter reflect.Value
+}
+
+// set parses value and calls the setter.
+func (f *field) set(value string) error {
+	t := f.setter.Type()
+	in := t.In(0)
+	if t.IsVariadic() {
+		in = in.Elem()
+	}
+
+	var args []reflect.Value
+	switch {
+	case in == reflect.TypeOf(mq.TopicFilter{}):
+		filter, opts := value, uint64(0)
+		if i := strings.LastIndex(value, ":"); i > -1 {
+			v, err := strconv.ParseUint(value[i+1:], 0, 8)
+			if err != nil {
+				return err
+			}
+			filter, opts = value[:i], v
+		}
+		args = append(args, reflect.ValueOf(mq.NewTopicFilter(filter, mq.Opt(opts))))
+
+	case in == reflect.TypeOf(mq.ReasonCode(0)):
+		code, err := parseReasonCode(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, reflect.ValueOf(code))
+
+	case in.Kind() == reflect.String && t.IsVariadic():
+		// key value pairs, e.g. AddUserProp
+		k, v, found := strings.Cut(value, ":")
+		if !found {
+			return fmt.Errorf("%w: expected KEY:VALUE", ErrValue)
+		}
+		args = append(args, reflect.ValueOf(k), reflect.ValueOf(v))
+
+	case in.Kind() == reflect.String:
+		args = append(args, reflect.ValueOf(value))
+
+	case in.Kind() == reflect.Slice && in.Elem().Kind() == reflect.Uint8:
+		args = append(args, reflect.ValueOf([]byte(value)))
+
+	case in.Kind() == reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, reflect.ValueOf(v))
+
+	case in.Kind() >= reflect.Uint8 && in.Kind() <= reflect.Uint64:
+		v, err := strconv.ParseUint(value, 0, in.Bits())
+		if err != nil {
+			return err
+		}
+		args = append(args, reflect.ValueOf(v).Convert(in))
+
+	case in.Kind() >= reflect.Int && in.Kind() <= reflect.Int64:
+		v, err := strconv.ParseInt(value, 0, in.Bits())
+		if err != nil {
+			return err
+		}
+		args = append(args, reflect.ValueOf(v).Convert(in))
+
+	default:
+		return fmt.Errorf("%w %s", ErrUnsupported, in)
+	}
+	f.setter.Call(args)
+	return nil
+}
+
+// comparable returns an error if the field cannot be read from p.
+func (f *field) comparable(p mq.Packet) error {
+	if !f.getter(reflect.ValueOf(p)).IsValid() {
+		return fmt.Errorf("%w %s", ErrCompare, f.name)
+	}
+	return nil
+}
+
+// getter returns the method or field used to read the value.
+func (f *field) getter(p reflect.Value) reflect.Value {
+	for _, name := range []string{f.name, f.name + "s"} {
+		if m := p.MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 {
+			return m
+		}
+	}
+	if f.name == "UserProp" {
+		return p.Elem().FieldByName("UserProperties")
+	}
+	return reflect.Value{}
+}
+
+// value returns the field value of p as a string.
+func (f *field) value(p mq.Packet) string {
+	v := f.getter(reflect.ValueOf(p))
+	if v.Kind() == reflect.Func {
+		v = v.Call(nil)[0]
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// parseReasonCode returns the reason code given by name or number.
+func parseReasonCode(v string) (mq.ReasonCode, error) {
+	if code, found := reasonCodes[v]; found {
+		return code, nil
+	}
+	n, err := strconv.ParseUint(v, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrReasonCode, v)
+	}
+	return mq.ReasonCode(n), nil
+}
+
+var reasonCodes = func() map[string]mq.ReasonCode {
+	codes := map[string]mq.ReasonCode{
+		// same values as Success which is the name given by String
+		"NormalDisconnect": mq.NormalDisconnect,
+		"GrantedQoS0":      mq.GrantedQoS0,
+	}
+	for i := 0; i < 256; i++ {
+		code := mq.ReasonCode(i)
+		if name := code.String(); !strings.HasPrefix(name, "ReasonCode(") {
+			codes[name] = code
+		}
+	}
+	return codes
+}()
+
+var (
+	ErrUnknownField = fmt.Errorf("unknown field")
+	ErrUnsupported  = fmt.Errorf("unsupported field type")
+	ErrValue        = fmt.Errorf("bad value")
+	ErrReasonCode   = fmt.Errorf("unknown reason code")
+	ErrCompare      = fmt.Errorf("cannot compare field")
+)
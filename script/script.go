@@ -0,0 +1,250 @@
+/*
+Package script describes packet sequences in plain text for protocol
+tests.
+
+A script has one packet per line. Lines starting with > are packets
+to send and lines starting with < are packets expected to be
+received, e.g.
+
+	# connect and subscribe
+	> CONNECT clientid=pink keepalive=30
+	< CONNACK reason=Success
+	> SUBSCRIBE packetid=1 filter=a/#:1
+	< SUBACK packetid=1 reasoncode=GrantedQoS1
+	> PUBLISH topic=a/b payload="hello world" userprop=color:red
+	> DISCONNECT
+
+The packet type is followed by fields as key=value pairs. Keys are
+the packet setter names without Set or Add, in any case, e.g.
+clientid for Connect.SetClientID. The aliases reason and topic may be
+used for reasoncode and topicname. Values containing spaces are
+double quoted. Keys of Add methods may be repeated, e.g. filter for
+Subscribe.AddFilters where the value is FILTER[:OPTIONS], and
+userprop whose value is KEY:VALUE. Reason codes are given by name or
+number.
+
+Expected packets are compared by type and the given fields only.
+*/
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gregoryv/mq"
+)
+
+// Load reads a script from the given file.
+func Load(filename string) (*Script, error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("script.Load: %w", err)
+	}
+	defer fh.Close()
+	s, err := Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("script.Load %s: %w", filename, err)
+	}
+	return s, nil
+}
+
+// Parse reads a script from the given reader.
+func Parse(r io.Reader) (*Script, error) {
+	var s Script
+	scanner := bufio.NewScanner(r)
+	for no := 1; scanner.Scan(); no++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		step, err := parseStep(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", no, err)
+		}
+		step.Line = no
+		s.Steps = append(s.Steps, step)
+	}
+	return &s, scanner.Err()
+}
+
+func parseStep(line string) (Step, error) {
+	var step Step
+	switch line[0] {
+	case '>':
+		step.Send = true
+	case '<':
+	default:
+		return step, fmt.Errorf("%w %q", ErrDirection, line[:1])
+	}
+	words, err := split(line[1:])
+	if err != nil {
+		return step, err
+	}
+	if len(words) == 0 {
+		return step, ErrMissingType
+	}
+	newPacket, found := packetTypes[words[0]]
+	if !found {
+		return step, fmt.Errorf("%w %q", mq.ErrPacketType, words[0])
+	}
+	step.Packet = newPacket()
+	for _, word := range words[1:] {
+		key, value, found := strings.Cut(word, "=")
+		if !found {
+			return step, fmt.Errorf("%w %q", ErrField, word)
+		}
+		f, err := newField(step.Packet, key)
+		if err != nil {
+			return step, err
+		}
+		if err := f.set(value); err != nil {
+			return step, fmt.Errorf("%s: %w", key, err)
+		}
+		if !step.Send {
+			if err := f.comparable(step.Packet); err != nil {
+				return step, err
+			}
+			step.fields = appendOnce(step.fields, f)
+		}
+	}
+	return step, nil
+}
+
+// split splits line into words separated by spaces, values may be
+// double quoted.
+func split(line string) ([]string, error) {
+	var words []string
+	line = strings.TrimSpace(line)
+	for line != "" {
+		end := strings.IndexAny(line, " \t\"")
+		if end == -1 {
+			words = append(words, line)
+			break
+		}
+		if line[end] != '"' {
+			words = append(words, line[:end])
+			line = strings.TrimSpace(line[end:])
+			continue
+		}
+		// quoted value, e.g. key="a b"
+		quoted, err := strconv.QuotedPrefix(line[end:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrQuote, line[end:])
+		}
+		value, _ := strconv.Unquote(quoted)
+		words = append(words, line[:end]+value)
+		line = line[end+len(quoted):]
+		if line != "" && line[0] != ' ' && line[0] != '\t' {
+			return nil, fmt.Errorf("%w: %s", ErrQuote, line)
+		}
+		line = strings.TrimSpace(line)
+	}
+	return words, nil
+}
+
+func appendOnce(fields []*field, f *field) []*field {
+	for _, v := range fields {
+		if v.name == f.name {
+			return fields
+		}
+	}
+	return append(fields, f)
+}
+
+var packetTypes = map[string]func() mq.Packet{
+	"AUTH":        func() mq.Packet { return mq.NewAuth() },
+	"CONNACK":     func() mq.Packet { return mq.NewConnAck() },
+	"CONNECT":     func() mq.Packet { return mq.NewConnect() },
+	"DISCONNECT":  func() mq.Packet { return mq.NewDisconnect() },
+	"PINGREQ":     func() mq.Packet { return mq.NewPingReq() },
+	"PINGRESP":    func() mq.Packet { return mq.NewPingResp() },
+	"PUBACK":      func() mq.Packet { return mq.NewPubAck() },
+	"PUBCOMP":     func() mq.Packet { return mq.NewPubComp() },
+	"PUBLISH":     func() mq.Packet { return mq.NewPublish() },
+	"PUBREC":      func() mq.Packet { return mq.NewPubRec() },
+	"PUBREL":      func() mq.Packet { return mq.NewPubRel() },
+	"SUBACK":      func() mq.Packet { return mq.NewSubAck() },
+	"SUBSCRIBE":   func() mq.Packet { return mq.NewSubscribe() },
+	"UNSUBACK":    func() mq.Packet { return mq.NewUnsubAck() },
+	"UNSUBSCRIBE": func() mq.Packet { return mq.NewUnsubscribe() },
+}
+
+// ----------------------------------------
+
+// Script is a sequence of packets to send and expect.
+type Script struct {
+	Steps []Step
+}
+
+// Run plays the script against rw. Packets are written to and read
+// from rw in script order. Returns an error on the first unexpected
+// packet.
+func (s *Script) Run(rw io.ReadWriter) error {
+	for _, step := range s.Steps {
+		if err := step.Run(rw); err != nil {
+			return fmt.Errorf("line %v: %w", step.Line, err)
+		}
+	}
+	return nil
+}
+
+// Step is one line in a script.
+type Step struct {
+	Line   int  // in script
+	Send   bool // false if expected
+	Packet mq.Packet
+
+	fields []*field // to compare
+}
+
+// Run writes the packet to rw if it's sent or reads one packet and
+// compares it with the expected one.
+func (s *Step) Run(rw io.ReadWriter) error {
+	if s.Send {
+		_, err := s.Packet.WriteTo(rw)
+		return err
+	}
+	p, err := mq.ReadPacket(rw)
+	if err != nil {
+		return err
+	}
+	return s.Check(p)
+}
+
+// Check returns an error if p differs from the expected packet in
+// type or any of the given fields.
+func (s *Step) Check(p mq.Packet) error {
+	exp := fmt.Sprintf("%T", s.Packet)
+	if got := fmt.Sprintf("%T", p); got != exp {
+		return fmt.Errorf("%w: got %v, expected %s", ErrMismatch, p, exp)
+	}
+	for _, f := range s.fields {
+		got, exp := f.value(p), f.value(s.Packet)
+		if got != exp {
+			return fmt.Errorf("%w: %s %s, expected %s",
+				ErrMismatch, f.name, got, exp,
+			)
+		}
+	}
+	return nil
+}
+
+func (s *Step) String() string {
+	dir := "<"
+	if s.Send {
+		dir = ">"
+	}
+	return fmt.Sprintf("%s %v", dir, s.Packet)
+}
+
+var (
+	ErrDirection   = fmt.Errorf("direction must be > or <")
+	ErrMissingType = fmt.Errorf("missing packet type")
+	ErrField       = fmt.Errorf("field must be key=value")
+	ErrQuote       = fmt.Errorf("bad quote")
+	ErrMismatch    = fmt.Errorf("mismatch")
+)
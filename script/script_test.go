@@ -0,0 +1,189 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gregoryv/mq"
+)
+
+func Example() {
+	s, _ := Parse(strings.NewReader(`
+> PUBLISH qos=1 packetid=3 topic=a/b payload="hello world"
+< PUBACK packetid=3 reason=NotAuthorized`,
+	))
+	for _, step := range s.Steps {
+		fmt.Println(step.Line, step.String())
+	}
+	// output:
+	// 2 > PUBLISH --1- p3 a/b 21 bytes
+	// 3 < PUBACK ---- p3 5 bytes NotAuthorized!
+}
+
+func TestScript_Run(t *testing.T) {
+	s, err := Load("testdata/subscribe.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suback := mq.NewSubAck()
+	suback.SetPacketID(1)
+	suback.AddReasonCode(mq.GrantedQoS1)
+
+	var out bytes.Buffer
+	rw := readWriter(&out, mq.NewConnAck(), suback)
+	if err := s.Run(rw); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"CONNECT ---- -------- MQTT5 pink 30s",
+		"SUBSCRIBE --1- p1 a/#",
+		"PUBLISH ---- p0 a/b",
+		"DISCONNECT ----",
+	}
+	for _, e := range exp {
+		p, err := mq.ReadPacket(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(p.String(), e) {
+			t.Errorf("got %q, expected %q", p, e)
+		}
+		if pub, ok := p.(*mq.Publish); ok {
+			if v := string(pub.Payload()); v != "hello world" {
+				t.Error("payload", v)
+			}
+			if v := pub.UserProperties; len(v) != 1 || v[0] != (mq.UserProp{"color", "red"}) {
+				t.Error("user properties", v)
+			}
+		}
+	}
+}
+
+func TestScript_Run_mismatch(t *testing.T) {
+	ack := mq.NewConnAck()
+	ack.SetReasonCode(mq.NotAuthorized)
+
+	cases := map[string]struct {
+		script string
+		in     []mq.Packet
+	}{
+		"type":   {"< PINGRESP", []mq.Packet{ack}},
+		"field":  {"< CONNACK reason=Success", []mq.Packet{ack}},
+		"filter": {"< UNSUBACK reasoncode=0 reasoncode=17", []mq.Packet{unsuback(0)}},
+	}
+	for name, c := range cases {
+		s, err := Parse(strings.NewReader(c.script))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		err = s.Run(readWriter(io.Discard, c.in...))
+		if !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: expected ErrMismatch, got %v", name, err)
+		}
+		if err != nil && !strings.HasPrefix(err.Error(), "line 1:") {
+			t.Errorf("%s: missing line in %v", name, err)
+		}
+	}
+
+	s, _ := Parse(strings.NewReader("< CONNACK"))
+	if err := s.Run(readWriter(io.Discard)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	cases := map[string]struct {
+		script string
+		exp    error
+	}{
+		"direction":   {"CONNECT", ErrDirection},
+		"type":        {">", ErrMissingType},
+		"unknown":     {"> CONNECTED", mq.ErrPacketType},
+		"field":       {"> CONNECT clientid", ErrField},
+		"name":        {"> CONNECT color=red", ErrUnknownField},
+		"unsupported": {"> CONNECT will=x", ErrUnsupported},
+		"reason":      {"> PUBACK reason=Fine", ErrReasonCode},
+		"userprop":    {"> PUBLISH userprop=color", ErrValue},
+		"quote":       {`> PUBLISH payload="a`, ErrQuote},
+		"after quote": {`> PUBLISH payload="a"b`, ErrQuote},
+	}
+	for name, c := range cases {
+		_, err := Parse(strings.NewReader("# comment\n" + c.script))
+		if !errors.Is(err, c.exp) {
+			t.Errorf("%s: expected %v, got %v", name, c.exp, err)
+		}
+		if err != nil && !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("%s: missing line in %v", name, err)
+		}
+	}
+
+	bad := []string{
+		"> CONNECT keepalive=-1",
+		"> CONNECT cleanstart=maybe",
+		"> SUBSCRIBE subscriptionid=x",
+		"> SUBSCRIBE filter=a:x",
+	}
+	for _, line := range bad {
+		if _, err := Parse(strings.NewReader(line)); err == nil {
+			t.Errorf("%s: expected error", line)
+		}
+	}
+
+	if _, err := Load("no-such-file"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// Every setter of every packet can be used in a script, either as
+// sent or expected field.
+func TestFields(t *testing.T) {
+	for name, newPacket := range packetTypes {
+		p := newPacket()
+		typ := reflect.TypeOf(p)
+		for i := 0; i < typ.NumMethod(); i++ {
+			m := typ.Method(i).Name
+			key, found := strings.CutPrefix(m, "Set")
+			if !found {
+				key, found = strings.CutPrefix(m, "Add")
+			}
+			if !found {
+				continue
+			}
+			f, err := newField(p, key)
+			if err != nil {
+				t.Errorf("%s %s: %v", name, m, err)
+				continue
+			}
+			if err := f.comparable(p); err != nil {
+				t.Errorf("%s %s: %v", name, m, err)
+			}
+		}
+	}
+}
+
+func unsuback(codes ...mq.ReasonCode) *mq.UnsubAck {
+	p := mq.NewUnsubAck()
+	for _, c := range codes {
+		p.AddReasonCode(c)
+	}
+	return p
+}
+
+// readWriter returns a ReadWriter reading the given packets and
+// writing to w.
+func readWriter(w io.Writer, in ...mq.Packet) io.ReadWriter {
+	var buf bytes.Buffer
+	for _, p := range in {
+		p.WriteTo(&buf)
+	}
+	return struct {
+		io.Reader
+		io.Writer
+	}{&buf, w}
+}
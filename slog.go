@@ -0,0 +1,266 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// LogValue methods return a group with the packet type, flags and the
+// fields most useful when following a packet flow, e.g. packet ID,
+// reason code, topic and payload size. Secrets such as password and
+// auth data are redacted. Use NewDumpHandler to log all fields.
+
+func (p *Connect) LogValue() slog.Value {
+	return logValue(p, p.fixed,
+		optString("clientID", p.ClientID()),
+		optString("username", stars(len(p.username))),
+		optString("password", stars(len(p.password))),
+		optString("authMethod", p.AuthMethod()),
+		optString("authData", stars(len(p.authData))),
+	)
+}
+
+func (p *ConnAck) LogValue() slog.Value {
+	return logValue(p, p.fixed,
+		slog.Bool("sessionPresent", p.SessionPresent()),
+		optString("assignedClientID", p.AssignedClientID()),
+		optString("authMethod", p.AuthMethod()),
+		optString("authData", stars(len(p.authData))),
+	)
+}
+
+func (p *Publish) LogValue() slog.Value {
+	return logValue(p, p.fixed, publishTopic(p), slog.Int("size", len(p.payload)))
+}
+
+func (p *PublishStream) LogValue() slog.Value {
+	return logValue(p, p.fixed, publishTopic(p.Publish), slog.Int64("size", p.size))
+}
+
+func publishTopic(p *Publish) slog.Attr {
+	if p.topicAlias > 0 && len(p.topicName) == 0 {
+		return slog.Int("topicAlias", int(p.topicAlias))
+	}
+	return slog.String("topic", p.TopicName())
+}
+
+func (p *PubAck) LogValue() slog.Value  { return logValue(p, p.fixed) }
+func (p *PubRec) LogValue() slog.Value  { return logValue(p, p.fixed) }
+func (p *PubRel) LogValue() slog.Value  { return logValue(p, p.fixed) }
+func (p *PubComp) LogValue() slog.Value { return logValue(p, p.fixed) }
+
+func (p *Subscribe) LogValue() slog.Value {
+	filters := make([]string, len(p.filters))
+	for i, f := range p.filters {
+		filters[i] = f.Filter()
+	}
+	return logValue(p, p.fixed, slog.Any("filters", filters))
+}
+
+func (p *SubAck) LogValue() slog.Value {
+	return logValue(p, p.fixed, reasonNames(SUBACK, p.reasonCodes))
+}
+
+func (p *Unsubscribe) LogValue() slog.Value {
+	return logValue(p, p.fixed, slog.Any("filters", p.Filters()))
+}
+
+func (p *UnsubAck) LogValue() slog.Value {
+	return logValue(p, p.fixed, reasonNames(UNSUBACK, p.reasonCodes))
+}
+
+func reasonNames(packetType byte, codes []uint8) slog.Attr {
+	names := make([]string, len(codes))
+	for i, c := range codes {
+		names[i] = ReasonCode(c).NameFor(packetType)
+	}
+	return slog.Any("reasons", names)
+}
+
+func (p *PingReq) LogValue() slog.Value    { return logValue(p, p.fixed) }
+func (p *PingResp) LogValue() slog.Value   { return logValue(p, p.fixed) }
+func (p *Disconnect) LogValue() slog.Value { return logValue(p, p.fixed) }
+
+func (p *Auth) LogValue() slog.Value {
+	return logValue(p, p.fixed,
+		optString("authMethod", p.AuthMethod()),
+		optString("authData", stars(len(p.authData))),
+	)
+}
+
+func (p *Undefined) LogValue() slog.Value { return logValue(p, p.fixed) }
+
+// logValue returns a group with type, flags, packet ID and reason
+// code, if p has them, followed by attrs. Empty attrs are skipped.
+func logValue(p Packet, fixed bits, attrs ...slog.Attr) slog.Value {
+	name, flags, _ := strings.Cut(firstByte(fixed).String(), " ")
+	group := []slog.Attr{
+		slog.String("type", name),
+		slog.String("flags", flags),
+	}
+	if v, ok := p.(HasPacketID); ok {
+		group = append(group, slog.Int("packetID", int(v.PacketID())))
+	}
+	if v, ok := p.(HasReason); ok {
+		packetType := byte(fixed) & 0b1111_0000
+		group = append(group, slog.String("reason", v.ReasonCode().NameFor(packetType)))
+	}
+	for _, a := range attrs {
+		if !a.Equal(slog.Attr{}) {
+			group = append(group, a)
+		}
+	}
+	return slog.GroupValue(group...)
+}
+
+// optString returns an empty attribute if v is empty.
+func optString(key, v string) slog.Attr {
+	if v == "" {
+		return slog.Attr{}
+	}
+	return slog.String(key, v)
+}
+
+// ----------------------------------------
+
+// NewDumpHandler returns a handler logging packets with all fields,
+// as written by Dump, in records at debug level. Other records are
+// passed to h as is.
+func NewDumpHandler(h slog.Handler) *DumpHandler {
+	return &DumpHandler{
+		handler: h,
+		level:   slog.LevelDebug,
+		dumper:  NewDumper(),
+	}
+}
+
+// DumpHandler replaces packet attributes with full dumps in records
+// at or below its level. Only attributes of a record are replaced,
+// not those added with WithAttrs.
+type DumpHandler struct {
+	handler slog.Handler
+	level   slog.Leveler
+	dumper  *Dumper
+}
+
+// SetLevel sets the highest level of records with dumped packets,
+// default slog.LevelDebug.
+func (h *DumpHandler) SetLevel(v slog.Leveler) { h.level = v }
+func (h *DumpHandler) Level() slog.Leveler     { return h.level }
+
+// SetDumper sets the dumper rendering packets, default NewDumper.
+func (h *DumpHandler) SetDumper(v *Dumper) { h.dumper = v }
+func (h *DumpHandler) Dumper() *Dumper     { return h.dumper }
+
+func (h *DumpHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *DumpHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level > h.level.Level() {
+		return h.handler.Handle(ctx, r)
+	}
+	dumped := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		dumped.AddAttrs(h.dumpAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, dumped)
+}
+
+func (h *DumpHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.handler = h.handler.WithAttrs(attrs)
+	return &c
+}
+
+func (h *DumpHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.handler = h.handler.WithGroup(name)
+	return &c
+}
+
+// dumpAttr replaces packets in a with a group of all their fields.
+func (h *DumpHandler) dumpAttr(a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, v := range group {
+			attrs[i] = h.dumpAttr(v)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+
+	case slog.KindAny, slog.KindLogValuer:
+		p, ok := a.Value.Any().(Packet)
+		if !ok {
+			return a
+		}
+		name := typeName(p)
+		fields := dumpFields(name, p, h.dumper)
+		attrs := make([]slog.Attr, 0, len(fields)+2)
+		if v, ok := p.(slog.LogValuer); ok {
+			attrs = append(attrs, v.LogValue().Group()[:2]...) // type and flags
+		}
+		for _, f := range fields {
+			key := strings.TrimPrefix(f.name, name+".")
+			attrs = append(attrs, slog.String(key, f.value))
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	}
+	return a
+}
+
+// dumpFields parses the Dump of p into named fields. Sections, e.g.
+// UserProperties, are lines without a value followed by indented
+// lines. Numbered lines in a section are named by their index and
+// other indented lines continue the previous value.
+func dumpFields(root string, p Packet, d *Dumper) fields {
+	var buf bytes.Buffer
+	d.Dump(&buf, p)
+
+	var res fields
+	path := []string{root} // path[i] is the section at indent i
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		depth := (len(line) - len(trimmed)) / 2
+		if depth+1 > len(path) && len(res) > 0 {
+			// continued value, e.g. indented JSON
+			res[len(res)-1].value += "\n" + trimmed
+			continue
+		}
+		if depth+1 > len(path) {
+			depth = len(path) - 1 // unexpected indent
+		}
+		path = path[:depth+1]
+		prefix := strings.Join(path, ".")
+
+		// numbered items, e.g. "0. color: red"
+		if i, v, ok := strings.Cut(trimmed, ". "); ok && isDigits(i) {
+			res = append(res, field{name: prefix + "[" + i + "]", value: v})
+			continue
+		}
+		name, value, ok := strings.Cut(trimmed, ": ")
+		if !ok {
+			// section
+			path = append(path, strings.TrimSuffix(trimmed, ":"))
+			continue
+		}
+		res = append(res, field{name: prefix + "." + name, value: value})
+	}
+	return res
+}
+
+func isDigits(v string) bool {
+	for _, c := range v {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(v) > 0
+}
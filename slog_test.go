@@ -0,0 +1,129 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func ExamplePublish_LogValue() {
+	p := Pub(1, "a/b", "gopher")
+	p.SetPacketID(3)
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: dropTime,
+	}))
+	log.Info("out", "packet", p)
+	// output:
+	// level=INFO msg=out packet.type=PUBLISH packet.flags=--1- packet.packetID=3 packet.topic=a/b packet.size=6
+}
+
+func ExampleNewDumpHandler() {
+	p := NewConnect()
+	p.SetClientID("pink")
+	p.SetPassword([]byte("secret"))
+
+	h := NewDumpHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: dropTime,
+	}))
+	log := slog.New(h)
+	log.Info("in", "packet", p)
+	log.Debug("in", "packet", p)
+	// output:
+	// level=INFO msg=in packet.type=CONNECT packet.flags=---- packet.clientID=pink packet.password=*********
+	// level=DEBUG msg=in packet.type=CONNECT packet.flags=---- packet.AuthData="" packet.AuthMethod="" packet.CleanStart=false packet.ClientID=pink packet.KeepAlive=0 packet.MaxPacketSize=0 packet.Password="\"*********\"" packet.ProtocolName=MQTT packet.ProtocolVersion=5 packet.ReceiveMax=0 packet.RequestProblemInfo=false packet.RequestResponseInfo=false packet.SessionExpiryInterval=0 packet.TopicAliasMax=0 packet.Username=""
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func TestLogValue(t *testing.T) {
+	for _, p := range annotatedPackets() {
+		v, ok := p.(slog.LogValuer)
+		if !ok {
+			t.Fatalf("%T is not a slog.LogValuer", p)
+		}
+		group := v.LogValue().Group()
+		if len(group) < 2 || group[0].Key != "type" || group[1].Key != "flags" {
+			t.Errorf("%T: %v", p, group)
+		}
+		// password of connect
+		if got := v.LogValue().String(); strings.Contains(got, "cute") {
+			t.Errorf("%T: %s", p, got)
+		}
+	}
+
+	a := NewAuth()
+	a.SetAuthMethod("SCRAM-SHA-256")
+	a.SetAuthData([]byte("secret"))
+	if got := a.LogValue().String(); strings.Contains(got, "secret") {
+		t.Error(got)
+	}
+
+	d := NewDisconnect()
+	if got := d.LogValue().String(); !strings.Contains(got, "reason=NormalDisconnect") {
+		t.Error(got)
+	}
+
+	p := Pub(0, "", "gopher")
+	p.SetTopicAlias(4)
+	if got := p.LogValue().String(); !strings.Contains(got, "topicAlias=4") {
+		t.Error(got)
+	}
+
+	s := NewPublishStream()
+	s.SetTopicName("a/b")
+	s.SetPayload(strings.NewReader("gopher"), 6)
+	if got := s.LogValue().String(); !strings.Contains(got, "size=6") {
+		t.Error(got)
+	}
+}
+
+func TestDumpHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewDumpHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+	eq(t, h.SetLevel, h.Level, slog.Leveler(slog.LevelInfo))
+
+	c := NewConnAck()
+	c.SetAuthMethod("SCRAM-SHA-256")
+	c.SetAuthData([]byte("secret"))
+	c.AddUserProp("color", "red")
+
+	log := slog.New(h).With("id", 1).WithGroup("g")
+	log.Info("in", slog.Group("nested", "packet", c), "n", 2)
+	got := buf.String()
+	for _, exp := range []string{
+		"id=1",
+		"g.nested.packet.type=CONNACK",
+		`g.nested.packet.UserProperties[0]="color: \"red\""`,
+		`g.nested.packet.AuthData="\"*********\""`,
+		"g.n=2",
+	} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("missing %s\n%s", exp, got)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Error(got)
+	}
+
+	// above level
+	buf.Reset()
+	log.Warn("in", "packet", c)
+	if got := buf.String(); strings.Contains(got, "UserProperties") {
+		t.Error(got)
+	}
+	if h.Enabled(context.Background(), slog.LevelDebug-1) {
+		t.Error("enabled below handler level")
+	}
+}
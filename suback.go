@@ -19,6 +19,23 @@ type SubAck struct {
 	reasonCodes  []uint8
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *SubAck) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	if len(p.reasonCodes) == 0 {
+		return newMalformed(p, "reason codes", "no")
+	}
+	for _, c := range p.reasonCodes {
+		if !ReasonCode(c).ValidFor(SUBACK) {
+			return newMalformed(p, "reason code", ReasonCode(c).NameFor(SUBACK))
+		}
+	}
+	return nil
+}
+
 func (p *SubAck) String() string {
 	return fmt.Sprintf("%s p%v %v bytes",
 		firstByte(p.fixed).String(),
@@ -27,10 +44,14 @@ func (p *SubAck) String() string {
 	)
 }
 
-func (p *SubAck) dump(w io.Writer) {
+func (p *SubAck) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
 	fmt.Fprintf(w, "ReasonString: %v\n", p.ReasonString())
-	fmt.Fprintf(w, "ReasonCodes: %v\n", p.ReasonCodes())
+	names := make([]string, len(p.reasonCodes))
+	for i, c := range p.reasonCodes {
+		names[i] = ReasonCode(c).NameFor(SUBACK)
+	}
+	fmt.Fprintf(w, "ReasonCodes: %v\n", names)
 	p.UserProperties.dump(w)
 }
 
@@ -97,7 +118,9 @@ func (p *SubAck) UnmarshalBinary(data []byte) error {
 	b := &buffer{data: data}
 	b.get(&p.packetID)
 	b.getAny(p.propertyMap(), p.appendUserProperty)
-
+	if b.err != nil {
+		return b.err
+	}
 	p.reasonCodes = make([]uint8, len(data)-b.i)
 
 	for i, _ := range p.reasonCodes {
@@ -28,6 +28,9 @@ func (p *Subscribe) String() string {
 }
 
 func (p *Subscribe) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0b0010); err != nil {
+		return err
+	}
 	if len(p.filters) == 0 {
 		return newMalformed(p, "filters", "no")
 	}
@@ -42,7 +45,7 @@ func (p *Subscribe) WellFormed() *Malformed {
 	return nil
 }
 
-func (p *Subscribe) dump(w io.Writer) {
+func (p *Subscribe) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
 	if p.subscriptionID != nil {
 		fmt.Fprintf(w, "SubscriptionID: %v\n", p.SubscriptionID())
@@ -150,7 +153,7 @@ func (p *Subscribe) UnmarshalBinary(data []byte) error {
 		b.get(&f.filter)
 		b.get(&f.options)
 		p.filters = append(p.filters, f)
-		if b.i == len(data) {
+		if b.err != nil || b.atEnd() {
 			break
 		}
 	}
@@ -11,6 +11,12 @@ type Undefined struct {
 	data  []byte
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *Undefined) WellFormed() *Malformed {
+	return newMalformed(p, "packet type", "forbidden")
+}
+
 func (p *Undefined) String() string {
 	return fmt.Sprintf("%s %v bytes",
 		firstByte(p.fixed).String(), 0,
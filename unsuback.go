@@ -21,6 +21,23 @@ type UnsubAck struct {
 	reasonCodes  []uint8
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *UnsubAck) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0); err != nil {
+		return err
+	}
+	if len(p.reasonCodes) == 0 {
+		return newMalformed(p, "reason codes", "no")
+	}
+	for _, c := range p.reasonCodes {
+		if !ReasonCode(c).ValidFor(UNSUBACK) {
+			return newMalformed(p, "reason code", ReasonCode(c).NameFor(UNSUBACK))
+		}
+	}
+	return nil
+}
+
 func (p *UnsubAck) String() string {
 	return fmt.Sprintf("%s p%v %v bytes",
 		firstByte(p.fixed).String(),
@@ -29,10 +46,14 @@ func (p *UnsubAck) String() string {
 	)
 }
 
-func (p *UnsubAck) dump(w io.Writer) {
+func (p *UnsubAck) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
 	fmt.Fprintf(w, "ReasonString: %v\n", p.ReasonString())
-	fmt.Fprintf(w, "ReasonCodes: %v\n", p.ReasonCodes())
+	names := make([]string, len(p.reasonCodes))
+	for i, c := range p.reasonCodes {
+		names[i] = ReasonCode(c).NameFor(UNSUBACK)
+	}
+	fmt.Fprintf(w, "ReasonCodes: %v\n", names)
 	p.UserProperties.dump(w)
 }
 
@@ -99,7 +120,9 @@ func (p *UnsubAck) UnmarshalBinary(data []byte) error {
 	b := &buffer{data: data}
 	b.get(&p.packetID)
 	b.getAny(p.propertyMap(), p.appendUserProperty)
-
+	if b.err != nil {
+		return b.err
+	}
 	p.reasonCodes = make([]uint8, len(data)-b.i)
 
 	for i, _ := range p.reasonCodes {
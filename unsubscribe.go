@@ -18,6 +18,21 @@ type Unsubscribe struct {
 	filters []wstring
 }
 
+// WellFormed returns a Malformed error if the packet does not follow
+// the specification.
+func (p *Unsubscribe) WellFormed() *Malformed {
+	if err := wellFormedFixed(p, p.fixed, 0b0010); err != nil {
+		return err
+	}
+	if p.packetID == 0 {
+		return newMalformed(p, "packet ID", "empty")
+	}
+	if len(p.filters) == 0 {
+		return newMalformed(p, "filters", "no")
+	}
+	return nil
+}
+
 func (p *Unsubscribe) String() string {
 	return fmt.Sprintf("%s p%v, %s, %v bytes",
 		firstByte(p.fixed).String(),
@@ -27,7 +42,7 @@ func (p *Unsubscribe) String() string {
 	)
 }
 
-func (p *Unsubscribe) dump(w io.Writer) {
+func (p *Unsubscribe) dump(w io.Writer, d *Dumper) {
 	fmt.Fprintf(w, "PacketID: %v\n", p.PacketID())
 
 	if len(p.filters) > 0 {
@@ -108,7 +123,7 @@ func (p *Unsubscribe) UnmarshalBinary(data []byte) error {
 		var f wstring
 		b.get(&f)
 		p.filters = append(p.filters, f)
-		if b.i == len(data) {
+		if b.err != nil || b.atEnd() {
 			break
 		}
 	}
@@ -121,7 +121,9 @@ func (v bindata) fill(data []byte, i int) int {
 
 func (v *bindata) UnmarshalBinary(data []byte) error {
 	var length wuint16
-	_ = length.UnmarshalBinary(data)
+	if err := length.UnmarshalBinary(data); err != nil {
+		return err
+	}
 	if len(data) < int(length)+2 {
 		return unmarshalErr(v, "", "missing data")
 	}
@@ -270,6 +272,9 @@ func (v wbool) fill(data []byte, i int) int {
 	return 1
 }
 func (v *wbool) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return unmarshalErr(v, "", "missing data")
+	}
 	switch data[0] {
 	case 0:
 		*v = wbool(false)
@@ -304,14 +309,6 @@ func (v bits) fill(data []byte, i int) int {
 	return 1
 }
 
-// fillOpt fills the bits if > 0
-func (v bits) fillOpt(data []byte, i int) int {
-	if v == 0 {
-		return 0
-	}
-	return v.fill(data, i)
-}
-
 func (v *bits) ReadFrom(r io.Reader) (int64, error) {
 	data := make([]byte, 1)
 	if n, err := r.Read(data); err != nil {
@@ -320,6 +317,9 @@ func (v *bits) ReadFrom(r io.Reader) (int64, error) {
 	return 1, v.UnmarshalBinary(data)
 }
 func (v *bits) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return unmarshalErr(v, "", "missing data")
+	}
 	*v = bits(data[0])
 	return nil
 }
@@ -353,6 +353,9 @@ func (v wuint16) fill(data []byte, i int) int {
 }
 
 func (v *wuint16) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return unmarshalErr(v, "", "missing data")
+	}
 	*v = wuint16(binary.BigEndian.Uint16(data))
 	return nil
 }
@@ -380,6 +383,9 @@ func (v wuint32) fill(data []byte, i int) int {
 }
 
 func (v *wuint32) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return unmarshalErr(v, "", "missing data")
+	}
 	*v = wuint32(binary.BigEndian.Uint32(data))
 	return nil
 }
@@ -397,6 +403,9 @@ func (v Ident) fill(data []byte, i int) int {
 }
 
 func (v *Ident) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return unmarshalErr(v, "", "missing data")
+	}
 	*v = Ident(data[0])
 	return nil
 }
@@ -274,3 +274,34 @@ func (w *brokenRW) Read(data []byte) (int, error) {
 }
 
 var broken = fmt.Errorf("broken")
+
+func FuzzWireTypes(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0x00, 0x02, 'a', 'b'})
+	f.Add([]byte{0x80, 0x80, 0x01})
+	f.Add([]byte{0x00, 0x03, 0x00, 0x01, 'k'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var (
+			b   bits
+			u16 wuint16
+			u32 wuint32
+			vb  vbint
+			bo  wbool
+			s   wstring
+			bd  bindata
+			rd  rawdata
+			id  Ident
+			up  UserProp
+		)
+		for _, v := range []wireType{
+			&b, &u16, &u32, &vb, &bo, &s, &bd, &rd, &id, &up,
+		} {
+			if err := v.UnmarshalBinary(data); err != nil {
+				continue
+			}
+			if v.width() > len(data) {
+				t.Errorf("%T width %v exceeds %v bytes", v, v.width(), len(data))
+			}
+		}
+	})
+}
@@ -0,0 +1,84 @@
+/*
+Package ws provides mqtt over websocket transport.
+
+Control packets are sent in binary frames using the mqtt
+subprotocol, see
+https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_Toc3901285
+
+A packet may be split across several frames and one frame may
+contain several packets. The connections returned by this package
+hide frame boundaries so mq.ReadPacket and each packets WriteTo
+method work as they would on a plain TCP connection.
+*/
+package ws
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+// Subprotocol is the websocket subprotocol name used for mqtt.
+const Subprotocol = "mqtt"
+
+// Dial opens a websocket connection to the given url, e.g.
+// ws://example.com/mqtt, negotiating the mqtt subprotocol.
+func Dial(url, origin string) (net.Conn, error) {
+	conn, err := websocket.Dial(url, Subprotocol, origin)
+	if err != nil {
+		return nil, fmt.Errorf("ws.Dial: %w", err)
+	}
+	return NewConn(conn)
+}
+
+// NewConn adapts the websocket connection for mqtt use. Returns an
+// error if the mqtt subprotocol was not negotiated.
+func NewConn(conn *websocket.Conn) (net.Conn, error) {
+	if !hasSubprotocol(conn.Config().Protocol) {
+		conn.Close()
+		return nil, fmt.Errorf(
+			"ws.NewConn: %w %q", ErrSubprotocol, conn.Config().Protocol,
+		)
+	}
+	// mqtt packets must be sent in binary frames, the default is
+	// text
+	conn.PayloadType = websocket.BinaryFrame
+	return conn, nil
+}
+
+// NewHandler returns a http.Handler that upgrades requests offering
+// the mqtt subprotocol to websocket connections. Requests without
+// it are rejected. The connection is closed once handle returns.
+func NewHandler(handle func(net.Conn)) http.Handler {
+	return websocket.Server{
+		Handshake: func(c *websocket.Config, r *http.Request) error {
+			if !hasSubprotocol(c.Protocol) {
+				return ErrSubprotocol
+			}
+			c.Protocol = []string{Subprotocol}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			c, err := NewConn(conn)
+			if err != nil {
+				return
+			}
+			handle(c)
+		},
+	}
+}
+
+func hasSubprotocol(offered []string) bool {
+	for _, v := range offered {
+		if v == Subprotocol {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrSubprotocol is returned when the peer does not offer or accept
+// the mqtt subprotocol.
+var ErrSubprotocol = fmt.Errorf("missing %s subprotocol", Subprotocol)
@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gregoryv/mq"
+	"golang.org/x/net/websocket"
+)
+
+func TestDial(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(echo))
+	defer srv.Close()
+
+	conn, err := Dial(wsURL(srv), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	p := mq.Pub(0, "a/b", "gopher")
+	if _, err := p.WriteTo(conn); err != nil {
+		t.Fatal(err)
+	}
+	got, err := mq.ReadPacket(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != p.String() {
+		t.Errorf("got %v, expected %v", got, p)
+	}
+}
+
+func TestNewConn_split(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(echo))
+	defer srv.Close()
+
+	raw, conn := dialRaw(t, srv)
+	defer conn.Close()
+
+	// one packet split in one frame per byte
+	var buf bytes.Buffer
+	mq.Pub(0, "a/b", "gopher").WriteTo(&buf)
+	for _, b := range buf.Bytes() {
+		if _, err := raw.Write([]byte{b}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mq.ReadPacket(conn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewConn_coalesced(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(echo))
+	defer srv.Close()
+
+	raw, conn := dialRaw(t, srv)
+	defer conn.Close()
+
+	// two and a half packets in the first frame, rest in second
+	var buf bytes.Buffer
+	mq.NewPingReq().WriteTo(&buf)
+	mq.Pub(0, "a/b", "gopher").WriteTo(&buf)
+	mq.Pub(0, "c/d", "gopher").WriteTo(&buf)
+	data := buf.Bytes()
+	i := len(data) - 5
+	raw.Write(data[:i])
+	raw.Write(data[i:])
+
+	for _, exp := range []string{"PINGREQ", "a/b", "c/d"} {
+		p, err := mq.ReadPacket(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(p.String(), exp) {
+			t.Errorf("%v missing %q", p, exp)
+		}
+	}
+}
+
+func TestNewHandler_missingSubprotocol(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(echo))
+	defer srv.Close()
+
+	if _, err := websocket.Dial(wsURL(srv), "", srv.URL); err == nil {
+		t.Error("expected error without subprotocol")
+	}
+}
+
+func TestNewConn(t *testing.T) {
+	srv := httptest.NewServer(websocket.Handler(func(c *websocket.Conn) {}))
+	defer srv.Close()
+
+	raw, err := websocket.Dial(wsURL(srv), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewConn(raw); err == nil {
+		t.Error("expected error without subprotocol")
+	}
+}
+
+// dialRaw returns a websocket connection for writing frames as is
+// and the same connection adapted for mqtt.
+func dialRaw(t *testing.T, srv *httptest.Server) (*websocket.Conn, net.Conn) {
+	t.Helper()
+	raw, err := websocket.Dial(wsURL(srv), Subprotocol, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := NewConn(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw, conn
+}
+
+// echo writes back each packet it reads
+func echo(conn net.Conn) {
+	for {
+		p, err := mq.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if _, err := p.WriteTo(conn); err != nil {
+			return
+		}
+	}
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}